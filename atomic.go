@@ -0,0 +1,134 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type atomicFile struct {
+	*os.File
+	path string
+	mode fs.FileMode
+	done bool
+
+	remote      *remoteTarget
+	contentType string
+
+	maxSize  int64
+	size     int64
+	segments []*atomicFile
+	// replace removes segments left over from an earlier run on commit.
+	replace bool
+}
+
+func createAtomic(path string) (*atomicFile, error) {
+	if remote, ok := parseRemote(path); ok {
+		if err := remote.validate(); err != nil {
+			return nil, err
+		}
+		tmp, err := os.CreateTemp("", "ipsubmap-upload-*.tmp")
+		if err != nil {
+			return nil, err
+		}
+		return &atomicFile{File: tmp, path: path, remote: &remote}, nil
+	}
+
+	mode := fs.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &atomicFile{File: tmp, path: path, mode: mode}, nil
+}
+
+func (a *atomicFile) Commit() error {
+	if a.done {
+		return nil
+	}
+
+	if err := a.commit(); err != nil {
+		abortAll(a.segments)
+		return err
+	}
+
+	for _, s := range a.segments {
+		if err := s.commit(); err != nil {
+			abortAll(a.segments)
+			return err
+		}
+	}
+
+	if a.maxSize > 0 && a.replace {
+		return removeStaleSegments(a.path, len(a.segments)+1)
+	}
+	return nil
+}
+
+func (a *atomicFile) commit() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+
+	if a.remote != nil {
+		return a.upload()
+	}
+
+	if err := a.File.Sync(); err != nil {
+		a.File.Close()
+		os.Remove(a.File.Name())
+		return fmt.Errorf("failed to sync %q: %v", a.path, err)
+	}
+
+	if err := a.File.Chmod(a.mode); err != nil {
+		a.File.Close()
+		os.Remove(a.File.Name())
+		return fmt.Errorf("failed to chmod %q: %v", a.path, err)
+	}
+
+	if err := a.File.Close(); err != nil {
+		os.Remove(a.File.Name())
+		return fmt.Errorf("failed to close %q: %v", a.path, err)
+	}
+
+	if err := os.Rename(a.File.Name(), a.path); err != nil {
+		os.Remove(a.File.Name())
+		return fmt.Errorf("failed to rename into %q: %v", a.path, err)
+	}
+
+	return nil
+}
+
+func (a *atomicFile) upload() error {
+	defer os.Remove(a.File.Name())
+	defer a.File.Close()
+
+	return a.remote.upload(a.File, cmp.Or(a.contentType, "text/plain"))
+}
+
+func (a *atomicFile) Abort() {
+	abortAll(a.segments)
+	if a.done {
+		return
+	}
+	a.done = true
+	a.File.Close()
+	os.Remove(a.File.Name())
+}
+
+func abortAll(files []*atomicFile) {
+	for _, f := range files {
+		f.Abort()
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAtomicFile_commit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "public.txt")
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+
+	out, err := createAtomic(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := out.WriteString("new"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ := os.ReadFile(path)
+	if string(got) != "old" {
+		t.Errorf("expected target untouched before commit, got %q", got)
+	}
+
+	if err := out.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, _ = os.ReadFile(path)
+	if string(got) != "new" {
+		t.Errorf("expected %q, got %q", "new", got)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("expected mode to be preserved, got %v", info.Mode().Perm())
+	}
+}
+
+func TestAtomicFile_abort(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "public.txt")
+
+	out, err := createAtomic(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.Abort()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files left behind, got %d", len(entries))
+	}
+}
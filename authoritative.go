@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	lookupNS   = net.LookupNS
+	soaTimeout = 5 * time.Second
+)
+
+// maxCNAMEHops bounds how many out-of-zone CNAME targets a lookup follows.
+const maxCNAMEHops = 8
+
+// authoritative resolves hostnames by querying the nameservers of their zone
+// directly instead of the recursive resolver.
+type authoritative struct {
+	mu       sync.Mutex
+	dial     func(ctx context.Context, network, address string) (net.Conn, error)
+	fallback func(host string) ([]net.IP, error)
+	zones    map[string]*zoneEntry
+}
+
+// zoneEntry is the closest enclosing zone of a name. done is closed once z is
+// known, so concurrent lookups in the same zone wait for a single NS lookup.
+type zoneEntry struct {
+	done chan struct{}
+	z    *zone
+}
+
+// zone is a zone with nameservers that answered, queried through r. Its SOA
+// record is queried once, the first time a name in it does not exist.
+type zone struct {
+	name    string
+	servers []nameserver
+	r       *net.Resolver
+
+	soaOnce sync.Once
+	soaTTL  time.Duration
+	soaOK   bool
+}
+
+// nameserver is a nameserver of a zone and one of its addresses.
+type nameserver struct {
+	host string
+	addr string
+}
+
+func newAuthoritative(source net.IP) *authoritative {
+	return &authoritative{
+		dial:     sourceDialer(source),
+		fallback: lookupIP,
+		zones:    make(map[string]*zoneEntry),
+	}
+}
+
+// closestZone returns the closest enclosing zone of host that has NS records,
+// or nil when none was found. Results are cached for every name looked at on
+// the way up.
+func (a *authoritative) closestZone(host string) *zone {
+	name := strings.TrimSuffix(host, ".")
+	_, parent, ok := strings.Cut(name, ".")
+	if !ok {
+		return nil
+	}
+
+	a.mu.Lock()
+	if e, ok := a.zones[name]; ok {
+		a.mu.Unlock()
+		<-e.done
+		return e.z
+	}
+	e := &zoneEntry{done: make(chan struct{})}
+	a.zones[name] = e
+	a.mu.Unlock()
+
+	defer close(e.done)
+	if e.z = a.nameservers(name); e.z == nil {
+		e.z = a.closestZone(parent)
+	}
+	return e.z
+}
+
+func (a *authoritative) nameservers(name string) *zone {
+	records, err := lookupNS(name)
+	if err != nil {
+		return nil
+	}
+
+	var servers []nameserver
+	for _, ns := range records {
+		host := strings.TrimSuffix(ns.Host, ".")
+		ips, err := a.fallback(host)
+		if err != nil {
+			continue
+		}
+		for _, ip := range ips {
+			servers = append(servers, nameserver{host: host, addr: net.JoinHostPort(ip.String(), "53")})
+		}
+	}
+	if len(servers) == 0 {
+		return nil
+	}
+
+	next := 0
+	var mu sync.Mutex
+	return &zone{name: name, servers: servers, r: &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			mu.Lock()
+			server := servers[next%len(servers)]
+			next++
+			mu.Unlock()
+			return a.dial(ctx, network, server.addr)
+		},
+	}}
+}
+
+// lookupIP resolves host through its zone's nameservers, falling back to the
+// recursive resolver when they cannot be found.
+func (a *authoritative) lookupIP(host string) ([]net.IP, error) {
+	return a.lookup(host, 0)
+}
+
+func (a *authoritative) lookup(host string, hops int) ([]net.IP, error) {
+	z := a.closestZone(host)
+	if z == nil {
+		return a.fallback(host)
+	}
+	r := z.r
+
+	ctx := context.Background()
+	addrs, err := r.LookupIPAddr(ctx, host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound && hops < maxCNAMEHops {
+		// Nameservers only answer for their own zone, so a CNAME to a name
+		// outside of it comes back without addresses. Resolve the target on
+		// its own instead.
+		if target, cerr := r.LookupCNAME(ctx, host); cerr == nil && !strings.EqualFold(strings.TrimSuffix(target, "."), strings.TrimSuffix(host, ".")) {
+			return a.lookup(strings.TrimSuffix(target, "."), hops+1)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// negativeTTL returns how long the nonexistence of host may be cached: the
+// lower of the TTL and the minimum field of its zone's SOA record, as in RFC
+// 2308. It returns false when there is no zone or its SOA record is unknown.
+func (a *authoritative) negativeTTL(host string) (time.Duration, bool) {
+	z := a.closestZone(host)
+	if z == nil {
+		return 0, false
+	}
+	z.soaOnce.Do(func() {
+		z.soaTTL, z.soaOK = z.querySOA()
+	})
+	return z.soaTTL, z.soaOK
+}
+
+func (z *zone) querySOA() (time.Duration, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), soaTimeout)
+	defer cancel()
+
+	conn, err := z.r.Dial(ctx, "udp", "")
+	if err != nil {
+		return 0, false
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(soaTimeout))
+
+	id := uint16(time.Now().UnixNano())
+	if _, err := conn.Write(dnsQuery(id, z.name, dnsTypeSOA)); err != nil {
+		return 0, false
+	}
+	msg := make([]byte, 1232)
+	n, err := conn.Read(msg)
+	if err != nil || n < 2 || binary.BigEndian.Uint16(msg) != id {
+		return 0, false
+	}
+	ttl, err := soaNegativeTTL(msg[:n])
+	return ttl, err == nil
+}
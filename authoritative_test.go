@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveDNS answers every A query on conn with ip, every SOA query with a
+// record with a TTL of 300 and a minimum of 60, and every other query with an
+// empty answer.
+func serveDNS(conn net.PacketConn, ip net.IP) {
+	serveDNSWith(conn, ip, nil)
+}
+
+// serveDNSWith is serveDNS, except that names in cnames are answered with
+// only a CNAME record to their target.
+func serveDNSWith(conn net.PacketConn, ip net.IP, cnames map[string]string) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		query := buf[:n]
+
+		end := 12
+		for end < len(query) && query[end] != 0 {
+			end += int(query[end]) + 1
+		}
+		end += 5
+		if end > len(query) {
+			continue
+		}
+		qtype := binary.BigEndian.Uint16(query[end-4:])
+
+		resp := append([]byte{}, query[:2]...)
+		resp = append(resp, 0x85, 0x80, 0, 1, 0, 0, 0, 0, 0, 0)
+		resp = append(resp, query[12:end]...)
+		if target, ok := cnames[queryName(query[12:end-4])]; ok {
+			var rdata []byte
+			for _, label := range strings.Split(target, ".") {
+				rdata = append(rdata, byte(len(label)))
+				rdata = append(rdata, label...)
+			}
+			rdata = append(rdata, 0)
+			resp[7] = 1
+			resp = append(resp, 0xc0, 12, 0, 5, 0, 1, 0, 0, 0, 60, 0, byte(len(rdata)))
+			resp = append(resp, rdata...)
+		} else if qtype == 1 {
+			resp[7] = 1
+			resp = append(resp, 0xc0, 12, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4)
+			resp = append(resp, ip.To4()...)
+		} else if qtype == dnsTypeSOA {
+			resp[7] = 1
+			resp = append(resp, 0xc0, 12, 0, 6, 0, 1, 0, 0, 1, 44, 0, 24, 0xc0, 12, 0xc0, 12)
+			resp = append(resp, 0, 0, 0, 1, 0, 0, 14, 16, 0, 0, 7, 8, 0, 9, 58, 128, 0, 0, 0, 60)
+		}
+		conn.WriteTo(resp, addr)
+	}
+}
+
+func queryName(b []byte) string {
+	var labels []string
+	for len(b) > 0 && b[0] != 0 {
+		labels = append(labels, string(b[1:1+b[0]]))
+		b = b[1+b[0]:]
+	}
+	return strings.ToLower(strings.Join(labels, "."))
+}
+
+func TestAuthoritativeLookupIP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	go serveDNSWith(conn, net.ParseIP("10.0.0.1"), map[string]string{
+		"cdn.example.com":   "edge.example.net",
+		"alias.example.com": "www.example.com",
+	})
+
+	nsLookups := 0
+	lookupNS = func(name string) ([]*net.NS, error) {
+		nsLookups++
+		if name == "example.com" {
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	defer func() { lookupNS = net.LookupNS }()
+
+	var mu sync.Mutex
+	var dialed []string
+	a := &authoritative{
+		dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, address)
+			mu.Unlock()
+			var d net.Dialer
+			return d.DialContext(ctx, network, conn.LocalAddr().String())
+		},
+		fallback: func(host string) ([]net.IP, error) {
+			switch host {
+			case "ns1.example.com":
+				return []net.IP{net.ParseIP("192.0.2.53")}, nil
+			case "www.example.org":
+				return []net.IP{net.ParseIP("10.9.9.9")}, nil
+			case "edge.example.net":
+				return []net.IP{net.ParseIP("10.8.8.8")}, nil
+			}
+			return nil, fmt.Errorf("unexpected recursive lookup of %s", host)
+		},
+		zones: make(map[string]*zoneEntry),
+	}
+
+	tt := map[string]struct {
+		host string
+		want string
+	}{
+		"authoritative":  {host: "www.example.com", want: "10.0.0.1"},
+		"cached zone":    {host: "api.example.com", want: "10.0.0.1"},
+		"no zone":        {host: "www.example.org", want: "10.9.9.9"},
+		"in zone cname":  {host: "alias.example.com", want: "10.0.0.1"},
+		"external cname": {host: "cdn.example.com", want: "10.8.8.8"},
+	}
+
+	for _, name := range []string{"authoritative", "cached zone", "no zone", "in zone cname", "external cname"} {
+		tc := tt[name]
+		t.Run(name, func(t *testing.T) {
+			ips, err := a.lookupIP(tc.host)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(ips) != 1 || ips[0].String() != tc.want {
+				t.Errorf("expected %s, got %v", tc.want, ips)
+			}
+		})
+	}
+
+	if len(dialed) == 0 || dialed[0] != "192.0.2.53:53" {
+		t.Errorf("expected queries to the zone's nameserver, got %v", dialed)
+	}
+	// www.example.com, example.com, api.example.com, www.example.org, example.org,
+	// alias.example.com, cdn.example.com, edge.example.net and example.net.
+	if nsLookups != 9 {
+		t.Errorf("expected 9 NS lookups, got %d", nsLookups)
+	}
+}
+
+func TestAuthoritativeNegativeTTL(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	go serveDNS(conn, net.ParseIP("10.0.0.1"))
+
+	lookupNS = func(name string) ([]*net.NS, error) {
+		if name == "example.com" {
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	defer func() { lookupNS = net.LookupNS }()
+
+	queries := 0
+	a := &authoritative{
+		dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			queries++
+			var d net.Dialer
+			return d.DialContext(ctx, network, conn.LocalAddr().String())
+		},
+		fallback: func(host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("192.0.2.53")}, nil
+		},
+		zones: make(map[string]*zoneEntry),
+	}
+
+	tt := map[string]struct {
+		host string
+		want time.Duration
+		ok   bool
+	}{
+		"zone":        {host: "missing.example.com", want: time.Minute, ok: true},
+		"cached zone": {host: "other.example.com", want: time.Minute, ok: true},
+		"no zone":     {host: "missing.example.org"},
+	}
+
+	for _, name := range []string{"zone", "cached zone", "no zone"} {
+		tc := tt[name]
+		t.Run(name, func(t *testing.T) {
+			got, ok := a.negativeTTL(tc.host)
+			if got != tc.want || ok != tc.ok {
+				t.Errorf("expected %v %v, got %v %v", tc.want, tc.ok, got, ok)
+			}
+		})
+	}
+	if queries != 1 {
+		t.Errorf("expected 1 SOA query, got %d", queries)
+	}
+}
+
+func TestAuthoritativeZoneResolver_concurrent(t *testing.T) {
+	var mu sync.Mutex
+	nsLookups := make(map[string]int)
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	lookupNS = func(name string) ([]*net.NS, error) {
+		mu.Lock()
+		nsLookups[name]++
+		mu.Unlock()
+		if name == "example.com" {
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			<-release
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	defer func() { lookupNS = net.LookupNS }()
+
+	a := &authoritative{
+		fallback: func(host string) ([]net.IP, error) {
+			return []net.IP{net.ParseIP("192.0.2.53")}, nil
+		},
+		zones: make(map[string]*zoneEntry),
+	}
+
+	// A lookup in an unrelated zone must not wait for the slow one.
+	var wg sync.WaitGroup
+	for _, host := range []string{"a.example.com", "b.example.com", "a.example.com"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if a.closestZone(host) == nil {
+				t.Errorf("expected a resolver for %s", host)
+			}
+		}()
+	}
+	<-started
+	if r := a.closestZone("www.example.org"); r != nil {
+		t.Errorf("expected no resolver for www.example.org")
+	}
+	close(release)
+	wg.Wait()
+
+	if n := nsLookups["example.com"]; n != 1 {
+		t.Errorf("expected 1 NS lookup of example.com, got %d", n)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	expandAXFR = "axfr"
+
+	dnsTypeSOA  = 6
+	dnsTypeAXFR = 252
+)
+
+var (
+	axfrTimeout = 30 * time.Second
+	dialAXFR    = func(ctx context.Context, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", address)
+	}
+)
+
+// transfer is a successful zone transfer.
+type transfer struct {
+	zone   string
+	server string
+	names  []string
+}
+
+func dnsQuery(id uint16, name string, qtype uint16) []byte {
+	msg := binary.BigEndian.AppendUint16(nil, id)
+	msg = append(msg, 0, 0, 0, 1, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	return binary.BigEndian.AppendUint16(msg, 1)
+}
+
+var errMalformedMessage = errors.New("malformed dns message")
+
+// readName reads the possibly compressed name at off, returning it and the
+// offset right after it.
+func readName(msg []byte, off int) (string, int, error) {
+	var labels []string
+	end := -1
+	for jumps := 0; ; {
+		if off >= len(msg) {
+			return "", 0, errMalformedMessage
+		}
+		n := int(msg[off])
+		switch {
+		case n == 0:
+			if end < 0 {
+				end = off + 1
+			}
+			return strings.ToLower(strings.Join(labels, ".")), end, nil
+		case n&0xc0 == 0xc0:
+			if off+1 >= len(msg) || jumps > 64 {
+				return "", 0, errMalformedMessage
+			}
+			if end < 0 {
+				end = off + 2
+			}
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			jumps++
+		default:
+			if off+1+n > len(msg) {
+				return "", 0, errMalformedMessage
+			}
+			labels = append(labels, string(msg[off+1:off+1+n]))
+			off += 1 + n
+		}
+	}
+}
+
+// axfrRecords returns the owner names and the number of SOA records in an
+// AXFR response message.
+func axfrRecords(msg []byte) ([]string, int, error) {
+	if len(msg) < 12 {
+		return nil, 0, errMalformedMessage
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		return nil, 0, fmt.Errorf("transfer refused with rcode %d", rcode)
+	}
+
+	off := 12
+	for range binary.BigEndian.Uint16(msg[4:]) {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		off = next + 4
+	}
+
+	var names []string
+	soa := 0
+	for range binary.BigEndian.Uint16(msg[6:]) {
+		name, next, err := readName(msg, off)
+		if err != nil {
+			return nil, 0, err
+		}
+		if next+10 > len(msg) {
+			return nil, 0, errMalformedMessage
+		}
+		if binary.BigEndian.Uint16(msg[next:]) == dnsTypeSOA {
+			soa++
+		}
+		off = next + 10 + int(binary.BigEndian.Uint16(msg[next+8:]))
+		if off > len(msg) {
+			return nil, 0, errMalformedMessage
+		}
+		names = append(names, name)
+	}
+	return names, soa, nil
+}
+
+// soaNegativeTTL returns the lower of the TTL and the minimum field of the
+// SOA record in the answer section of msg.
+func soaNegativeTTL(msg []byte) (time.Duration, error) {
+	if len(msg) < 12 {
+		return 0, errMalformedMessage
+	}
+	if rcode := msg[3] & 0x0f; rcode != 0 {
+		return 0, fmt.Errorf("soa query failed with rcode %d", rcode)
+	}
+
+	off := 12
+	for range binary.BigEndian.Uint16(msg[4:]) {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return 0, err
+		}
+		off = next + 4
+	}
+	for range binary.BigEndian.Uint16(msg[6:]) {
+		_, next, err := readName(msg, off)
+		if err != nil {
+			return 0, err
+		}
+		if next+10 > len(msg) {
+			return 0, errMalformedMessage
+		}
+		end := next + 10 + int(binary.BigEndian.Uint16(msg[next+8:]))
+		if end > len(msg) {
+			return 0, errMalformedMessage
+		}
+		if binary.BigEndian.Uint16(msg[next:]) == dnsTypeSOA && end-next >= 10+22 {
+			ttl := binary.BigEndian.Uint32(msg[next+4:])
+			minimum := binary.BigEndian.Uint32(msg[end-4:])
+			return time.Duration(min(ttl, minimum)) * time.Second, nil
+		}
+		off = end
+	}
+	return 0, fmt.Errorf("no soa record")
+}
+
+// axfr attempts a zone transfer of zone from server and returns the names in
+// the zone.
+func axfr(server string, zone string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), axfrTimeout)
+	defer cancel()
+
+	conn, err := dialAXFR(ctx, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(axfrTimeout))
+
+	query := dnsQuery(uint16(time.Now().UnixNano()), zone, dnsTypeAXFR)
+	if _, err := conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	soa := 0
+	for soa < 2 {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return nil, err
+		}
+
+		found, n, err := axfrRecords(msg)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			return nil, fmt.Errorf("empty transfer")
+		}
+		names = append(names, found...)
+		soa += n
+	}
+
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// tryAXFR attempts a zone transfer of the zone of every input hostname and
+// domain against each of its nameservers, and resolves the names of zones
+// that could be transferred. The zones are found on m.workers goroutines.
+func (m *ipSubMap) tryAXFR(domains []string) {
+	if m.seen == nil {
+		m.seen = make(map[string]bool)
+	}
+	if m.zones == nil {
+		m.zones = newAuthoritative(nil)
+	}
+
+	hosts := slices.Concat(domains, m.inputNames)
+	found := make([]*zone, len(hosts))
+	var wg sync.WaitGroup
+	next := make(chan int)
+	for range max(m.workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				found[i] = m.zones.closestZone(hosts[i])
+			}
+		}()
+	}
+	for i := range hosts {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	var zones []*zone
+	for _, z := range found {
+		if z != nil && !slices.ContainsFunc(zones, func(o *zone) bool { return o.name == z.name }) {
+			zones = append(zones, z)
+		}
+	}
+
+	var transfers []transfer
+	for _, z := range zones {
+		if m.pastDeadline() {
+			break
+		}
+		if t := transferZone(z); t != nil {
+			transfers = append(transfers, *t)
+		}
+	}
+	m.transfers = append(m.transfers, transfers...)
+
+	m.resolveCandidates(expandAXFR, nil, func(queue func(name string, source string)) {
+		for _, t := range transfers {
+			for _, name := range t.names {
+				if !strings.HasPrefix(name, "*.") {
+					queue(name, t.zone)
+				}
+			}
+		}
+	})
+}
+
+func transferZone(z *zone) *transfer {
+	for _, ns := range z.servers {
+		if names, err := axfr(ns.addr, z.name); err == nil {
+			return &transfer{zone: z.name, server: ns.host, names: names}
+		}
+	}
+	return nil
+}
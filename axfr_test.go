@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func dnsName(name string) []byte {
+	var b []byte
+	for _, label := range strings.Split(name, ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	return append(b, 0)
+}
+
+// axfrMessage builds a length prefixed response to query with one record per
+// "TYPE name" entry, @ being a compressed pointer to the zone.
+func axfrMessage(query []byte, rcode byte, records ...string) []byte {
+	end := 12 + bytes.IndexByte(query[12:], 0) + 5
+	msg := append([]byte{}, query[:2]...)
+	msg = append(msg, 0x84, rcode, 0, 1)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(records)))
+	msg = append(msg, 0, 0, 0, 0)
+	msg = append(msg, query[12:end]...)
+	for _, record := range records {
+		rtype, name, _ := strings.Cut(record, " ")
+		if name == "@" {
+			msg = append(msg, 0xc0, 12)
+		} else {
+			msg = append(msg, dnsName(name)...)
+		}
+		if rtype == "SOA" {
+			msg = append(msg, 0, dnsTypeSOA, 0, 1, 0, 0, 0, 60, 0, 2, 0xc0, 12)
+		} else {
+			msg = append(msg, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 10, 0, 0, 1)
+		}
+	}
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(msg))), msg...)
+}
+
+func serveAXFR(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			var size [2]byte
+			io.ReadFull(conn, size[:])
+			query := make([]byte, binary.BigEndian.Uint16(size[:]))
+			io.ReadFull(conn, query)
+
+			zone, _, _ := readName(query, 12)
+			switch zone {
+			case "example.com":
+				conn.Write(axfrMessage(query, 0, "SOA @", "A www.example.com", "A Internal.Example.com"))
+				conn.Write(axfrMessage(query, 0, "A www.example.com", "SOA @"))
+			default:
+				conn.Write(axfrMessage(query, 5))
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestAXFR(t *testing.T) {
+	addr := serveAXFR(t)
+
+	names, err := axfr(addr, "example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"example.com", "internal.example.com", "www.example.com"}; !slices.Equal(names, want) {
+		t.Errorf("expected %q, got %q", want, names)
+	}
+
+	if _, err := axfr(addr, "example.org"); err == nil {
+		t.Error("expected error for refused transfer")
+	}
+}
+
+func TestReadName(t *testing.T) {
+	msg := append(make([]byte, 12), dnsName("example.com")...)
+	msg = append(msg, 3, 'w', 'w', 'w', 0xc0, 12)
+
+	tt := map[string]struct {
+		msg  []byte
+		off  int
+		want string
+		next int
+		err  bool
+	}{
+		"plain":      {msg: msg, off: 12, want: "example.com", next: 25},
+		"compressed": {msg: msg, off: 25, want: "www.example.com", next: 31},
+		"truncated":  {msg: msg[:30], off: 25, err: true},
+		"loop":       {msg: []byte{0xc0, 0}, err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, next, err := readName(tc.msg, tc.off)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if got != tc.want || next != tc.next {
+				t.Errorf("expected %q at %d, got %q at %d", tc.want, tc.next, got, next)
+			}
+		})
+	}
+}
+
+func TestIPSubMapTryAXFR(t *testing.T) {
+	addr := serveAXFR(t)
+	dial := dialAXFR
+	dialAXFR = func(ctx context.Context, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", addr)
+	}
+	var mu sync.Mutex
+	queried := make(map[string]int)
+	lookupNS = func(name string) ([]*net.NS, error) {
+		mu.Lock()
+		queried[name]++
+		mu.Unlock()
+		if name == "example.com" {
+			return []*net.NS{{Host: "ns1.example.com."}}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "ns1.example.com":
+			return []net.IP{net.ParseIP("192.0.2.53")}, nil
+		case "internal.example.com":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case "www.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	defer func() {
+		dialAXFR = dial
+		lookupNS = net.LookupNS
+		lookupIP = net.LookupIP
+	}()
+
+	report := newExpansionReport()
+	m := &ipSubMap{
+		ipv4:        true,
+		tryTransfer: true,
+		workers:     2,
+		seen:        make(map[string]bool),
+		public:      fragment{m: make(map[netip.Addr][]string)},
+		private:     fragment{m: make(map[netip.Addr][]string)},
+		reports:     []reportOutput{{name: "expanded", report: report}},
+	}
+	if err := m.enumerate(strings.NewReader("www.example.com\napi.example.com\nwww.example.org\n")); err == nil {
+		t.Fatal("expected error for the unresolved input")
+	}
+
+	if len(m.transfers) != 1 || m.transfers[0].zone != "example.com" || m.transfers[0].server != "ns1.example.com" {
+		t.Fatalf("expected one transfer of example.com, got %+v", m.transfers)
+	}
+	for name, n := range queried {
+		if n != 1 {
+			t.Errorf("expected the NS records of %s to be looked up once, got %d", name, n)
+		}
+	}
+	if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; !slices.Equal(got, []string{"internal.example.com"}) {
+		t.Errorf("expected the transferred name to be recorded, got %q", got)
+	}
+	out := &bytes.Buffer{}
+	report.write(out)
+	if want := "internal.example.com axfr example.com"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+type resolverBackend string
+
+const (
+	// backendSystem leaves the choice to the net package: the Go resolver
+	// unless the platform, GODEBUG=netdns or the system configuration (such
+	// as NSS modules other than files and dns) call for the C library.
+	backendSystem resolverBackend = "system"
+	// backendGo always uses the Go resolver, which reads /etc/resolv.conf
+	// and /etc/hosts itself and sends queries directly.
+	backendGo resolverBackend = "go"
+	// backendCgo always uses the C library resolver (getaddrinfo), with
+	// NSS modules, its search domain and address sorting rules.
+	backendCgo resolverBackend = "cgo"
+)
+
+func parseResolverBackend(s string) (resolverBackend, error) {
+	switch b := resolverBackend(s); b {
+	case backendSystem, backendGo, backendCgo:
+		return b, nil
+	default:
+		return "", fmt.Errorf("unknown resolver backend %q", s)
+	}
+}
+
+// useResolverBackend configures the net package for b. It must run before the
+// first lookup, as the net package reads its configuration once.
+func useResolverBackend(b resolverBackend) error {
+	switch b {
+	case backendGo:
+		net.DefaultResolver.PreferGo = true
+		return setGODEBUG("netdns", "go")
+	case backendCgo:
+		if !cgoResolver {
+			return fmt.Errorf("-resolver-backend cgo requires a build with cgo enabled and without the netgo tag")
+		}
+		return setGODEBUG("netdns", "cgo")
+	}
+	return nil
+}
+
+// setGODEBUG appends key=value to GODEBUG, taking precedence over an earlier
+// setting of key.
+func setGODEBUG(key, value string) error {
+	settings := os.Getenv("GODEBUG")
+	return os.Setenv("GODEBUG", strings.TrimPrefix(settings+","+key+"="+value, ","))
+}
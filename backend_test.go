@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestParseResolverBackend(t *testing.T) {
+	for _, s := range []string{"system", "go", "cgo"} {
+		if _, err := parseResolverBackend(s); err != nil {
+			t.Errorf("unexpected error for %q: %v", s, err)
+		}
+	}
+	if _, err := parseResolverBackend("libc"); err == nil {
+		t.Error("expected error for libc")
+	}
+}
+
+func TestUseResolverBackend(t *testing.T) {
+	defer func() { net.DefaultResolver.PreferGo = false }()
+
+	tt := map[string]struct {
+		backend  resolverBackend
+		godebug  string
+		preferGo bool
+		wantErr  bool
+	}{
+		"system": {backend: backendSystem, godebug: "http2client=0"},
+		"go":     {backend: backendGo, godebug: "http2client=0,netdns=go", preferGo: true},
+		"cgo":    {backend: backendCgo, godebug: "http2client=0,netdns=cgo", wantErr: !cgoResolver},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("GODEBUG", "http2client=0")
+			net.DefaultResolver.PreferGo = false
+
+			err := useResolverBackend(tc.backend)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := os.Getenv("GODEBUG"); got != tc.godebug {
+				t.Errorf("expected GODEBUG %q, got %q", tc.godebug, got)
+			}
+			if net.DefaultResolver.PreferGo != tc.preferGo {
+				t.Errorf("expected PreferGo %v", tc.preferGo)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// maxBenchErrorRate is the error rate above which a resolver is not
+// recommended.
+const maxBenchErrorRate = 0.02
+
+type benchOptions struct {
+	resolvers   string
+	file        string
+	sample      int
+	concurrency int
+	timeout     time.Duration
+}
+
+func (o *benchOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.resolvers, "resolvers", "", "File with one resolver address (ip or ip:port) per line")
+	fs.StringVar(&o.file, "file", "", "File with hostnames to sample, stdin when empty")
+	fs.IntVar(&o.sample, "sample", 200, "Number of distinct hostnames from the input resolved through each resolver")
+	fs.IntVar(&o.concurrency, "concurrency", 10, "Number of lookups in flight per resolver")
+	fs.DurationVar(&o.timeout, "timeout", 2*time.Second, "Timeout of each lookup")
+}
+
+type benchResult struct {
+	resolver  string
+	lookups   int
+	errors    int
+	elapsed   time.Duration
+	latencies []time.Duration
+}
+
+func (r *benchResult) errorRate() float64 {
+	if r.lookups == 0 {
+		return 0
+	}
+	return float64(r.errors) / float64(r.lookups)
+}
+
+func (r *benchResult) throughput() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.lookups) / r.elapsed.Seconds()
+}
+
+// percentile returns the latency below which p of the answered lookups were.
+func (r *benchResult) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	return r.latencies[int(p*float64(len(r.latencies)-1))]
+}
+
+func resolverAddress(s string) (string, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		return net.JoinHostPort(s, "53"), nil
+	}
+	host, _, err := net.SplitHostPort(s)
+	if err != nil || net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid resolver %q", s)
+	}
+	return s, nil
+}
+
+// benchResolver resolves hosts through server with concurrency lookups in
+// flight. Names that do not exist are answers, not errors.
+func benchResolver(server string, hosts []string, concurrency int, timeout time.Duration) *benchResult {
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+
+	result := &benchResult{resolver: server, lookups: len(hosts)}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	jobs := make(chan string)
+	started := time.Now()
+	for range max(concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for host := range jobs {
+				ctx, cancel := context.WithTimeout(context.Background(), timeout)
+				lookupStarted := time.Now()
+				_, err := r.LookupIPAddr(ctx, host)
+				took := time.Since(lookupStarted)
+				cancel()
+
+				var dnsErr *net.DNSError
+				failed := err != nil && !(errors.As(err, &dnsErr) && dnsErr.IsNotFound)
+				mu.Lock()
+				if failed {
+					result.errors++
+				} else {
+					result.latencies = append(result.latencies, took)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, host := range hosts {
+		jobs <- host
+	}
+	close(jobs)
+	wg.Wait()
+	result.elapsed = time.Since(started)
+	slices.Sort(result.latencies)
+	return result
+}
+
+// rankResolvers orders the results by p90 latency, resolvers above the error
+// rate limit last.
+func rankResolvers(results []*benchResult) {
+	slices.SortFunc(results, func(a, b *benchResult) int {
+		aFailing, bFailing := a.errorRate() > maxBenchErrorRate, b.errorRate() > maxBenchErrorRate
+		if aFailing != bFailing {
+			if aFailing {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Or(cmp.Compare(a.percentile(0.9), b.percentile(0.9)), strings.Compare(a.resolver, b.resolver))
+	})
+}
+
+func writeBench(out io.Writer, results []*benchResult, concurrency int) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "RESOLVER\tLOOKUPS\tERRORS\tQPS\tP50\tP90\tP99\n")
+	var recommended []string
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%.1f\t%s\t%s\t%s\n", r.resolver, r.lookups, 100*r.errorRate(), r.throughput(),
+			r.percentile(0.5).Round(time.Millisecond), r.percentile(0.9).Round(time.Millisecond), r.percentile(0.99).Round(time.Millisecond))
+		if r.errorRate() <= maxBenchErrorRate {
+			recommended = append(recommended, r.resolver)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(recommended) == 0 {
+		_, err := fmt.Fprintf(out, "\nNo resolver answered %.0f%% of the lookups. Try a lower -concurrency.\n", 100*(1-maxBenchErrorRate))
+		return err
+	}
+	_, err := fmt.Fprintf(out, "\nRecommended: use %s as the system resolver and -workers %d.\n", strings.Join(recommended[:min(len(recommended), 3)], ", "), concurrency)
+	return err
+}
+
+func loadResolvers(path string) ([]string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	var servers []string
+	err = scanLines(in, func(n int, line string) error {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return nil
+		}
+		server, err := resolverAddress(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		servers = append(servers, server)
+		return nil
+	})
+	return servers, err
+}
+
+func runBench(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts benchOptions
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ipsubmap bench -resolvers resolvers.txt [flags]\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.resolvers == "" || opts.sample < 1 || opts.concurrency < 1 || opts.timeout <= 0 {
+		fs.Usage()
+		return 2
+	}
+
+	servers, err := loadResolvers(opts.resolvers)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to load resolvers: %v\n", err)
+		return 1
+	}
+
+	in := stdin
+	if opts.file != "" {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to open input file: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	var hosts []string
+	err = scanLines(in, func(_ int, line string) error {
+		if len(hosts) < opts.sample && validHostname(line) && !slices.Contains(hosts, line) {
+			hosts = append(hosts, line)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read input: %v\n", err)
+		return 1
+	}
+	if len(servers) == 0 || len(hosts) == 0 {
+		fmt.Fprintln(stderr, "need at least one resolver and one hostname")
+		return 1
+	}
+
+	var results []*benchResult
+	for _, server := range servers {
+		results = append(results, benchResolver(server, hosts, opts.concurrency, opts.timeout))
+	}
+	rankResolvers(results)
+	if err := writeBench(stdout, results, opts.concurrency); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolverAddress(t *testing.T) {
+	tt := map[string]struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		"ip":        {in: "1.1.1.1", want: "1.1.1.1:53"},
+		"ip port":   {in: "1.1.1.1:5353", want: "1.1.1.1:5353"},
+		"ipv6":      {in: "2606:4700::1111", want: "[2606:4700::1111]:53"},
+		"ipv6 port": {in: "[2606:4700::1111]:53", want: "[2606:4700::1111]:53"},
+		"hostname":  {in: "dns.example.com", wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := resolverAddress(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRankResolvers(t *testing.T) {
+	results := []*benchResult{
+		{resolver: "failing", lookups: 10, errors: 5, latencies: []time.Duration{time.Millisecond}},
+		{resolver: "slow", lookups: 10, latencies: []time.Duration{50 * time.Millisecond}},
+		{resolver: "fast", lookups: 10, latencies: []time.Duration{5 * time.Millisecond}},
+	}
+	rankResolvers(results)
+
+	var got []string
+	for _, r := range results {
+		got = append(got, r.resolver)
+	}
+	if want := "fast slow failing"; strings.Join(got, " ") != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestRunBench(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer conn.Close()
+	go serveDNS(conn, net.ParseIP("10.0.0.1"))
+
+	resolvers := filepath.Join(t.TempDir(), "resolvers.txt")
+	if err := os.WriteFile(resolvers, []byte("# local\n"+conn.LocalAddr().String()+"\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("a.example.com\nb.example.com\na.example.com\nnot a host\n")
+	if code := runBench([]string{"-resolvers", resolvers, "-concurrency", "2"}, stdin, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+	for _, want := range []string{"RESOLVER", conn.LocalAddr().String() + "  2 ", "Recommended: use " + conn.LocalAddr().String()} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+const expandBrute = "brute"
+
+type bruteForce struct {
+	words   []string
+	domains []string
+}
+
+func parseDomains(s string) ([]string, error) {
+	var domains []string
+	for _, domain := range strings.Split(s, ",") {
+		domain = strings.ToLower(strings.Trim(strings.TrimSpace(domain), "."))
+		labels := strings.Split(domain, ".")
+		for _, label := range labels {
+			if !labelPattern.MatchString(label) {
+				return nil, fmt.Errorf("invalid domain %q", domain)
+			}
+		}
+		domains = append(domains, domain)
+	}
+	return domains, nil
+}
+
+// probeIP resolves the random names of wildcard probes. It bypasses the
+// negative cache, the latency stats and the sharing of lookups, which the
+// probes would only fill with made up names.
+var probeIP = func(host string) ([]net.IP, error) {
+	return lookupIP(host)
+}
+
+// wildcardIPs resolves random names under domain, returning the addresses a
+// wildcard record answers with, if any.
+func (m *ipSubMap) wildcardIPs(domain string) map[string]bool {
+	ips := make(map[string]bool)
+	for range 2 {
+		b := make([]byte, 8)
+		rand.Read(b)
+		answers, err := probeIP(hex.EncodeToString(b) + "." + domain)
+		if err != nil {
+			continue
+		}
+		for _, ip := range answers {
+			ips[formatIP(addrFromIP(ip))] = true
+		}
+	}
+	return ips
+}
+
+// wildcards probes domains for wildcard records on m.workers goroutines,
+// returning the wildcard addresses of each domain.
+func (m *ipSubMap) wildcards(domains []string) map[string]map[string]bool {
+	wildcards := make(map[string]map[string]bool)
+	if m.pastDeadline() {
+		return wildcards
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	next := make(chan string)
+	for range max(m.workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range next {
+				ips := m.wildcardIPs(domain)
+				mu.Lock()
+				wildcards[domain] = ips
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, domain := range domains {
+		next <- domain
+	}
+	close(next)
+	wg.Wait()
+	return wildcards
+}
+
+func onlyWildcard(ips []net.IP, wildcard map[string]bool) bool {
+	if len(wildcard) == 0 {
+		return false
+	}
+	for _, ip := range ips {
+		if !wildcard[formatIP(addrFromIP(ip))] {
+			return false
+		}
+	}
+	return true
+}
+
+// bruteForce resolves word.domain for every word and domain, keeping the names
+// that resolve to something other than the domain's wildcard addresses.
+func (m *ipSubMap) bruteForce() {
+	if m.seen == nil {
+		m.seen = make(map[string]bool)
+	}
+
+	m.resolveCandidates(expandBrute, m.wildcards(m.brute.domains), func(queue func(name string, source string)) {
+		for _, domain := range m.brute.domains {
+			for _, word := range m.brute.words {
+				queue(word+"."+domain, domain)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDomains(t *testing.T) {
+	tt := map[string]struct {
+		in      string
+		want    []string
+		wantErr bool
+	}{
+		"single":   {in: "example.com", want: []string{"example.com"}},
+		"multiple": {in: "Example.com., corp.example.org", want: []string{"example.com", "corp.example.org"}},
+		"wildcard": {in: "*.example.com", wantErr: true},
+		"empty":    {in: "example.com,", wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseDomains(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("expected error %v, got %v", tc.wantErr, err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIPSubMapBruteForce(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch {
+		case host == "www.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		case host == "api.example.com":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case host == "www.wild.example.org":
+			return []net.IP{net.ParseIP("10.0.0.2")}, nil
+		case strings.HasSuffix(host, ".wild.example.org"):
+			return []net.IP{net.ParseIP("10.0.0.9")}, nil
+		default:
+			return nil, fmt.Errorf("no such host")
+		}
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	report := newExpansionReport()
+	unresolved := newUnresolvedReport()
+	m := &ipSubMap{
+		ipv4:    true,
+		brute:   &bruteForce{words: []string{"www", "api", "mail"}, domains: []string{"example.com", "wild.example.org"}},
+		seen:    make(map[string]bool),
+		public:  fragment{m: make(map[netip.Addr][]string)},
+		private: fragment{m: make(map[netip.Addr][]string)},
+		reports: []reportOutput{
+			{name: "expanded", report: report},
+			{name: "unresolved", report: unresolved},
+		},
+	}
+	if err := m.enumerate(strings.NewReader("www.example.com\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; !slices.Equal(got, []string{"api.example.com"}) {
+		t.Errorf("expected the brute forced name to be recorded, got %q", got)
+	}
+	if got := m.private.m[netip.MustParseAddr("10.0.0.2")]; !slices.Equal(got, []string{"www.wild.example.org"}) {
+		t.Errorf("expected the name outside the wildcard to be recorded, got %q", got)
+	}
+	if got, ok := m.private.m[netip.MustParseAddr("10.0.0.9")]; ok {
+		t.Errorf("expected wildcard answers to be dropped, got %q", got)
+	}
+
+	out := &bytes.Buffer{}
+	report.write(out)
+	want := "api.example.com brute example.com\nwww.wild.example.org brute wild.example.org"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+	if len(unresolved.names) != 0 {
+		t.Errorf("expected unresolved candidates not to be reported, got %v", unresolved.names)
+	}
+}
+
+func TestIPSubMapBruteForce_deadline(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasPrefix(host, "w") {
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	var words []string
+	for i := range 20 {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	remaining := &strings.Builder{}
+	m := &ipSubMap{
+		ipv4:      true,
+		brute:     &bruteForce{words: words, domains: []string{"example.com"}},
+		seen:      make(map[string]bool),
+		deadline:  time.Now().Add(100 * time.Millisecond),
+		remaining: remaining,
+		private:   fragment{m: make(map[netip.Addr][]string)},
+	}
+	if err := m.enumerate(strings.NewReader("")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !m.expired {
+		t.Fatal("expected the deadline to expire")
+	}
+	resolved := len(m.private.m[netip.MustParseAddr("10.0.0.1")])
+	if resolved == 0 || m.unprocessed == 0 || resolved+m.unprocessed != len(words) {
+		t.Fatalf("expected %d candidates, got %d resolved and %d unprocessed", len(words), resolved, m.unprocessed)
+	}
+	var want string
+	for _, word := range words[resolved:] {
+		want += word + ".example.com\n"
+	}
+	if remaining.String() != want {
+		t.Errorf("expected remaining %q, got %q", want, remaining.String())
+	}
+}
+
+func TestIPSubMapWildcardIPs_probe(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		t.Errorf("expected the probe of %s not to go through the lookup chain", host)
+		return nil, fmt.Errorf("no such host")
+	}
+	probes := 0
+	probeIP = func(host string) ([]net.IP, error) {
+		probes++
+		return []net.IP{net.ParseIP("10.0.0.9")}, nil
+	}
+	defer func() {
+		lookupIP = net.LookupIP
+		probeIP = func(host string) ([]net.IP, error) { return lookupIP(host) }
+	}()
+
+	m := &ipSubMap{latency: newLatencyStats()}
+	if got := m.wildcardIPs("example.com"); !got["10.0.0.9"] || len(got) != 1 {
+		t.Errorf("expected the wildcard address, got %v", got)
+	}
+	if probes != 2 {
+		t.Errorf("expected 2 probes, got %d", probes)
+	}
+	if len(m.latency.zones) != 0 {
+		t.Errorf("expected probes not to be counted in the latency stats, got %v", m.latency.zones)
+	}
+}
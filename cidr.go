@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// aggregatePrefixes returns the smallest set of prefixes covering exactly the
+// given addresses, merging sibling prefixes bottom up.
+func aggregatePrefixes(addrs []netip.Addr) []netip.Prefix {
+	sorted := slices.Clone(addrs)
+	slices.SortFunc(sorted, netip.Addr.Compare)
+	sorted = slices.Compact(sorted)
+
+	var stack []netip.Prefix
+	for _, addr := range sorted {
+		stack = append(stack, netip.PrefixFrom(addr, addr.BitLen()))
+		for len(stack) >= 2 {
+			a, b := stack[len(stack)-2], stack[len(stack)-1]
+			if a.Bits() != b.Bits() || a.Bits() == 0 || a.Addr().Is4() != b.Addr().Is4() {
+				break
+			}
+			parent, _ := a.Addr().Prefix(a.Bits() - 1)
+			if parent.Addr() != a.Addr() || !parent.Contains(b.Addr()) {
+				break
+			}
+			stack = append(stack[:len(stack)-2], parent)
+		}
+	}
+
+	return stack
+}
+
+type cidrReport struct {
+	hosts map[netip.Addr][]string
+}
+
+func newCIDRReport() *cidrReport {
+	return &cidrReport{hosts: make(map[netip.Addr][]string)}
+}
+
+func (r *cidrReport) add(class string, ip string, subdomain string) {
+	if class != classPublic {
+		return
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	if !slices.Contains(r.hosts[addr], subdomain) {
+		r.hosts[addr] = append(r.hosts[addr], subdomain)
+	}
+}
+
+func (r *cidrReport) write(out io.Writer) error {
+	addrs := make([]netip.Addr, 0, len(r.hosts))
+	for addr := range r.hosts {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+
+	var lines []string
+	i := 0
+	for _, prefix := range aggregatePrefixes(addrs) {
+		ips := 0
+		subdomains := make(map[string]struct{})
+		for ; i < len(addrs) && prefix.Contains(addrs[i]); i++ {
+			ips++
+			for _, host := range r.hosts[addrs[i]] {
+				subdomains[host] = struct{}{}
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %d", prefix, ips, len(subdomains)))
+	}
+
+	_, err := out.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestAggregatePrefixes(t *testing.T) {
+	tt := map[string]struct {
+		addrs []string
+		want  []string
+	}{
+		"single": {
+			addrs: []string{"1.1.1.1"},
+			want:  []string{"1.1.1.1/32"},
+		},
+		"pair": {
+			addrs: []string{"10.0.0.1", "10.0.0.0"},
+			want:  []string{"10.0.0.0/31"},
+		},
+		"unaligned pair": {
+			addrs: []string{"10.0.0.1", "10.0.0.2"},
+			want:  []string{"10.0.0.1/32", "10.0.0.2/32"},
+		},
+		"full block with duplicates": {
+			addrs: []string{"192.0.2.3", "192.0.2.0", "192.0.2.2", "192.0.2.1", "192.0.2.1", "192.0.2.4"},
+			want:  []string{"192.0.2.0/30", "192.0.2.4/32"},
+		},
+		"mixed families": {
+			addrs: []string{"2001:db8::1", "2001:db8::", "0.0.0.1", "0.0.0.0"},
+			want:  []string{"0.0.0.0/31", "2001:db8::/127"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var addrs []netip.Addr
+			for _, a := range tc.addrs {
+				addrs = append(addrs, netip.MustParseAddr(a))
+			}
+
+			var got []string
+			for _, p := range aggregatePrefixes(addrs) {
+				got = append(got, p.String())
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestCIDRReport(t *testing.T) {
+	r := newCIDRReport()
+	r.add(classPublic, "192.0.2.0", "a.example.com")
+	r.add(classPublic, "192.0.2.1", "a.example.com")
+	r.add(classPublic, "192.0.2.1", "b.example.com")
+	r.add(classPublic, "198.51.100.7", "c.example.com")
+	r.add(classPrivate, "10.0.0.1", "d.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "192.0.2.0/31 2 2\n198.51.100.7/32 1 1"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
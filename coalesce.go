@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"sync"
+)
+
+// lookupCacheSize bounds the number of answers a lookupGroup keeps.
+const lookupCacheSize = 1 << 20
+
+// lookupGroup lets concurrent lookups of the same hostname share a single
+// query. With keep, answers and nonexistent names are also kept for the rest
+// of the run, up to lookupCacheSize of them, so that those hostnames are
+// queried once. Other errors are never kept.
+type lookupGroup struct {
+	next func(host string) ([]net.IP, error)
+	keep bool
+
+	mu     sync.Mutex
+	calls  map[string]*lookupCall
+	kept   int
+	shared int
+}
+
+type lookupCall struct {
+	done chan struct{}
+	ips  []net.IP
+	err  error
+}
+
+func newLookupGroup(next func(host string) ([]net.IP, error), keep bool) *lookupGroup {
+	return &lookupGroup{next: next, keep: keep, calls: make(map[string]*lookupCall)}
+}
+
+func (g *lookupGroup) lookupIP(host string) ([]net.IP, error) {
+	g.mu.Lock()
+	if c, ok := g.calls[host]; ok {
+		g.shared++
+		g.mu.Unlock()
+		<-c.done
+		return slices.Clone(c.ips), c.err
+	}
+	c := &lookupCall{done: make(chan struct{})}
+	g.calls[host] = c
+	g.mu.Unlock()
+
+	c.ips, c.err = g.next(host)
+	var dnsErr *net.DNSError
+	keep := g.keep && (c.err == nil || errors.As(c.err, &dnsErr) && dnsErr.IsNotFound)
+	g.mu.Lock()
+	if keep && g.kept < lookupCacheSize {
+		g.kept++
+	} else {
+		delete(g.calls, host)
+	}
+	g.mu.Unlock()
+	close(c.done)
+	return slices.Clone(c.ips), c.err
+}
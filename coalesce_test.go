@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLookupGroup_concurrent(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	g := newLookupGroup(func(host string) ([]net.IP, error) {
+		calls.Add(1)
+		<-release
+		return []net.IP{net.ParseIP("192.0.2.1")}, nil
+	}, true)
+
+	var wg sync.WaitGroup
+	results := make([][]net.IP, 8)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = g.lookupIP("a.example.com")
+		}()
+	}
+	for {
+		g.mu.Lock()
+		waiting := g.shared
+		g.mu.Unlock()
+		if waiting == len(results)-1 {
+			break
+		}
+		runtime.Gosched()
+	}
+	close(release)
+	wg.Wait()
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected 1 lookup, got %d", got)
+	}
+	for i, ips := range results {
+		if len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Errorf("result %d: unexpected %v", i, ips)
+		}
+	}
+}
+
+func TestLookupGroup_keep(t *testing.T) {
+	tt := map[string]struct {
+		keep  bool
+		ips   []net.IP
+		err   error
+		calls int
+	}{
+		"answer":         {keep: true, ips: []net.IP{net.ParseIP("192.0.2.1")}, calls: 1},
+		"not found":      {keep: true, err: &net.DNSError{Err: "no such host", IsNotFound: true}, calls: 1},
+		"timeout":        {keep: true, err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}, calls: 3},
+		"other error":    {keep: true, err: errors.New("server misbehaving"), calls: 3},
+		"in flight only": {ips: []net.IP{net.ParseIP("192.0.2.1")}, calls: 3},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			g := newLookupGroup(func(host string) ([]net.IP, error) {
+				calls++
+				return slices.Clone(tc.ips), tc.err
+			}, tc.keep)
+			for range 3 {
+				ips, err := g.lookupIP("a.example.com")
+				if !errors.Is(err, tc.err) || len(ips) != len(tc.ips) {
+					t.Fatalf("unexpected result %v %v", ips, err)
+				}
+				if len(ips) > 0 {
+					ips[0] = net.ParseIP("198.51.100.1")
+				}
+			}
+			if calls != tc.calls {
+				t.Errorf("expected %d lookups, got %d", tc.calls, calls)
+			}
+			if ips, _ := g.lookupIP("a.example.com"); len(ips) > 0 && !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+				t.Errorf("kept answer was modified: %v", ips)
+			}
+		})
+	}
+}
+
+func TestIPSubMapEnumerate_lookupOnce(t *testing.T) {
+	calls := make(map[string]int)
+	g := newLookupGroup(func(host string) ([]net.IP, error) {
+		calls[host]++
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}, true)
+	lookupIP = g.lookupIP
+	defer func() { lookupIP = net.LookupIP }()
+
+	m := &ipSubMap{
+		ipv4:    true,
+		workers: 1,
+		private: fragment{m: make(map[netip.Addr][]string)},
+	}
+	if err := m.enumerate(strings.NewReader("a.example.com\nb.example.com\na.example.com\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls["a.example.com"] != 1 || calls["b.example.com"] != 1 {
+		t.Errorf("expected each name to be queried once, got %v", calls)
+	}
+}
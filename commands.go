@@ -0,0 +1,377 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+type command struct {
+	name     string
+	usage    string
+	register func(fs *flag.FlagSet)
+	run      func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{
+		name:  "resolve",
+		usage: "Resolve subdomains and write them grouped by ip address class (the default)",
+		register: func(fs *flag.FlagSet) {
+			new(Flags).register(fs)
+		},
+		run: func(args []string, _ io.Reader, _, _ io.Writer) int {
+			runResolve(args)
+			return 0
+		},
+	},
+	{
+		name:  "discover",
+		usage: "Find names under -domain in certificate transparency logs and resolve them like resolve",
+		register: func(fs *flag.FlagSet) {
+			f := new(Flags)
+			f.register(fs)
+			f.registerDiscover(fs)
+		},
+		run: func(args []string, _ io.Reader, _, _ io.Writer) int {
+			runDiscover(args)
+			return 0
+		},
+	},
+	{
+		name:     "classify",
+		usage:    "Print the class of each ip address read from a file or stdin",
+		register: func(fs *flag.FlagSet) { new(classifyOptions).register(fs) },
+		run:      runClassify,
+	},
+	{
+		name:     "diff",
+		usage:    "Compare two combined outputs (-out) and print added and removed results",
+		register: func(fs *flag.FlagSet) { new(diffOptions).register(fs) },
+		run:      runDiff,
+	},
+	{
+		name:     "merge",
+		usage:    "Merge output files of the same kind into one",
+		register: func(fs *flag.FlagSet) { new(mergeOptions).register(fs) },
+		run:      runMerge,
+	},
+	{
+		name:     "bench",
+		usage:    "Measure the throughput, latency and error rate of resolvers on a sample of the input",
+		register: func(fs *flag.FlagSet) { new(benchOptions).register(fs) },
+		run:      runBench,
+	},
+	{
+		name:     "version",
+		usage:    "Print version and build information",
+		register: func(fs *flag.FlagSet) {},
+		run: func(_ []string, _ io.Reader, stdout, _ io.Writer) int {
+			fmt.Fprintln(stdout, readBuildDetails())
+			return 0
+		},
+	},
+}
+
+func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func dispatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runResolve(args)
+		return 0
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+
+	switch args[0] {
+	case "help":
+		printCommands(stdout)
+		return 0
+	case "completion":
+		return runCompletion(args[1:], stdin, stdout, stderr)
+	}
+
+	fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+	printCommands(stderr)
+	return 2
+}
+
+func printCommands(out io.Writer) {
+	fmt.Fprintf(out, "Usage: ipsubmap [command] [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(out, "  %-10s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintf(out, "  %-10s %s\n", "completion", "Print a shell completion script for bash, zsh or fish")
+	fmt.Fprintf(out, "\nRun ipsubmap <command> -h for the flags of a command.\n")
+}
+
+type classifyOptions struct {
+	file string
+}
+
+func (o *classifyOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.file, "file", "", "File with one ip address per line, stdin when empty. Anything after the first field is ignored")
+}
+
+func runClassify(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("classify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts classifyOptions
+	opts.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	in := stdin
+	if opts.file != "" {
+		f, err := os.Open(opts.file)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to open input file: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+
+	status := 0
+	err := scanLines(in, func(n int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return nil
+		}
+		field := fields[0]
+		ip, err := netip.ParseAddr(field)
+		if err != nil {
+			fmt.Fprintf(stderr, "line %d: invalid ip address %q\n", n, field)
+			status = 1
+			return nil
+		}
+		_, err = fmt.Fprintf(stdout, "%s %s\n", field, classify(ip.Unmap()))
+		return err
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to read input: %v\n", err)
+		return 1
+	}
+	return status
+}
+
+type diffOptions struct {
+	sort       string
+	ipv6Format string
+}
+
+func (o *diffOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.sort, "sort", string(sortNumeric), "Sort mode for ip addresses: lexical or numeric")
+	fs.StringVar(&o.ipv6Format, "ipv6-format", string(ipv6Canonical), "Representation ipv6 addresses are compared and printed in: canonical or expanded")
+}
+
+func runDiff(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts diffOptions
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ipsubmap diff [flags] old.txt new.txt\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	mode, err := parseSortMode(opts.sort)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	format, err := parseIPv6Format(opts.ipv6Format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	outputIPv6Format = format
+
+	var reports [2]*combinedReport
+	for i, path := range fs.Args() {
+		reports[i] = newCombinedReport(mode)
+		if err := loadFile(path, reports[i]); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 2
+		}
+	}
+
+	lines := diffCombined(reports[0], reports[1], ipSortMode(mode))
+	for _, line := range lines {
+		fmt.Fprintln(stdout, line)
+	}
+	if len(lines) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func diffCombined(old, new *combinedReport, mode sortMode) []string {
+	var ips []string
+	for ip := range old.ips {
+		ips = append(ips, ip)
+	}
+	for ip := range new.ips {
+		if _, ok := old.ips[ip]; !ok {
+			ips = append(ips, ip)
+		}
+	}
+	sortIPs(ips, mode)
+
+	var lines []string
+	for _, ip := range ips {
+		var before, after combinedEntry
+		if e, ok := old.ips[ip]; ok {
+			before = *e
+		}
+		if e, ok := new.ips[ip]; ok {
+			after = *e
+		}
+
+		var removed, added []string
+		for _, subdomain := range before.subdomains {
+			if !slices.Contains(after.subdomains, subdomain) {
+				removed = append(removed, subdomain)
+			}
+		}
+		for _, subdomain := range after.subdomains {
+			if !slices.Contains(before.subdomains, subdomain) {
+				added = append(added, subdomain)
+			}
+		}
+		slices.Sort(removed)
+		slices.Sort(added)
+
+		if len(removed) > 0 {
+			lines = append(lines, fmt.Sprintf("- %s %s %s", ip, before.class, strings.Join(removed, ",")))
+		}
+		if len(added) > 0 {
+			lines = append(lines, fmt.Sprintf("+ %s %s %s", ip, after.class, strings.Join(added, ",")))
+		}
+	}
+	return lines
+}
+
+type mergeOptions struct {
+	output     string
+	force      bool
+	combined   bool
+	sort       string
+	ipSep      string
+	subSep     string
+	ipv6Format string
+}
+
+func (o *mergeOptions) register(fs *flag.FlagSet) {
+	fs.StringVar(&o.output, "o", "", "Output file")
+	fs.BoolVar(&o.force, "force", false, "Overwrite the output file if it exists")
+	fs.BoolVar(&o.combined, "combined", false, "Inputs are combined outputs (-out) instead of class outputs")
+	fs.StringVar(&o.sort, "sort", string(sortNumeric), "Sort mode: lexical, numeric or subdomain")
+	fs.StringVar(&o.ipSep, "ip-sep", " ", `Separator between the ip address and its subdomains in class outputs. \t is a tab`)
+	fs.StringVar(&o.subSep, "sub-sep", ",", "Separator between subdomains in class outputs")
+	fs.StringVar(&o.ipv6Format, "ipv6-format", string(ipv6Canonical), "Representation ipv6 addresses are merged and written in: canonical or expanded")
+}
+
+func runMerge(args []string, _ io.Reader, _, stderr io.Writer) int {
+	fs := flag.NewFlagSet("merge", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var opts mergeOptions
+	opts.register(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: ipsubmap merge -o merged.txt [flags] a.txt b.txt...\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if opts.output == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+	mode, err := parseSortMode(opts.sort)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	opts.ipSep, opts.subSep = unescape(opts.ipSep), unescape(opts.subSep)
+	if err := validateSeparators(opts.ipSep, opts.subSep); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	format, err := parseIPv6Format(opts.ipv6Format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	outputIPv6Format = format
+	if !opts.force && opts.output != stdoutPath {
+		if err := refuseExisting(opts.output); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	var l loader
+	var write func(out io.Writer) error
+	if opts.combined {
+		r := newCombinedReport(mode)
+		l, write = r, r.write
+	} else {
+		f := &fragment{m: make(map[netip.Addr][]string), sortMode: mode, ipSep: opts.ipSep, subSep: opts.subSep}
+		l, write = f, func(out io.Writer) error { return f.writeTo(f.writerSink(out)) }
+	}
+	for _, path := range fs.Args() {
+		if err := loadFile(path, l); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+
+	out, err := createAtomic(opts.output)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to create output file: %v\n", err)
+		return 1
+	}
+	if err := write(out); err != nil {
+		out.Abort()
+		fmt.Fprintf(stderr, "failed to write output file: %v\n", err)
+		return 1
+	}
+	if err := out.Commit(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func loadFile(path string, l loader) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := l.load(in); err != nil {
+		return fmt.Errorf("failed to load %q: %v", path, err)
+	}
+	return nil
+}
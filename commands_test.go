@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDispatch_unknown(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := dispatch([]string{"watch"}, nil, stdout, stderr); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "watch"`) {
+		t.Errorf("unexpected stderr %q", stderr.String())
+	}
+}
+
+func TestRunClassify(t *testing.T) {
+	stdin := strings.NewReader("10.0.0.1\n8.8.8.8 dns.google\n \t\n::1\nnot-an-ip\n")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	if code := dispatch([]string{"classify"}, stdin, stdout, stderr); code != 1 {
+		t.Errorf("expected exit code 1 for the invalid line, got %d", code)
+	}
+
+	want := "10.0.0.1 private\n8.8.8.8 public\n::1 loopback\n"
+	if stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+	if !strings.Contains(stderr.String(), `line 5: invalid ip address "not-an-ip"`) {
+		t.Errorf("unexpected stderr %q", stderr.String())
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	new := filepath.Join(dir, "new.txt")
+	os.WriteFile(old, []byte("1.1.1.1 public a.example.com,b.example.com\n10.0.0.1 private dev.example.com"), 0o644)
+	os.WriteFile(new, []byte("1.1.1.1 public a.example.com,c.example.com\n2.2.2.2 public d.example.com"), 0o644)
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := dispatch([]string{"diff", old, new}, nil, stdout, stderr); code != 1 {
+		t.Fatalf("expected exit code 1, got %d: %s", code, stderr.String())
+	}
+
+	want := "- 1.1.1.1 public b.example.com\n" +
+		"+ 1.1.1.1 public c.example.com\n" +
+		"+ 2.2.2.2 public d.example.com\n" +
+		"- 10.0.0.1 private dev.example.com\n"
+	if stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+
+	stdout.Reset()
+	if code := dispatch([]string{"diff", old, old}, nil, stdout, stderr); code != 0 || stdout.Len() != 0 {
+		t.Errorf("expected no differences, got %d %q", code, stdout.String())
+	}
+}
+
+func TestRunMerge(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	out := filepath.Join(dir, "merged.txt")
+	os.WriteFile(a, []byte("1.1.1.1 a.example.com\n2.2.2.2 b.example.com"), 0o644)
+	os.WriteFile(b, []byte("1.1.1.1 a.example.com,c.example.com"), 0o644)
+
+	stderr := &bytes.Buffer{}
+	if code := dispatch([]string{"merge", "-o", out, a, b}, nil, nil, stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+
+	got, _ := os.ReadFile(out)
+	want := "1.1.1.1 a.example.com,c.example.com\n2.2.2.2 b.example.com"
+	if string(got) != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	stderr.Reset()
+	if code := dispatch([]string{"merge", "-o", out, b}, nil, nil, stderr); code != 1 || !strings.Contains(stderr.String(), "already exists") {
+		t.Errorf("expected an existing output to be refused, got %d: %s", code, stderr.String())
+	}
+	if code := dispatch([]string{"merge", "-force", "-o", out, b}, nil, nil, stderr); code != 0 {
+		t.Fatalf("expected exit code 0 with -force, got %d: %s", code, stderr.String())
+	}
+	if got, _ := os.ReadFile(out); string(got) != "1.1.1.1 a.example.com,c.example.com" {
+		t.Errorf("expected the output to be overwritten, got %q", got)
+	}
+}
+
+func TestRunMerge_numeric(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	out := filepath.Join(dir, "merged.txt")
+	os.WriteFile(a, []byte("10.0.0.1 a.example.com\n9.0.0.1 b.example.com"), 0o644)
+
+	stderr := &bytes.Buffer{}
+	if code := dispatch([]string{"merge", "-o", out, a}, nil, nil, stderr); code != 0 {
+		t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+	}
+	want := "9.0.0.1 b.example.com\n10.0.0.1 a.example.com"
+	if got, _ := os.ReadFile(out); string(got) != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var completionValues = map[string][]string{
+	"fail-on":      classes,
+	"sort":         {string(sortLexical), string(sortNumeric), string(sortSubdomain)},
+	"format":       {string(formatList), string(formatHosts), string(formatAnsible), string(formatAnsibleYAML), string(formatZone), string(formatProto), string(formatParquet), string(formatMsgpack), string(formatSQLite), string(formatJSON), string(formatCSV)},
+	"log-format":   {logFormatText, logFormatJSON},
+	"log-level":    {"debug", "info", "warn", "error"},
+	"input-format": {string(inputText), string(inputMassdns), string(inputDnsx), string(inputAmass), string(inputCSV)},
+	"ipv6-format":  {string(ipv6Canonical), string(ipv6Expanded)},
+	"mode":         {string(modeResolve), string(modeReverse), string(modeClassify)},
+}
+
+type completionFlag struct {
+	name   string
+	usage  string
+	bool   bool
+	values []string
+}
+
+type completionCommand struct {
+	name  string
+	usage string
+	flags []completionFlag
+}
+
+func completionCommands() []completionCommand {
+	var cmds []completionCommand
+	for _, c := range commands {
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.register(fs)
+
+		cc := completionCommand{name: c.name, usage: c.usage}
+		fs.VisitAll(func(f *flag.Flag) {
+			b, ok := f.Value.(interface{ IsBoolFlag() bool })
+			cc.flags = append(cc.flags, completionFlag{
+				name:   f.Name,
+				usage:  f.Usage,
+				bool:   ok && b.IsBoolFlag(),
+				values: completionValues[f.Name],
+			})
+		})
+		cmds = append(cmds, cc)
+	}
+	return cmds
+}
+
+func commandNames(cmds []completionCommand) string {
+	names := []string{"completion", "help"}
+	for _, c := range cmds {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " ")
+}
+
+func runCompletion(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: ipsubmap completion bash|zsh|fish\n")
+		return 2
+	}
+
+	cmds := completionCommands()
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(stdout, cmds)
+	case "zsh":
+		writeZshCompletion(stdout, cmds)
+	case "fish":
+		writeFishCompletion(stdout, cmds)
+	default:
+		fmt.Fprintf(stderr, "unknown shell %q: expected bash, zsh or fish\n", args[0])
+		return 2
+	}
+	return 0
+}
+
+func writeBashCompletion(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintf(w, `_ipsubmap() {
+    local cur prev cmd flags
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    if [[ ${COMP_CWORD} -eq 1 && ${cur} != -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "${cur}"))
+        return
+    fi
+
+    cmd=resolve
+    if [[ ${COMP_WORDS[1]} != -* ]]; then
+        cmd=${COMP_WORDS[1]}
+    fi
+
+    if [[ ${cmd} == completion ]]; then
+        COMPREPLY=($(compgen -W "bash zsh fish" -- "${cur}"))
+        return
+    fi
+
+    case "${cmd} ${prev}" in
+`, commandNames(cmds))
+
+	for _, c := range cmds {
+		for _, f := range c.flags {
+			if f.values != nil {
+				fmt.Fprintf(w, "        \"%s -%s\")\n            COMPREPLY=($(compgen -W \"%s\" -- \"${cur}\"))\n            return\n            ;;\n",
+					c.name, f.name, strings.Join(f.values, " "))
+			}
+		}
+	}
+	fmt.Fprintf(w, "    esac\n\n    case \"${cmd}\" in\n")
+
+	for _, c := range cmds {
+		var names []string
+		for _, f := range c.flags {
+			names = append(names, "-"+f.name)
+		}
+		fmt.Fprintf(w, "        %s)\n            flags=\"%s\"\n            ;;\n", c.name, strings.Join(names, " "))
+	}
+
+	fmt.Fprintf(w, `    esac
+
+    if [[ ${cur} == -* ]]; then
+        COMPREPLY=($(compgen -W "${flags}" -- "${cur}"))
+        return
+    fi
+    COMPREPLY=($(compgen -f -- "${cur}"))
+}
+
+complete -o filenames -F _ipsubmap ipsubmap
+`)
+}
+
+func zshQuote(s string) string {
+	r := strings.NewReplacer(`'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`)
+	return r.Replace(s)
+}
+
+func writeZshCompletion(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintf(w, `#compdef ipsubmap
+
+_ipsubmap() {
+    local cmd=resolve
+    if (( CURRENT == 2 )) && [[ ${words[2]} != -* ]]; then
+        local -a commands
+        commands=(
+`)
+	fmt.Fprintf(w, "            'completion:Print a shell completion script'\n")
+	fmt.Fprintf(w, "            'help:List the commands'\n")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "            '%s:%s'\n", c.name, zshQuote(c.usage))
+	}
+	fmt.Fprintf(w, `        )
+        _describe command commands
+        return
+    fi
+    if [[ ${words[2]} != -* ]]; then
+        cmd=${words[2]}
+        shift words
+        (( CURRENT-- ))
+    fi
+
+    case ${cmd} in
+        completion)
+            _values shell bash zsh fish
+            ;;
+`)
+
+	for _, c := range cmds {
+		fmt.Fprintf(w, "        %s)\n            _arguments \\\n", c.name)
+		for _, f := range c.flags {
+			spec := fmt.Sprintf("-%s[%s]", f.name, zshQuote(f.usage))
+			switch {
+			case f.bool:
+			case f.values != nil:
+				spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(f.values, " "))
+			default:
+				spec += fmt.Sprintf(":%s:_files", f.name)
+			}
+			fmt.Fprintf(w, "                '%s' \\\n", spec)
+		}
+		fmt.Fprintf(w, "                '*:file:_files'\n            ;;\n")
+	}
+
+	fmt.Fprintf(w, `    esac
+}
+
+_ipsubmap "$@"
+`)
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+func writeFishCompletion(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintf(w, `function __ipsubmap_command
+    set -l tokens (commandline -opc)
+    if test (count $tokens) -gt 1; and not string match -q -- '-*' $tokens[2]
+        echo $tokens[2]
+    else
+        echo resolve
+    end
+end
+
+complete -c ipsubmap -n __fish_use_subcommand -f -a completion -d 'Print a shell completion script'
+complete -c ipsubmap -n __fish_use_subcommand -f -a help -d 'List the commands'
+complete -c ipsubmap -n '__ipsubmap_command | string match -q completion' -f -a 'bash zsh fish'
+`)
+
+	for _, c := range cmds {
+		fmt.Fprintf(w, "complete -c ipsubmap -n __fish_use_subcommand -f -a %s -d %s\n", c.name, fishQuote(c.usage))
+	}
+
+	for _, c := range cmds {
+		cond := fishQuote("__ipsubmap_command | string match -q " + c.name)
+		for _, f := range c.flags {
+			line := fmt.Sprintf("complete -c ipsubmap -n %s -o %s -d %s", cond, f.name, fishQuote(f.usage))
+			switch {
+			case f.bool:
+			case f.values != nil:
+				line += " -x -a " + fishQuote(strings.Join(f.values, " "))
+			default:
+				line += " -r -F"
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunCompletion(t *testing.T) {
+	tt := map[string]struct {
+		shell string
+		want  []string
+	}{
+		"bash": {
+			shell: "bash",
+			want: []string{
+				"complete -o filenames -F _ipsubmap ipsubmap",
+				"resolve discover classify diff merge bench version",
+				`"resolve -fail-on")`,
+				"private public loopback",
+				"-out-public",
+			},
+		},
+		"zsh": {
+			shell: "zsh",
+			want: []string{
+				"#compdef ipsubmap",
+				"'classify:Print the class",
+				"'-file[Input file, or an http(s) URL to download it from]:file:_files'",
+				":fail-on:(private public loopback)",
+				"'-force[Overwrite existing output files]'",
+			},
+		},
+		"fish": {
+			shell: "fish",
+			want: []string{
+				"complete -c ipsubmap -n __fish_use_subcommand -f -a merge",
+				"string match -q resolve' -o file -d 'Input file, or an http(s) URL to download it from' -r -F",
+				"-o fail-on",
+				"-x -a 'private public loopback'",
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			if code := dispatch([]string{"completion", tc.shell}, nil, stdout, stderr); code != 0 {
+				t.Fatalf("expected exit code 0, got %d: %s", code, stderr.String())
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("expected script to contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCompletion_unknownShell(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	if code := dispatch([]string{"completion", "ksh"}, nil, stdout, stderr); code != 2 {
+		t.Errorf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown shell "ksh"`) {
+		t.Errorf("unexpected stderr %q", stderr.String())
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func loadConfig(path string) (map[string][]string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return parseYAMLConfig(in)
+	case ".toml":
+		return parseTOMLConfig(in)
+	default:
+		return nil, fmt.Errorf("unknown config format %q: expected .yaml, .yml or .toml", filepath.Ext(path))
+	}
+}
+
+// applyConfig sets every flag from values that was not set on the command line.
+func applyConfig(fs *flag.FlagSet, values map[string][]string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for key, vals := range values {
+		name := strings.ReplaceAll(key, "_", "-")
+		if fs.Lookup(name) == nil || name == "config" {
+			return fmt.Errorf("unknown config key %q", key)
+		}
+		if set[name] {
+			continue
+		}
+		for _, v := range vals {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("invalid value for %q: %v", key, err)
+			}
+		}
+	}
+	return nil
+}
+
+const envPrefix = "IPSUBMAP_"
+
+// applyEnv sets every flag from an IPSUBMAP_* variable in environ that was not
+// set on the command line. IPSUBMAP_OUT_PUBLIC sets -out-public.
+func applyEnv(fs *flag.FlagSet, environ []string) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		name, ok := strings.CutPrefix(key, envPrefix)
+		if !ok {
+			continue
+		}
+		name = strings.ToLower(strings.ReplaceAll(name, "_", "-"))
+		if fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown environment variable %s", key)
+		}
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func parseYAMLConfig(in io.Reader) (map[string][]string, error) {
+	values := make(map[string][]string)
+	list := ""
+
+	scanner := bufio.NewScanner(in)
+	n := 0
+	for scanner.Scan() {
+		n++
+		raw := cleanLine(scanner.Text())
+		line := strings.TrimSpace(stripComment(raw))
+		if line == "" || line == "---" {
+			continue
+		}
+
+		if item, ok := strings.CutPrefix(line, "- "); ok && list != "" {
+			v, err := parseScalar(item)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			values[list] = append(values[list], v)
+			continue
+		}
+
+		if raw[0] == ' ' || raw[0] == '\t' {
+			return nil, fmt.Errorf("line %d: nested values are not supported", n)
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		list = ""
+		if value == "" {
+			list = key
+			values[key] = nil
+			continue
+		}
+
+		vals, err := parseValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		values[key] = vals
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseTOMLConfig(in io.Reader) (map[string][]string, error) {
+	values := make(map[string][]string)
+
+	scanner := bufio.NewScanner(in)
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(stripComment(cleanLine(scanner.Text())))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported", n)
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+
+		vals, err := parseValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		values[key] = vals
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func parseValue(value string) ([]string, error) {
+	inner, ok := strings.CutPrefix(value, "[")
+	if !ok {
+		v, err := parseScalar(value)
+		return []string{v}, err
+	}
+
+	inner, ok = strings.CutSuffix(inner, "]")
+	if !ok {
+		return nil, fmt.Errorf("unterminated list %q", value)
+	}
+
+	var vals []string
+	for _, item := range splitList(inner) {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		v, err := parseScalar(item)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+func parseScalar(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	default:
+		return s, nil
+	}
+}
+
+// stripComment removes a # comment that is not inside a quoted string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func splitList(s string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == '\\' && quote == '"':
+			i++
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, s[start:])
+}
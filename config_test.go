@@ -0,0 +1,165 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	want := map[string][]string{
+		"file":         {"subdomains.txt"},
+		"out-public":   {"public #1.txt"},
+		"ipv6":         {"true"},
+		"include-cidr": {"10.0.0.0/8", "192.168.0.0/16"},
+		"match":        {`\.example\.com$`},
+	}
+
+	tt := map[string]struct {
+		parse func(string) (map[string][]string, error)
+		in    string
+	}{
+		"yaml": {
+			parse: func(s string) (map[string][]string, error) { return parseYAMLConfig(strings.NewReader(s)) },
+			in: "# ipsubmap\n" +
+				"---\n" +
+				"file: subdomains.txt\n" +
+				"out-public: \"public #1.txt\" # comment\n" +
+				"ipv6: true\n" +
+				"include-cidr:\n" +
+				"  - 10.0.0.0/8\n" +
+				"  - '192.168.0.0/16'\n" +
+				"match: '\\.example\\.com$'\n",
+		},
+		"toml": {
+			parse: func(s string) (map[string][]string, error) { return parseTOMLConfig(strings.NewReader(s)) },
+			in: "# ipsubmap\n" +
+				"file = \"subdomains.txt\"\n" +
+				"out-public = \"public #1.txt\" # comment\n" +
+				"ipv6 = true\n" +
+				"include-cidr = [\"10.0.0.0/8\", \"192.168.0.0/16\"]\n" +
+				"match = '\\.example\\.com$'\n",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.parse(tc.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %v, got %v", want, got)
+			}
+		})
+	}
+}
+
+func TestParseConfig_unsupported(t *testing.T) {
+	if _, err := parseYAMLConfig(strings.NewReader("scope:\n  file: scope.txt\n")); err == nil {
+		t.Error("expected error for nested yaml")
+	}
+	if _, err := parseTOMLConfig(strings.NewReader("[outputs]\npublic = \"public.txt\"\n")); err == nil {
+		t.Error("expected error for toml table")
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	fs := flag.NewFlagSet("ipsubmap", flag.ContinueOnError)
+	public := fs.String("out-public", "", "")
+	private := fs.String("out-private", "", "")
+	ipv6 := fs.Bool("ipv6", false, "")
+	var cidrs prefixList
+	fs.Var(&cidrs, "include-cidr", "")
+
+	if err := fs.Parse([]string{"-out-public", "cli.txt"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "ipsubmap.yaml")
+	config := "out-public: config.txt\nout_private: private.txt\nipv6: true\ninclude-cidr: [10.0.0.0/8, 192.168.0.0/16]\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+
+	values, err := loadConfig(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := applyConfig(fs, values); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *public != "cli.txt" {
+		t.Errorf("expected command line to take precedence, got %q", *public)
+	}
+	if *private != "private.txt" || !*ipv6 || len(cidrs) != 2 {
+		t.Errorf("unexpected values %q %v %v", *private, *ipv6, cidrs)
+	}
+
+	if err := applyConfig(fs, map[string][]string{"workers": {"4"}}); err == nil {
+		t.Error("expected error for unknown key")
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tt := map[string]struct {
+		args    []string
+		environ []string
+		want    string
+		wantErr bool
+	}{
+		"env": {
+			environ: []string{"HOME=/root", "IPSUBMAP_OUT_PUBLIC=env.txt"},
+			want:    "env.txt",
+		},
+		"flag wins": {
+			args:    []string{"-out-public", "cli.txt"},
+			environ: []string{"IPSUBMAP_OUT_PUBLIC=env.txt"},
+			want:    "cli.txt",
+		},
+		"unknown": {
+			environ: []string{"IPSUBMAP_WORKERS=4"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			fs := flag.NewFlagSet("ipsubmap", flag.ContinueOnError)
+			public := fs.String("out-public", "", "")
+			if err := fs.Parse(tc.args); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			err := applyEnv(fs, tc.environ)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && *public != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, *public)
+			}
+		})
+	}
+}
+
+func TestApplyEnv_beforeConfig(t *testing.T) {
+	fs := flag.NewFlagSet("ipsubmap", flag.ContinueOnError)
+	public := fs.String("out-public", "", "")
+	private := fs.String("out-private", "", "")
+
+	if err := applyEnv(fs, []string{"IPSUBMAP_OUT_PUBLIC=env.txt"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	values := map[string][]string{"out-public": {"config.txt"}, "out-private": {"private.txt"}}
+	if err := applyConfig(fs, values); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if *public != "env.txt" || *private != "private.txt" {
+		t.Errorf("expected env to take precedence over config, got %q %q", *public, *private)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io"
+	"slices"
+)
+
+type deltaReport struct {
+	baseline *combinedReport
+	current  *combinedReport
+	gone     bool
+}
+
+func newDeltaReport(baseline *combinedReport, gone bool) *deltaReport {
+	return &deltaReport{
+		baseline: baseline,
+		current:  newCombinedReport(baseline.sortMode),
+		gone:     gone,
+	}
+}
+
+func (r *deltaReport) add(class string, ip string, subdomain string) {
+	r.current.add(class, ip, subdomain)
+}
+
+func (r *deltaReport) write(out io.Writer) error {
+	if r.gone {
+		return subtractCombined(r.baseline, r.current).write(out)
+	}
+	return subtractCombined(r.current, r.baseline).write(out)
+}
+
+func subtractCombined(a, b *combinedReport) *combinedReport {
+	diff := newCombinedReport(a.sortMode)
+	for ip, entry := range a.ips {
+		var other []string
+		if e, ok := b.ips[ip]; ok {
+			other = e.subdomains
+		}
+		for _, subdomain := range entry.subdomains {
+			if !slices.Contains(other, subdomain) {
+				diff.add(entry.class, ip, subdomain)
+			}
+		}
+	}
+	return diff
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDeltaReport(t *testing.T) {
+	baseline := newCombinedReport(sortNumeric)
+	err := baseline.load(strings.NewReader("10.0.0.1 private a.example.com,b.example.com\n1.1.1.1 public old.example.com"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tt := map[string]struct {
+		gone bool
+		want string
+	}{
+		"new": {
+			want: "8.8.8.8 public new.example.com\n10.0.0.1 private c.example.com",
+		},
+		"gone": {
+			gone: true,
+			want: "1.1.1.1 public old.example.com\n10.0.0.1 private b.example.com",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			r := newDeltaReport(baseline, tc.gone)
+			r.add(classPrivate, "10.0.0.1", "a.example.com")
+			r.add(classPrivate, "10.0.0.1", "c.example.com")
+			r.add(classPublic, "8.8.8.8", "new.example.com")
+
+			out := &bytes.Buffer{}
+			if err := r.write(out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
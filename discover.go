@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const defaultCTURL = "https://crt.sh/?q=%25.{domain}&output=json"
+
+func (f *Flags) registerDiscover(fs *flag.FlagSet) {
+	fs.StringVar(&f.ctURL, "ct-url", defaultCTURL, "Certificate transparency search returning crt.sh style JSON, {domain} is replaced with each -domain")
+}
+
+type ctEntry struct {
+	NameValue  string `json:"name_value"`
+	CommonName string `json:"common_name"`
+}
+
+// discoverNames queries a certificate transparency aggregator returning
+// crt.sh style JSON for the names under domain. {domain} in endpoint is
+// replaced with the domain.
+func discoverNames(endpoint, domain string) ([]string, error) {
+	u := strings.ReplaceAll(endpoint, "{domain}", url.QueryEscape(domain))
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to query %s: unexpected status %s", u, resp.Status)
+	}
+
+	var entries []ctEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to decode response of %s: %v", u, err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		for _, name := range strings.Split(e.NameValue+"\n"+e.CommonName, "\n") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			name = strings.TrimSuffix(strings.TrimPrefix(name, "*."), ".")
+			if name != domain && !strings.HasSuffix(name, "."+domain) {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return slices.Compact(names), nil
+}
+
+// discoverInput returns the names found for every domain, one per line.
+func discoverInput(endpoint string, domains []string) (string, int, error) {
+	var b strings.Builder
+	n := 0
+	for _, domain := range domains {
+		names, err := discoverNames(endpoint, domain)
+		if err != nil {
+			return "", 0, err
+		}
+		for _, name := range names {
+			b.WriteString(name)
+			b.WriteByte('\n')
+		}
+		n += len(names)
+	}
+	return b.String(), n, nil
+}
+
+func isDiscoverURL(s string) bool {
+	return isURL(s) && strings.Contains(s, "{domain}")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestDiscoverNames(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("q") {
+		case "%.example.com":
+			io.WriteString(w, `[
+				{"name_value": "www.example.com\n*.api.example.com", "common_name": "www.example.com"},
+				{"name_value": "Mail.Example.com.", "common_name": "mail.example.com"},
+				{"name_value": "example.com\nexample.org", "common_name": "notexample.com"}
+			]`)
+		case "%.broken.com":
+			io.WriteString(w, `{"error":`)
+		default:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	endpoint := srv.URL + "/?q=%25.{domain}&output=json"
+	tt := map[string]struct {
+		domain string
+		want   []string
+		err    bool
+	}{
+		"names": {
+			domain: "example.com",
+			want:   []string{"api.example.com", "example.com", "mail.example.com", "www.example.com"},
+		},
+		"invalid json": {domain: "broken.com", err: true},
+		"status":       {domain: "down.com", err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := discoverNames(endpoint, tc.domain)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIsDiscoverURL(t *testing.T) {
+	tt := map[string]bool{
+		defaultCTURL:                       true,
+		"https://ct.example.com/{domain}":  true,
+		"https://crt.sh/?q=example.com":    false,
+		"ftp://ct.example.com/?q={domain}": false,
+	}
+
+	for in, want := range tt {
+		if got := isDiscoverURL(in); got != want {
+			t.Errorf("%q: expected %v, got %v", in, want, got)
+		}
+	}
+}
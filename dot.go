@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+var dotColors = map[string]string{
+	classPublic:   "#9ecae1",
+	classPrivate:  "#fdae6b",
+	classLoopback: "#d9d9d9",
+}
+
+type dotReport struct {
+	ips      map[string]string
+	edges    map[string][]string
+	sortMode sortMode
+}
+
+func newDotReport(mode sortMode) *dotReport {
+	return &dotReport{
+		ips:      make(map[string]string),
+		edges:    make(map[string][]string),
+		sortMode: ipSortMode(mode),
+	}
+}
+
+func (r *dotReport) add(class string, ip string, subdomain string) {
+	r.ips[ip] = class
+	if !slices.Contains(r.edges[subdomain], ip) {
+		r.edges[subdomain] = append(r.edges[subdomain], ip)
+	}
+}
+
+func (r *dotReport) write(out io.Writer) error {
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "digraph ipsubmap {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [fontname=\"Helvetica\"];")
+
+	for _, class := range classes {
+		fmt.Fprintf(w, "\tsubgraph %s {\n", dotQuote("cluster_"+class))
+		fmt.Fprintf(w, "\t\tlabel=%s;\n", dotQuote(class))
+		fmt.Fprintf(w, "\t\tnode [shape=box, style=filled, fillcolor=%s];\n", dotQuote(dotColors[class]))
+
+		var ips []string
+		for ip, c := range r.ips {
+			if c == class {
+				ips = append(ips, ip)
+			}
+		}
+		sortIPs(ips, r.sortMode)
+		for _, ip := range ips {
+			fmt.Fprintf(w, "\t\t%s;\n", dotQuote(ip))
+		}
+		fmt.Fprintln(w, "\t}")
+	}
+
+	subdomains := make([]string, 0, len(r.edges))
+	for subdomain := range r.edges {
+		subdomains = append(subdomains, subdomain)
+	}
+	slices.Sort(subdomains)
+
+	fmt.Fprintln(w, "\tnode [shape=ellipse, style=solid];")
+	for _, subdomain := range subdomains {
+		fmt.Fprintf(w, "\t%s;\n", dotQuote(subdomain))
+	}
+	for _, subdomain := range subdomains {
+		ips := r.edges[subdomain]
+		sortIPs(ips, r.sortMode)
+		for _, ip := range ips {
+			fmt.Fprintf(w, "\t%s -> %s;\n", dotQuote(subdomain), dotQuote(ip))
+		}
+	}
+
+	fmt.Fprint(w, "}\n")
+	return w.Flush()
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDotReport(t *testing.T) {
+	r := newDotReport(sortNumeric)
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	r.add(classPublic, "1.1.1.1", "b.example.com")
+	r.add(classPrivate, "10.0.0.1", `we"ird.example.com`)
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"digraph ipsubmap {",
+		`subgraph "cluster_public" {`,
+		`node [shape=box, style=filled, fillcolor="#9ecae1"];`,
+		`"a.example.com" -> "1.1.1.1";`,
+		`"b.example.com" -> "1.1.1.1";`,
+		`"we\"ird.example.com" -> "10.0.0.1";`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected graph to contain %q, got:\n%s", want, got)
+		}
+	}
+}
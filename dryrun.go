@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+)
+
+const maxReportedInvalid = 10
+
+var hostnamePattern = regexp.MustCompile(`^([a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?\.)*[a-zA-Z0-9_]([a-zA-Z0-9_-]{0,61}[a-zA-Z0-9_])?\.?$`)
+
+type invalidLine struct {
+	n    int
+	line string
+}
+
+type inputCheck struct {
+	lines      int
+	empty      int
+	duplicates int
+	invalid    int
+	samples    []invalidLine
+	first      string
+}
+
+func validHostname(s string) bool {
+	return len(s) <= 253 && hostnamePattern.MatchString(s)
+}
+
+// checkInput counts the lines of in, and the duplicate and invalid entries of
+// the formats whose lines are a single entry.
+func checkInput(in io.Reader, mode lookupMode, input inputFormat) (inputCheck, error) {
+	var c inputCheck
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		c.lines++
+		line := cleanLine(scanner.Text())
+		if line == "" {
+			c.empty++
+			continue
+		}
+		if input != inputText {
+			continue
+		}
+
+		if seen[line] {
+			c.duplicates++
+			continue
+		}
+		seen[line] = true
+
+		valid := true
+		switch mode {
+		case modeReverse:
+			_, err := reverseTargets(line)
+			valid = err == nil
+		case modeClassify:
+			fields := strings.Fields(line)
+			valid = len(fields) > 0 && net.ParseIP(fields[len(fields)-1]) != nil
+		default:
+			valid = validHostname(line)
+		}
+		if !valid {
+			c.invalid++
+			if len(c.samples) < maxReportedInvalid {
+				c.samples = append(c.samples, invalidLine{n: n, line: line})
+			}
+		} else if c.first == "" && mode == modeResolve {
+			c.first = line
+		}
+	}
+	return c, scanner.Err()
+}
+
+// checkResolver resolves host, succeeding when the resolver answered even if
+// the name does not exist.
+func checkResolver(host string) error {
+	_, err := lookupIP(host)
+	var dnsErr *net.DNSError
+	if err == nil || (errors.As(err, &dnsErr) && dnsErr.IsNotFound) {
+		return nil
+	}
+	return err
+}
+
+// dryRun checks the input and the resolver without resolving the input and
+// returns the exit status.
+func dryRun(logger *slog.Logger, in io.Reader, flags *Flags) int {
+	status := 0
+	c, err := checkInput(in, flags.lookupMode, flags.inputFormat)
+	if err != nil {
+		logger.Error("failed to read input", "error", err)
+		return 1
+	}
+	for _, l := range c.samples {
+		logger.Warn("Invalid input line", "line", l.n, "value", l.line)
+	}
+	logger.Info("Checked input", "lines", c.lines, "empty", c.empty, "duplicates", c.duplicates, "invalid", c.invalid)
+
+	if c.first != "" {
+		if err := checkResolver(c.first); err != nil {
+			logger.Error("Resolver is not reachable", "host", c.first, "error", err)
+			status = 1
+		} else {
+			logger.Info("Resolver is reachable", "host", c.first)
+		}
+	}
+	return status
+}
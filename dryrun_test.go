@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCheckInput(t *testing.T) {
+	tt := map[string]struct {
+		in      string
+		mode    lookupMode
+		input   inputFormat
+		want    inputCheck
+		samples int
+	}{
+		"hostnames": {
+			in:    "www.example.com\n\nwww.example.com\n_dmarc.example.com\nbad host\n-bad.example.com\n",
+			mode:  modeResolve,
+			input: inputText,
+			want:  inputCheck{lines: 6, empty: 1, duplicates: 1, invalid: 2, first: "www.example.com"},
+		},
+		"reverse": {
+			in:    "10.0.0.1\n10.0.0.0/24\nexample.com\n",
+			mode:  modeReverse,
+			input: inputText,
+			want:  inputCheck{lines: 3, invalid: 1},
+		},
+		"classify": {
+			in:    "www.example.com 10.0.0.1\n10.0.0.2\nwww.example.com\n",
+			mode:  modeClassify,
+			input: inputText,
+			want:  inputCheck{lines: 3, invalid: 1},
+		},
+		"massdns": {
+			in:    "www.example.com. A 10.0.0.1\n\nwww.example.com. A 10.0.0.1\n",
+			mode:  modeResolve,
+			input: inputMassdns,
+			want:  inputCheck{lines: 3, empty: 1},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := checkInput(strings.NewReader(tc.in), tc.mode, tc.input)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(got.samples) != got.invalid {
+				t.Errorf("expected %d invalid samples, got %v", got.invalid, got.samples)
+			}
+			got.samples = nil
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("expected %+v, got %+v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	tt := map[string]struct {
+		err    error
+		status int
+	}{
+		"reachable":    {},
+		"not found":    {err: &net.DNSError{Err: "no such host", IsNotFound: true}},
+		"unreachable":  {err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}, status: 1},
+		"server error": {err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}, status: 1},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			lookups := 0
+			lookupIP = func(host string) ([]net.IP, error) {
+				lookups++
+				return nil, tc.err
+			}
+			defer func() { lookupIP = net.LookupIP }()
+
+			logs := &bytes.Buffer{}
+			flags := &Flags{lookupMode: modeResolve, inputFormat: inputText}
+			status := dryRun(slog.New(slog.NewTextHandler(logs, nil)), strings.NewReader("www.example.com\napi.example.com\n"), flags)
+			if status != tc.status {
+				t.Errorf("expected status %d, got %d: %s", tc.status, status, logs)
+			}
+			if lookups != 1 {
+				t.Errorf("expected a single lookup, got %d", lookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const elasticBatchSize = 1000
+
+type elasticReport struct {
+	endpoint string
+	index    string
+	apiKey   string
+	buf      bytes.Buffer
+	pending  int
+	err      error
+	now      func() time.Time
+}
+
+func newElasticReport(rawURL string) (*elasticReport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid elasticsearch url %q", rawURL)
+	}
+
+	index := strings.Trim(u.Path, "/")
+	if index == "" || strings.Contains(index, "/") {
+		return nil, fmt.Errorf("elasticsearch url %q must end with the index name", rawURL)
+	}
+	u.Path = "/_bulk"
+
+	return &elasticReport{
+		endpoint: u.String(),
+		index:    index,
+		apiKey:   os.Getenv("ELASTIC_API_KEY"),
+		now:      time.Now,
+	}, nil
+}
+
+func (r *elasticReport) add(class string, ip string, subdomain string) {
+	if r.err != nil {
+		return
+	}
+
+	action, _ := json.Marshal(map[string]any{"index": map[string]string{"_index": r.index}})
+	doc, _ := json.Marshal(resultRecord{SchemaVersion: schemaVersion, Subdomain: subdomain, IP: ip, Class: class, Timestamp: r.now().UTC()})
+	r.buf.Write(action)
+	r.buf.WriteByte('\n')
+	r.buf.Write(doc)
+	r.buf.WriteByte('\n')
+	r.pending++
+
+	if r.pending >= elasticBatchSize {
+		r.err = r.flush()
+	}
+}
+
+func (r *elasticReport) flush() error {
+	if r.pending == 0 {
+		return nil
+	}
+	defer func() {
+		r.buf.Reset()
+		r.pending = 0
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(r.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+r.apiKey)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("bulk request failed: %s: %s", resp.Status, truncate(bytes.TrimSpace(body), 512))
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Error json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("invalid bulk response: %v", err)
+	}
+	if !result.Errors {
+		return nil
+	}
+
+	failed := 0
+	var first json.RawMessage
+	for _, item := range result.Items {
+		for _, status := range item {
+			if len(status.Error) > 0 {
+				failed++
+				if first == nil {
+					first = status.Error
+				}
+			}
+		}
+	}
+	return fmt.Errorf("%d documents failed to index: %s", failed, truncate(first, 512))
+}
+
+func (r *elasticReport) write(_ io.Writer) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.flush()
+}
+
+func truncate(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[:n]
+	}
+	return b
+}
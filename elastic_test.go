@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewElasticReport(t *testing.T) {
+	tt := map[string]struct {
+		url          string
+		wantEndpoint string
+		wantIndex    string
+		wantErr      bool
+	}{
+		"valid":    {url: "http://localhost:9200/recon", wantEndpoint: "http://localhost:9200/_bulk", wantIndex: "recon"},
+		"no index": {url: "http://localhost:9200", wantErr: true},
+		"scheme":   {url: "localhost:9200/recon", wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			r, err := newElasticReport(tc.url)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && (r.endpoint != tc.wantEndpoint || r.index != tc.wantIndex) {
+				t.Errorf("expected %s %s, got %s %s", tc.wantEndpoint, tc.wantIndex, r.endpoint, r.index)
+			}
+		})
+	}
+}
+
+func TestElasticReport(t *testing.T) {
+	var lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		io.WriteString(w, `{"errors":false,"items":[]}`)
+	}))
+	defer srv.Close()
+
+	r, err := newElasticReport(srv.URL + "/recon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	if err := r.write(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		`{"index":{"_index":"recon"}}`,
+		`{"schema_version":1,"subdomain":"a.example.com","ip":"1.1.1.1","class":"public","timestamp":"2024-01-02T03:04:05Z"}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, lines)
+	}
+}
+
+func TestElasticReport_bulkErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"errors": true,
+			"items":  []any{map[string]any{"index": map[string]any{"error": map[string]string{"type": "mapper_parsing_exception"}}}},
+		})
+	}))
+	defer srv.Close()
+
+	r, err := newElasticReport(srv.URL + "/recon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+
+	err = r.write(nil)
+	if err == nil || !strings.Contains(err.Error(), "mapper_parsing_exception") {
+		t.Errorf("expected bulk item error, got %v", err)
+	}
+}
+
+func TestIPSubMapWrite_elasticUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	r, err := newElasticReport(srv.URL + "/recon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ips := newIPReport(sortNumeric)
+	out := &strings.Builder{}
+	m := &ipSubMap{
+		reports: []reportOutput{
+			{name: "unique ip addresses", out: out, report: ips},
+			{name: "elasticsearch", out: io.Discard, sink: true, report: r},
+		},
+	}
+	for _, report := range m.reports {
+		report.add(classPublic, "1.1.1.1", "a.example.com")
+	}
+
+	failed := m.writeSinks()
+	if failed["elasticsearch"] == nil || len(failed) != 1 {
+		t.Errorf("expected the elasticsearch sink to fail, got %v", failed)
+	}
+	if err := m.write(); err != nil {
+		t.Fatalf("expected local outputs to be written, got %v", err)
+	}
+	if out.String() != "1.1.1.1" {
+		t.Errorf("unexpected local output %q", out.String())
+	}
+}
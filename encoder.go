@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// outputBufferSize is the size of the buffer entries are batched in before
+// they are written to an output.
+const outputBufferSize = 64 << 10
+
+// encoder appends one record to b. Text encoders leave out the final line
+// break; the sink puts one between records.
+type encoder interface {
+	encode(b []byte, r record) ([]byte, error)
+}
+
+// headerEncoder is implemented by encoders whose files start with a header
+// line, written again at the top of every rotated segment.
+type headerEncoder interface {
+	header() []byte
+}
+
+func (f *fragment) encoder() encoder {
+	switch f.format {
+	case formatProto:
+		return protoEncoder{class: f.class}
+	case formatMsgpack:
+		return msgpackEncoder{class: f.class, seen: f.seen, now: time.Now()}
+	case formatJSON:
+		return newJSONEncoder(f.class, f.seen)
+	case formatCSV:
+		return &csvEncoder{class: f.class, seen: f.seen, now: time.Now()}
+	}
+	if f.template != nil {
+		return &templateEncoder{template: f.template, class: f.class}
+	}
+	ipSep, subSep := f.separators()
+	return lineEncoder{ipSep: ipSep, subSep: subSep}
+}
+
+type rotator interface {
+	full(buffered int, n int) bool
+	rotate(n int) (bool, error)
+}
+
+// encoderSink writes the encoded records to out through a buffer, rotating
+// out when it has a size limit.
+type encoderSink struct {
+	out   io.Writer
+	w     *bufio.Writer
+	enc   encoder
+	sep   string
+	first bool
+	buf   []byte
+	class string
+
+	maxValues int
+	overflow  *overflowReport
+}
+
+func (f *fragment) encoderSink(out io.Writer) *encoderSink {
+	s := &encoderSink{
+		out:       out,
+		w:         bufio.NewWriterSize(out, outputBufferSize),
+		enc:       f.encoder(),
+		first:     true,
+		class:     f.class,
+		maxValues: f.maxValues,
+		overflow:  f.overflow,
+	}
+	if !f.format.binary() {
+		s.sep = "\n"
+	}
+	return s
+}
+
+// entry writes b, preceded by the separator unless it starts the file.
+func (s *encoderSink) entry(b []byte) error {
+	n := len(b)
+	if !s.first {
+		n += len(s.sep)
+	}
+	if r, ok := s.out.(rotator); ok && r.full(s.w.Buffered(), n) {
+		if err := s.w.Flush(); err != nil {
+			return err
+		}
+		rotated, err := r.rotate(n)
+		if err != nil {
+			return err
+		}
+		if rotated {
+			s.first = true
+		}
+	}
+
+	if s.first {
+		s.first = false
+		if h, ok := s.enc.(headerEncoder); ok {
+			s.w.Write(h.header())
+			s.w.WriteString(s.sep)
+		}
+	} else {
+		s.w.WriteString(s.sep)
+	}
+	_, err := s.w.Write(b)
+	return err
+}
+
+func (s *encoderSink) write(r record) error {
+	if s.maxValues > 0 && len(r.values) > s.maxValues {
+		s.overflow.overflow(s.class, r.key, r.values)
+		r.values = r.values[:s.maxValues]
+	}
+
+	b, err := s.enc.encode(s.buf[:0], r)
+	if err != nil {
+		return err
+	}
+	s.buf = b
+	return s.entry(b)
+}
+
+func (s *encoderSink) close() error {
+	return s.w.Flush()
+}
+
+// lineEncoder writes the ip address, then its subdomains, as in the list and
+// hosts formats.
+type lineEncoder struct {
+	ipSep  string
+	subSep string
+}
+
+func (e lineEncoder) encode(b []byte, r record) ([]byte, error) {
+	b = append(b, r.key...)
+	b = append(b, e.ipSep...)
+	for i, v := range r.values {
+		if i > 0 {
+			b = append(b, e.subSep...)
+		}
+		b = append(b, v...)
+	}
+	return b, nil
+}
+
+type templateEncoder struct {
+	template *template.Template
+	class    string
+	buf      bytes.Buffer
+}
+
+func (e *templateEncoder) encode(b []byte, r record) ([]byte, error) {
+	e.buf.Reset()
+	if err := e.template.Execute(&e.buf, templateData{IP: r.key, Class: e.class, Subdomains: r.values}); err != nil {
+		return nil, err
+	}
+	return append(b, e.buf.Bytes()...), nil
+}
+
+// jsonEncoder writes one JSON record per subdomain and ip address, a line
+// each.
+type jsonEncoder struct {
+	class string
+	seen  map[string]time.Time
+	now   time.Time
+	buf   bytes.Buffer
+	enc   *json.Encoder
+}
+
+func newJSONEncoder(class string, seen map[string]time.Time) *jsonEncoder {
+	e := &jsonEncoder{class: class, seen: seen, now: time.Now()}
+	e.enc = json.NewEncoder(&e.buf)
+	return e
+}
+
+func (e *jsonEncoder) encode(b []byte, r record) ([]byte, error) {
+	e.buf.Reset()
+	for _, subdomain := range r.values {
+		err := e.enc.Encode(resultRecord{
+			SchemaVersion: schemaVersion,
+			Subdomain:     subdomain,
+			IP:            r.key,
+			Class:         e.class,
+			Timestamp:     seenAt(e.seen, subdomain, e.now).UTC().Truncate(time.Second),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return append(b, bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))...), nil
+}
+
+var csvColumns = []string{"subdomain", "ip", "class", "timestamp"}
+
+// csvEncoder writes one row per subdomain and ip address under a csvColumns
+// header.
+type csvEncoder struct {
+	class string
+	seen  map[string]time.Time
+	now   time.Time
+	buf   bytes.Buffer
+}
+
+func (e *csvEncoder) header() []byte {
+	return []byte(strings.Join(csvColumns, ","))
+}
+
+func (e *csvEncoder) encode(b []byte, r record) ([]byte, error) {
+	e.buf.Reset()
+	w := csv.NewWriter(&e.buf)
+	for _, subdomain := range r.values {
+		if err := w.Write([]string{subdomain, r.key, e.class, formatSeen(seenAt(e.seen, subdomain, e.now))}); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+	return append(b, bytes.TrimSuffix(e.buf.Bytes(), []byte("\n"))...), nil
+}
+
+// seenAt returns when subdomain was first resolved, or now when that was not
+// recorded.
+func seenAt(seen map[string]time.Time, subdomain string, now time.Time) time.Time {
+	if t, ok := seen[subdomain]; ok {
+		return t
+	}
+	return now
+}
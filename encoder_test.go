@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFragmentWrite_encoders(t *testing.T) {
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tt := map[string]struct {
+		format outputFormat
+		want   string
+	}{
+		"json": {
+			format: formatJSON,
+			want: `{"schema_version":1,"subdomain":"a.example.com","ip":"1.1.1.1","class":"public","timestamp":"2024-05-01T12:00:00Z"}` + "\n" +
+				`{"schema_version":1,"subdomain":"b,example.com","ip":"1.1.1.1","class":"public","timestamp":"2024-05-01T12:00:00Z"}` + "\n" +
+				`{"schema_version":1,"subdomain":"a.example.com","ip":"2.2.2.2","class":"public","timestamp":"2024-05-01T12:00:00Z"}`,
+		},
+		"csv": {
+			format: formatCSV,
+			want: "subdomain,ip,class,timestamp\n" +
+				"a.example.com,1.1.1.1,public,2024-05-01T12:00:00Z\n" +
+				"\"b,example.com\",1.1.1.1,public,2024-05-01T12:00:00Z\n" +
+				"a.example.com,2.2.2.2,public,2024-05-01T12:00:00Z",
+		},
+		"hosts": {
+			format: formatHosts,
+			want:   "1.1.1.1 a.example.com b,example.com\n2.2.2.2 a.example.com",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			frag := fragment{
+				class:  classPublic,
+				format: tc.format,
+				seen:   map[string]time.Time{"a.example.com": seen, "b,example.com": seen},
+				m: addrMap(map[string][]string{
+					"2.2.2.2": {"a.example.com"},
+					"1.1.1.1": {"a.example.com", "b,example.com"},
+				}),
+			}
+			frag.out = frag.writerSink(out)
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFragmentWrite_csvRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "public.csv")
+	out, err := createAtomic(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.maxSize = 80
+
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	frag := fragment{
+		class:  classPublic,
+		format: formatCSV,
+		seen:   map[string]time.Time{"a.example.com": seen, "b.example.com": seen},
+		m: addrMap(map[string][]string{
+			"1.1.1.1": {"a.example.com"},
+			"2.2.2.2": {"b.example.com"},
+		}),
+	}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{
+		path:                 "subdomain,ip,class,timestamp\na.example.com,1.1.1.1,public,2024-05-01T12:00:00Z",
+		rotatedPath(path, 1): "subdomain,ip,class,timestamp\nb.example.com,2.2.2.2,public,2024-05-01T12:00:00Z",
+	}
+	for name, content := range want {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(b) != content {
+			t.Errorf("expected %s to be %q, got %q", name, content, b)
+		}
+	}
+}
+
+func BenchmarkFragmentWrite(b *testing.B) {
+	m := make(map[netip.Addr][]string)
+	for i := range 1 << 14 {
+		ip := netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)})
+		m[ip] = []string{fmt.Sprintf("a%d.example.com", i), fmt.Sprintf("b%d.example.com", i)}
+	}
+
+	for _, format := range []outputFormat{formatList, formatJSON} {
+		b.Run(string(format), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				out, err := createAtomic(filepath.Join(b.TempDir(), "public.txt"))
+				if err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				frag := fragment{m: m, class: classPublic, format: format, sortMode: sortLexical}
+				frag.out = frag.writerSink(out)
+				if err := frag.write(); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+				out.Abort()
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"time"
+)
+
+const (
+	categoryNotFound    = "not_found"
+	categoryServFail    = "servfail"
+	categoryServerError = "server_error"
+	categoryTimeout     = "timeout"
+	categoryTemporary   = "temporary"
+	categoryOther       = "other"
+	categoryNoAddresses = "no_addresses"
+)
+
+// errorCategories is the order categories are written in.
+var errorCategories = []string{
+	categoryNotFound,
+	categoryServFail,
+	categoryServerError,
+	categoryTimeout,
+	categoryTemporary,
+	categoryOther,
+	categoryNoAddresses,
+}
+
+// serverMisbehaving is how the go resolver reports SERVFAIL, REFUSED and
+// other failure rcodes. Only SERVFAIL is marked temporary.
+const serverMisbehaving = "server misbehaving"
+
+func errorCategory(err error) string {
+	if errors.Is(err, errNoAddresses) {
+		return categoryNoAddresses
+	}
+	if errors.Is(err, errNoNames) {
+		return categoryNotFound
+	}
+
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) {
+		return categoryOther
+	}
+
+	switch {
+	case dnsErr.IsNotFound:
+		return categoryNotFound
+	case dnsErr.Err == serverMisbehaving && dnsErr.IsTemporary:
+		return categoryServFail
+	case dnsErr.Err == serverMisbehaving:
+		return categoryServerError
+	case dnsErr.IsTimeout:
+		return categoryTimeout
+	case dnsErr.IsTemporary:
+		return categoryTemporary
+	default:
+		return categoryOther
+	}
+}
+
+func errorResolver(err error) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.Server != "" {
+		return dnsErr.Server
+	}
+	return "system"
+}
+
+type errorEntry struct {
+	SchemaVersion int       `json:"schema_version"`
+	Hostname      string    `json:"hostname"`
+	Category      string    `json:"category"`
+	Resolver      string    `json:"resolver"`
+	Error         string    `json:"error"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+type errorReport struct {
+	entries map[string][]json.RawMessage
+	now     func() time.Time
+}
+
+func newErrorReport() *errorReport {
+	return &errorReport{entries: make(map[string][]json.RawMessage), now: time.Now}
+}
+
+func (r *errorReport) add(string, string, string) {}
+
+func (r *errorReport) fail(subdomain string, err error) {
+	category := errorCategory(err)
+	entry, _ := json.Marshal(errorEntry{
+		SchemaVersion: schemaVersion,
+		Hostname:      subdomain,
+		Category:      category,
+		Resolver:      errorResolver(err),
+		Error:         err.Error(),
+		Timestamp:     r.now().UTC(),
+	})
+	r.entries[category] = append(r.entries[category], entry)
+}
+
+// write groups the entries by category, so that each category is a
+// contiguous section of the output.
+func (r *errorReport) write(out io.Writer) error {
+	categories := slices.Clone(errorCategories)
+	for category := range r.entries {
+		if !slices.Contains(categories, category) {
+			categories = append(categories, category)
+		}
+	}
+	slices.Sort(categories[len(errorCategories):])
+
+	for _, category := range categories {
+		for _, entry := range r.entries[category] {
+			if _, err := out.Write(append(entry, '\n')); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *errorReport) load(in io.Reader) error {
+	return scanLines(in, func(n int, line string) error {
+		var entry errorEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+		r.entries[entry.Category] = append(r.entries[entry.Category], json.RawMessage(line))
+		return nil
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestErrorCategory(t *testing.T) {
+	tt := map[string]struct {
+		err  error
+		want string
+	}{
+		"not found": {err: &net.DNSError{Err: "no such host", IsNotFound: true}, want: categoryNotFound},
+		"timeout":   {err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}, want: categoryTimeout},
+		"servfail":  {err: &net.DNSError{Err: "server misbehaving", IsTemporary: true}, want: categoryServFail},
+		"refused":   {err: &net.DNSError{Err: "server misbehaving"}, want: categoryServerError},
+		"temporary": {err: &net.DNSError{Err: "connection refused", IsTemporary: true}, want: categoryTemporary},
+		"other":     {err: errors.New("boom"), want: categoryOther},
+		"no addrs":  {err: errNoAddresses, want: categoryNoAddresses},
+		"no names":  {err: errNoNames, want: categoryNotFound},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := errorCategory(tc.err); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestErrorReport(t *testing.T) {
+	r := newErrorReport()
+	r.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	r.fail("a.example.com", &net.DNSError{Err: "no such host", Name: "a.example.com", Server: "1.1.1.1:53", IsNotFound: true})
+	r.fail("c.example.com", &net.DNSError{Err: "server misbehaving", Name: "c.example.com", Server: "1.1.1.1:53", IsTemporary: true})
+	r.fail("b.example.com", errNoAddresses)
+	r.fail("d.example.com", &net.DNSError{Err: "no such host", Name: "d.example.com", Server: "1.1.1.1:53", IsNotFound: true})
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `{"schema_version":1,"hostname":"a.example.com","category":"not_found","resolver":"1.1.1.1:53","error":"lookup a.example.com on 1.1.1.1:53: no such host","timestamp":"2024-01-02T03:04:05Z"}` + "\n" +
+		`{"schema_version":1,"hostname":"d.example.com","category":"not_found","resolver":"1.1.1.1:53","error":"lookup d.example.com on 1.1.1.1:53: no such host","timestamp":"2024-01-02T03:04:05Z"}` + "\n" +
+		`{"schema_version":1,"hostname":"c.example.com","category":"servfail","resolver":"1.1.1.1:53","error":"lookup c.example.com on 1.1.1.1:53: server misbehaving","timestamp":"2024-01-02T03:04:05Z"}` + "\n" +
+		`{"schema_version":1,"hostname":"b.example.com","category":"no_addresses","resolver":"system","error":"no addresses of the requested ip versions","timestamp":"2024-01-02T03:04:05Z"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+const expandApex = "apex"
+
+var labelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func parseExpansions(s string) ([]string, error) {
+	var rules []string
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		if rule != expandApex && !labelPattern.MatchString(rule) {
+			return nil, fmt.Errorf("%q is neither apex nor a hostname label", rule)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+type expansion struct {
+	name string
+	rule string
+}
+
+func expansions(host string, rules []string) []expansion {
+	apex := zoneOf(host)
+	if isPublicSuffix(apex) {
+		return nil
+	}
+	var out []expansion
+	for _, rule := range rules {
+		name := apex
+		if rule != expandApex {
+			name = rule + "." + apex
+		}
+		if !strings.EqualFold(name, host) {
+			out = append(out, expansion{name: name, rule: rule})
+		}
+	}
+	return out
+}
+
+type expandReport interface {
+	expanded(subdomain string, rule string, source string)
+}
+
+// expand resolves the -expand names of host once per run. Names that don't
+// resolve are skipped without being reported as failures.
+func (m *ipSubMap) expand(host string) {
+	if len(m.expansions) == 0 {
+		return
+	}
+	if m.seen == nil {
+		m.seen = map[string]bool{host: true}
+	}
+	if p := m.pending; p != nil && p.expansions && p.line == host {
+		// The names follow host through the pipeline, already resolved.
+		p.expand = true
+		return
+	}
+
+	for _, e := range expansions(host, m.expansions) {
+		m.resolveCandidate(e.name, e.rule, host, nil)
+	}
+}
+
+// resolveCandidate resolves a generated hostname once per run, and records it
+// as expanded from source when it answers with something other than the
+// wildcard addresses.
+func (m *ipSubMap) resolveCandidate(name string, kind string, source string, wildcard map[string]bool) {
+	if m.seen[name] {
+		return
+	}
+	m.seen[name] = true
+
+	if !m.allowsHost(name, "") || m.overBudget(name) {
+		return
+	}
+	ips, err := m.resolveIPs(name)
+	if err != nil || onlyWildcard(ips, wildcard) || !m.recordAll(name, ips) {
+		return
+	}
+	for _, r := range m.reports {
+		if x, ok := r.report.(expandReport); ok {
+			x.expanded(name, kind, source)
+		}
+	}
+}
+
+type expansionReport struct {
+	lines []string
+}
+
+func newExpansionReport() *expansionReport {
+	return &expansionReport{}
+}
+
+func (r *expansionReport) add(string, string, string) {}
+
+func (r *expansionReport) expanded(subdomain string, rule string, source string) {
+	r.lines = append(r.lines, fmt.Sprintf("%s %s %s", subdomain, rule, source))
+}
+
+func (r *expansionReport) write(out io.Writer) error {
+	_, err := io.WriteString(out, strings.Join(r.lines, "\n"))
+	return err
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseExpansions(t *testing.T) {
+	tt := map[string]struct {
+		in   string
+		want []string
+		err  bool
+	}{
+		"apex and www": {in: "apex, WWW", want: []string{"apex", "www"}},
+		"label":        {in: "mail", want: []string{"mail"}},
+		"invalid":      {in: "apex,*.dev", err: true},
+		"empty":        {in: "apex,", err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseExpansions(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestExpansions(t *testing.T) {
+	tt := map[string]struct {
+		host string
+		want []expansion
+	}{
+		"subdomain": {
+			host: "api.example.com",
+			want: []expansion{{name: "example.com", rule: "apex"}, {name: "www.example.com", rule: "www"}},
+		},
+		"second level suffix": {
+			host: "api.example.co.uk",
+			want: []expansion{{name: "example.co.uk", rule: "apex"}, {name: "www.example.co.uk", rule: "www"}},
+		},
+		"apex": {
+			host: "www.example.com.au",
+			want: []expansion{{name: "example.com.au", rule: "apex"}},
+		},
+		"public suffix": {
+			host: "co.uk",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := expansions(tc.host, []string{"apex", "www"}); !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestIPSubMapExpand(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "api.example.com", "example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		case "www.example.com":
+			return []net.IP{net.ParseIP("93.184.216.35")}, nil
+		default:
+			return nil, fmt.Errorf("no such host")
+		}
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	report := newExpansionReport()
+	unresolved := newUnresolvedReport()
+	m := &ipSubMap{
+		ipv4:       true,
+		expansions: []string{"apex", "www", "mail"},
+		public:     fragment{m: make(map[netip.Addr][]string)},
+		reports: []reportOutput{
+			{name: "expanded", report: report},
+			{name: "unresolved", report: unresolved},
+		},
+	}
+	if err := m.enumerate(strings.NewReader("api.example.com\nwww.example.com\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := m.public.m[netip.MustParseAddr("93.184.216.34")]; !slices.Equal(got, []string{"api.example.com", "example.com"}) {
+		t.Errorf("expected the apex to be recorded, got %q", got)
+	}
+
+	out := &bytes.Buffer{}
+	if err := report.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "example.com apex api.example.com\nwww.example.com www api.example.com"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+	if len(unresolved.names) != 0 {
+		t.Errorf("expected unresolved expansions not to be reported, got %v", unresolved.names)
+	}
+}
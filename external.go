@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+)
+
+var lookupCNAME = net.LookupCNAME
+
+type cnameReport interface {
+	cname(subdomain string, target string)
+}
+
+// canonicalName returns the name subdomain is an alias of, or "" when it is
+// not an alias.
+func (m *ipSubMap) canonicalName(subdomain string) string {
+	target, err := "", error(nil)
+	if p := m.pending; p != nil && p.cnameLooked && p.line == subdomain {
+		target, err = p.cname, p.cnameErr
+	} else {
+		target, err = lookupCNAME(subdomain)
+	}
+	target = strings.ToLower(strings.TrimSuffix(target, "."))
+	if err != nil || target == "" || target == strings.ToLower(strings.TrimSuffix(subdomain, ".")) {
+		return ""
+	}
+	return target
+}
+
+func (m *ipSubMap) cname(subdomain string, target string) {
+	for _, r := range m.reports {
+		if c, ok := r.report.(cnameReport); ok {
+			c.cname(subdomain, target)
+		}
+	}
+}
+
+type externalEntry struct {
+	domain    string
+	subdomain string
+	target    string
+}
+
+// externalReport lists the aliases pointing outside of the registered domain
+// of the subdomain, such as SaaS, CDN and other third party services.
+type externalReport struct {
+	entries []externalEntry
+	seen    map[externalEntry]bool
+}
+
+func newExternalReport() *externalReport {
+	return &externalReport{seen: make(map[externalEntry]bool)}
+}
+
+func (r *externalReport) add(string, string, string) {}
+
+func (r *externalReport) cname(subdomain string, target string) {
+	domain := zoneOf(target)
+	if domain == zoneOf(subdomain) {
+		return
+	}
+	e := externalEntry{domain: domain, subdomain: subdomain, target: target}
+	if !r.seen[e] {
+		r.seen[e] = true
+		r.entries = append(r.entries, e)
+	}
+}
+
+func (r *externalReport) write(out io.Writer) error {
+	slices.SortFunc(r.entries, func(a, b externalEntry) int {
+		return strings.Compare(a.domain+" "+a.subdomain+" "+a.target, b.domain+" "+b.subdomain+" "+b.target)
+	})
+
+	w := bufio.NewWriter(out)
+	for _, e := range r.entries {
+		fmt.Fprintf(w, "%s %s %s\n", e.domain, e.subdomain, e.target)
+	}
+	return w.Flush()
+}
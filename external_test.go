@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestIPSubMapExternal(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+	lookupCNAME = func(host string) (string, error) {
+		switch host {
+		case "shop.example.com":
+			return "shops.myshopify.com.", nil
+		case "www.example.com":
+			return "lb.example.com.", nil
+		}
+		return host + ".", nil
+	}
+	defer func() {
+		lookupIP = net.LookupIP
+		lookupCNAME = net.LookupCNAME
+	}()
+
+	tt := map[string]int{"sequential": 1, "concurrent": 4}
+	for name, workers := range tt {
+		t.Run(name, func(t *testing.T) {
+			report := newExternalReport()
+			m := &ipSubMap{
+				ipv4:         true,
+				workers:      workers,
+				followCNAMEs: true,
+				private:      fragment{m: make(map[netip.Addr][]string)},
+				reports:      []reportOutput{{name: "external", report: report}},
+			}
+			in := "shop.example.com\nwww.example.com\napi.example.com\nshop.example.com\n"
+			if err := m.enumerate(strings.NewReader(in)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			out := &bytes.Buffer{}
+			if err := report.write(out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := "myshopify.com shop.example.com shops.myshopify.com\n"; out.String() != want {
+				t.Errorf("expected %q, got %q", want, out.String())
+			}
+		})
+	}
+}
+
+func TestIPSubMapExternal_resolvedInput(t *testing.T) {
+	report := newExternalReport()
+	m := &ipSubMap{
+		ipv4:    true,
+		input:   inputMassdns,
+		public:  fragment{m: make(map[netip.Addr][]string)},
+		reports: []reportOutput{{name: "external", report: report}},
+	}
+	in := "docs.example.com. CNAME example.github.io.\nexample.github.io. A 185.199.108.153\n"
+	if err := m.enumerate(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	report.write(out)
+	if want := "github.io docs.example.com example.github.io\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestExternalReport_secondLevelSuffix(t *testing.T) {
+	report := newExternalReport()
+	report.cname("a.example.co.uk", "b.other.co.uk")
+	report.cname("a.example.co.uk", "b.other.co.uk")
+	report.cname("www.example.co.uk", "lb.example.co.uk")
+
+	out := &bytes.Buffer{}
+	report.write(out)
+	if want := "other.co.uk a.example.co.uk b.other.co.uk\n"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// downloadClient fetches remote inputs. Unlike httpClient it has no overall
+// timeout, so large inputs are not cut off mid-body.
+var downloadClient = &http.Client{Transport: downloadTransport()}
+
+func downloadTransport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.ResponseHeaderTimeout = 30 * time.Second
+	return t
+}
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+func parseHeader(s string) (string, string, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid header %q: expected \"Name: value\"", s)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// openInput opens a local input file, or downloads an http(s) one to a
+// temporary file first so slow lookups don't hold the connection open.
+func openInput(path string, header string) (io.ReadCloser, error) {
+	if !isURL(path) {
+		return os.Open(path)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	if header != "" {
+		name, value, err := parseHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(name, value)
+	}
+
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("failed to fetch %s: unexpected status %s", path, resp.Status)
+	}
+
+	f, err := os.CreateTemp("", "ipsubmap-input-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp := &tempInput{File: f}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to fetch %s: %v", path, err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	return tmp, nil
+}
+
+type tempInput struct {
+	*os.File
+}
+
+func (t *tempInput) Close() error {
+	err := t.File.Close()
+	os.Remove(t.Name())
+	return err
+}
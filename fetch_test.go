@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOpenInput_url(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "a.example.com\nb.example.com\n")
+	}))
+	defer srv.Close()
+
+	tt := map[string]struct {
+		header string
+		want   string
+		err    bool
+	}{
+		"authorized":   {header: "Authorization: Bearer secret", want: "a.example.com\nb.example.com\n"},
+		"unauthorized": {err: true},
+		"bad header":   {header: "Authorization", err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			in, err := openInput(srv.URL+"/scope.txt", tc.header)
+			if tc.err {
+				if err == nil {
+					in.Close()
+					t.Error("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			b, err := io.ReadAll(in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(b) != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, b)
+			}
+
+			tmp := in.(*tempInput).Name()
+			in.Close()
+			if _, err := os.Stat(tmp); !os.IsNotExist(err) {
+				t.Errorf("expected temporary file to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestOpenInput_slowDownload(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, line := range []string{"a.example.com\n", "b.example.com\n"} {
+			io.WriteString(w, line)
+			w.(http.Flusher).Flush()
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	defer func(timeout time.Duration) { httpClient.Timeout = timeout }(httpClient.Timeout)
+	httpClient.Timeout = 50 * time.Millisecond
+
+	in, err := openInput(srv.URL, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer in.Close()
+	b, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(b) != "a.example.com\nb.example.com\n" {
+		t.Errorf("unexpected input %q", b)
+	}
+}
+
+func TestIPSubMapClose_input(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "a.example.com\n")
+	}))
+	defer srv.Close()
+
+	in, err := openInput(srv.URL, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m := &ipSubMap{in: in}
+	m.close()
+	if _, err := os.Stat(in.(*tempInput).Name()); !os.IsNotExist(err) {
+		t.Errorf("expected temporary file to be removed, got %v", err)
+	}
+}
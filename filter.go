@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type prefixList []netip.Prefix
+
+func (l *prefixList) String() string {
+	if l == nil {
+		return ""
+	}
+	s := make([]string, len(*l))
+	for i, prefix := range *l {
+		s[i] = prefix.String()
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *prefixList) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		prefix, ok := parsePrefix(strings.TrimSpace(entry))
+		if !ok {
+			return fmt.Errorf("invalid CIDR %q", entry)
+		}
+		*l = append(*l, prefix)
+	}
+	return nil
+}
+
+// loadIgnoreList reads ip addresses and CIDRs, one per line.
+func loadIgnoreList(path string) (prefixList, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	l := prefixList{}
+	err = scanLines(in, func(n int, line string) error {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return nil
+		}
+		prefix, ok := parsePrefix(line)
+		if !ok {
+			return fmt.Errorf("invalid ip address or CIDR %q on line %d", line, n)
+		}
+		l = append(l, prefix)
+		return nil
+	})
+	return l, err
+}
+
+func (l prefixList) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	for _, prefix := range l {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type cidrFilter struct {
+	include prefixList
+	exclude prefixList
+}
+
+func (f *cidrFilter) allows(addr netip.Addr) (bool, string) {
+	addr = addr.Unmap()
+
+	for _, prefix := range f.exclude {
+		if prefix.Contains(addr) {
+			return false, fmt.Sprintf("address excluded by -exclude-cidr %s", prefix)
+		}
+	}
+
+	if len(f.include) == 0 {
+		return true, ""
+	}
+	for _, prefix := range f.include {
+		if prefix.Contains(addr) {
+			return true, ""
+		}
+	}
+	return false, "address not in -include-cidr"
+}
+
+type hostFilter struct {
+	match   *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func (f *hostFilter) allows(host string) (bool, string) {
+	if f.match != nil && !f.match.MatchString(host) {
+		return false, "host does not match -match"
+	}
+	if f.exclude != nil && f.exclude.MatchString(host) {
+		return false, "host matches -exclude"
+	}
+	return true, ""
+}
+
+type exclusionList struct {
+	path     string
+	modTime  time.Time
+	patterns []string
+}
+
+func loadExclusionList(path string) (*exclusionList, error) {
+	l := &exclusionList{path: path}
+	if err := l.reload(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *exclusionList) reload() error {
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to stat exclusion list: %v", err)
+	}
+	if info.ModTime().Equal(l.modTime) {
+		return nil
+	}
+
+	in, err := os.Open(l.path)
+	if err != nil {
+		return fmt.Errorf("failed to open exclusion list: %v", err)
+	}
+	defer in.Close()
+
+	var patterns []string
+	err = scanLines(in, func(n int, line string) error {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return nil
+		}
+		pattern := normalizeHost(line)
+		if pattern == "*." || strings.Contains(strings.TrimPrefix(pattern, "*."), "*") {
+			return fmt.Errorf("invalid exclusion %q on line %d", line, n)
+		}
+		patterns = append(patterns, pattern)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read exclusion list: %v", err)
+	}
+
+	l.patterns = patterns
+	l.modTime = info.ModTime()
+	return nil
+}
+
+func (l *exclusionList) allows(host string) (bool, string) {
+	host = normalizeHost(host)
+	for _, pattern := range l.patterns {
+		if matchHost(pattern, host) {
+			return false, fmt.Sprintf("host excluded by -exclude-file entry %q", pattern)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCIDRFilter(t *testing.T) {
+	var f cidrFilter
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(&f.include, "include-cidr", "")
+	fs.Var(&f.exclude, "exclude-cidr", "")
+	if err := fs.Parse([]string{
+		"-include-cidr", "10.0.0.0/8,2001:db8::/32",
+		"-include-cidr", "192.0.2.1",
+		"-exclude-cidr", "10.1.0.0/16",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ips := map[string]bool{
+		"10.0.0.1":        true,
+		"::ffff:10.0.0.1": true,
+		"10.1.2.3":        false,
+		"192.0.2.1":       true,
+		"192.0.2.2":       false,
+		"2001:db8::1":     true,
+		"1.1.1.1":         false,
+	}
+	for ip, want := range ips {
+		if got, _ := f.allows(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("allows(%q): expected %v, got %v", ip, want, got)
+		}
+	}
+
+	if err := f.include.Set("not-a-cidr"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+}
+
+func TestHostFilter(t *testing.T) {
+	f := hostFilter{
+		match:   regexp.MustCompile(`.*\.corp\.example\.com$`),
+		exclude: regexp.MustCompile(`^staging\.`),
+	}
+
+	hosts := map[string]bool{
+		"vpn.corp.example.com":     true,
+		"staging.corp.example.com": false,
+		"www.example.com":          false,
+	}
+	for host, want := range hosts {
+		if got, _ := f.allows(host); got != want {
+			t.Errorf("allows(%q): expected %v, got %v", host, want, got)
+		}
+	}
+}
+
+func TestExclusionList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known-good.txt")
+	if err := os.WriteFile(path, []byte("# triaged\n*.cdn.example.com\nwww.example.com\n"), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+
+	l, err := loadExclusionList(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hosts := map[string]bool{
+		"a.cdn.example.com": false,
+		"WWW.example.com":   false,
+		"api.example.com":   true,
+	}
+	for host, want := range hosts {
+		if got, _ := l.allows(host); got != want {
+			t.Errorf("allows(%q): expected %v, got %v", host, want, got)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("api.example.com\n"), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("failed to touch %q: %v", path, err)
+	}
+	if err := l.reload(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ok, _ := l.allows("api.example.com"); ok {
+		t.Error("expected reloaded exclusion to apply")
+	}
+	if ok, _ := l.allows("www.example.com"); !ok {
+		t.Error("expected removed exclusion to no longer apply")
+	}
+}
+
+func TestIgnoreList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "known.txt")
+	if err := os.WriteFile(path, []byte("# cdn frontends\n104.16.0.0/13\n1.1.1.1\n2001:db8::/32\n"), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+	l, err := loadIgnoreList(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	m := &ipSubMap{
+		ipv4:    true,
+		ipv6:    true,
+		ignored: l,
+		public:  fragment{m: make(map[netip.Addr][]string)},
+	}
+	m.record("a.example.com", netip.MustParseAddr("104.17.1.1"))
+	m.record("b.example.com", netip.MustParseAddr("::ffff:1.1.1.1"))
+	m.record("c.example.com", netip.MustParseAddr("2001:db8::1"))
+	m.record("d.example.com", netip.MustParseAddr("8.8.8.8"))
+
+	if len(m.public.m) != 1 || m.public.m[netip.MustParseAddr("8.8.8.8")] == nil {
+		t.Errorf("expected only 8.8.8.8 to be recorded, got %v", m.public.m)
+	}
+	if m.ignoredIPs != 3 || m.droppedIPs != 0 {
+		t.Errorf("expected 3 ignored and no dropped results, got %d and %d", m.ignoredIPs, m.droppedIPs)
+	}
+
+	if err := os.WriteFile(path, []byte("cdn.example.com\n"), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", path, err)
+	}
+	if _, err := loadIgnoreList(path); err == nil {
+		t.Error("expected error for a hostname")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type outputFormat string
+
+const (
+	formatList  outputFormat = "list"
+	formatHosts outputFormat = "hosts"
+
+	formatAnsible     outputFormat = "ansible"
+	formatAnsibleYAML outputFormat = "ansible-yaml"
+	formatZone        outputFormat = "zone"
+
+	formatProto   outputFormat = "proto"
+	formatParquet outputFormat = "parquet"
+	formatMsgpack outputFormat = "msgpack"
+	formatSQLite  outputFormat = "sqlite"
+
+	formatJSON outputFormat = "json"
+	formatCSV  outputFormat = "csv"
+)
+
+const zoneTTL = 3600
+
+func parseFormat(s string) (outputFormat, error) {
+	switch format := outputFormat(s); format {
+	case formatList, formatHosts, formatAnsible, formatAnsibleYAML, formatZone, formatProto, formatParquet, formatMsgpack, formatSQLite, formatJSON, formatCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown output format %q", s)
+	}
+}
+
+func (f outputFormat) separator() string {
+	if f == formatHosts {
+		return " "
+	}
+	return ","
+}
+
+func (f *fragment) separators() (string, string) {
+	ipSep, subSep := f.ipSep, f.subSep
+	if ipSep == "" {
+		ipSep = " "
+	}
+	if subSep == "" {
+		subSep = f.format.separator()
+	}
+	return ipSep, subSep
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\t`, "\t", `\\`, `\`).Replace(s)
+}
+
+func validateSeparators(ipSep string, subSep string) error {
+	if ipSep == "" {
+		return fmt.Errorf("-ip-sep must not be empty")
+	}
+	if strings.ContainsAny(ipSep+subSep, "\r\n") {
+		return fmt.Errorf("-ip-sep and -sub-sep must not contain line breaks")
+	}
+	if subSep != "" && strings.Contains(ipSep, subSep) {
+		return fmt.Errorf("-ip-sep must not contain -sub-sep")
+	}
+	return nil
+}
+
+// inventory reports whether the format is written per hostname instead of
+// per ip address.
+func (f outputFormat) inventory() bool {
+	return f == formatAnsible || f == formatAnsibleYAML || f == formatZone
+}
+
+// binary reports whether the format is not line based.
+func (f outputFormat) binary() bool {
+	return f == formatProto || f == formatParquet || f == formatMsgpack
+}
+
+// structured reports whether the format cannot be read back by -append, and
+// has no room for -template or the -group-by-prefix headers.
+func (f outputFormat) structured() bool {
+	return f.binary() || f == formatJSON || f == formatCSV
+}
+
+// contentType returns the media type uploads in the format are stored with.
+func (f outputFormat) contentType() string {
+	switch f {
+	case formatAnsibleYAML:
+		return "application/yaml"
+	case formatProto:
+		return "application/x-protobuf"
+	case formatParquet:
+		return "application/vnd.apache.parquet"
+	case formatMsgpack:
+		return "application/msgpack"
+	case formatSQLite:
+		return "application/vnd.sqlite3"
+	case formatJSON:
+		return "application/x-ndjson"
+	case formatCSV:
+		return "text/csv"
+	default:
+		return "text/plain"
+	}
+}
+
+// records reports whether the format writes one record per subdomain and ip
+// address, including the time the subdomain was first resolved.
+func (f outputFormat) records() bool {
+	return f == formatParquet || f == formatMsgpack || f == formatJSON || f == formatCSV
+}
+
+// writeInventory writes the hosts in m grouped under group. When seen is not
+// nil, every record includes the time its hostname was first resolved.
+func writeInventory(out io.Writer, format outputFormat, group string, m map[string][]string, seen map[string]time.Time) error {
+	hosts := invert(m)
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	w := bufio.NewWriter(out)
+	if format == formatZone {
+		fmt.Fprintf(w, "; %s\n", group)
+		for _, name := range names {
+			for _, ip := range hosts[name] {
+				rtype := "A"
+				if strings.Contains(ip, ":") {
+					rtype = "AAAA"
+				}
+				fmt.Fprintf(w, "%s.\t%d\tIN\t%s\t%s", name, zoneTTL, rtype, ip)
+				if t, ok := seen[name]; ok {
+					fmt.Fprintf(w, "\t; seen %s", formatSeen(t))
+				}
+				fmt.Fprintln(w)
+			}
+		}
+		return w.Flush()
+	}
+	if format == formatAnsibleYAML {
+		fmt.Fprintf(w, "%s:\n  hosts:\n", group)
+		for _, name := range names {
+			ips := hosts[name]
+			fmt.Fprintf(w, "    %s:\n      ansible_host: %s\n", strconv.Quote(name), strconv.Quote(ips[0]))
+			if len(ips) > 1 {
+				fmt.Fprintf(w, "      ipsubmap_addresses: [%s]\n", quoteAll(ips))
+			}
+			if t, ok := seen[name]; ok {
+				fmt.Fprintf(w, "      ipsubmap_seen: %s\n", strconv.Quote(formatSeen(t)))
+			}
+		}
+		return w.Flush()
+	}
+
+	fmt.Fprintf(w, "[%s]\n", group)
+	for _, name := range names {
+		ips := hosts[name]
+		fmt.Fprintf(w, "%s ansible_host=%s", name, ips[0])
+		if len(ips) > 1 {
+			fmt.Fprintf(w, " ipsubmap_addresses=%s", strings.Join(ips, ","))
+		}
+		if t, ok := seen[name]; ok {
+			fmt.Fprintf(w, " ipsubmap_seen=%s", formatSeen(t))
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+func formatSeen(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func quoteAll(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ", ")
+}
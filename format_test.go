@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteInventory(t *testing.T) {
+	m := map[string][]string{
+		"10.0.0.2": {"b.example.com"},
+		"10.0.0.1": {"a.example.com", "b.example.com"},
+	}
+
+	seen := map[string]time.Time{
+		"a.example.com": time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	tt := map[string]struct {
+		format outputFormat
+		seen   map[string]time.Time
+		want   string
+	}{
+		"ini": {
+			format: formatAnsible,
+			want: "[private]\n" +
+				"a.example.com ansible_host=10.0.0.1\n" +
+				"b.example.com ansible_host=10.0.0.1 ipsubmap_addresses=10.0.0.1,10.0.0.2\n",
+		},
+		"yaml": {
+			format: formatAnsibleYAML,
+			want: "private:\n  hosts:\n" +
+				"    \"a.example.com\":\n      ansible_host: \"10.0.0.1\"\n" +
+				"    \"b.example.com\":\n      ansible_host: \"10.0.0.1\"\n      ipsubmap_addresses: [\"10.0.0.1\", \"10.0.0.2\"]\n",
+		},
+		"zone": {
+			format: formatZone,
+			want: "; private\n" +
+				"a.example.com.\t3600\tIN\tA\t10.0.0.1\n" +
+				"b.example.com.\t3600\tIN\tA\t10.0.0.1\n" +
+				"b.example.com.\t3600\tIN\tA\t10.0.0.2\n",
+		},
+		"ini timestamps": {
+			format: formatAnsible,
+			seen:   seen,
+			want: "[private]\n" +
+				"a.example.com ansible_host=10.0.0.1 ipsubmap_seen=2024-05-01T12:00:00Z\n" +
+				"b.example.com ansible_host=10.0.0.1 ipsubmap_addresses=10.0.0.1,10.0.0.2\n",
+		},
+		"yaml timestamps": {
+			format: formatAnsibleYAML,
+			seen:   seen,
+			want: "private:\n  hosts:\n" +
+				"    \"a.example.com\":\n      ansible_host: \"10.0.0.1\"\n      ipsubmap_seen: \"2024-05-01T12:00:00Z\"\n" +
+				"    \"b.example.com\":\n      ansible_host: \"10.0.0.1\"\n      ipsubmap_addresses: [\"10.0.0.1\", \"10.0.0.2\"]\n",
+		},
+		"zone timestamps": {
+			format: formatZone,
+			seen:   seen,
+			want: "; private\n" +
+				"a.example.com.\t3600\tIN\tA\t10.0.0.1\t; seen 2024-05-01T12:00:00Z\n" +
+				"b.example.com.\t3600\tIN\tA\t10.0.0.1\n" +
+				"b.example.com.\t3600\tIN\tA\t10.0.0.2\n",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			if err := writeInventory(out, tc.format, classPrivate, m, tc.seen); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFragmentWrite_separators(t *testing.T) {
+	tt := map[string]struct {
+		format outputFormat
+		ipSep  string
+		subSep string
+		want   string
+	}{
+		"default": {
+			format: formatList,
+			want:   "10.0.0.1 a.example.com,b.example.com",
+		},
+		"hosts": {
+			format: formatHosts,
+			want:   "10.0.0.1 a.example.com b.example.com",
+		},
+		"custom": {
+			format: formatList,
+			ipSep:  "\t",
+			subSep: ";",
+			want:   "10.0.0.1\ta.example.com;b.example.com",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			frag := fragment{
+				m:      make(map[netip.Addr][]string),
+				format: tc.format,
+				ipSep:  tc.ipSep,
+				subSep: tc.subSep,
+			}
+			frag.out = frag.writerSink(out)
+			if err := frag.load(strings.NewReader(tc.want)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+			if got := frag.m[netip.MustParseAddr("10.0.0.1")]; len(got) != 2 {
+				t.Errorf("expected 2 subdomains to be loaded, got %q", got)
+			}
+		})
+	}
+}
+
+func TestValidateSeparators(t *testing.T) {
+	tt := map[string]struct {
+		ipSep  string
+		subSep string
+		err    bool
+	}{
+		"tab and semicolon": {ipSep: "\t", subSep: ";"},
+		"empty ip sep":      {ipSep: "", subSep: ",", err: true},
+		"line break":        {ipSep: " ", subSep: "\n", err: true},
+		"overlap":           {ipSep: ", ", subSep: ",", err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			err := validateSeparators(tc.ipSep, tc.subSep)
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/netip"
+	"strconv"
+)
+
+type prefixGroupWriter struct {
+	w     *encoderSink
+	bits4 int
+	bits6 int
+
+	current netip.Prefix
+	keys    []string
+	values  [][]string
+	header  []byte
+}
+
+func (g *prefixGroupWriter) prefix(key string) netip.Prefix {
+	addr, err := netip.ParseAddr(key)
+	if err != nil {
+		return netip.Prefix{}
+	}
+	addr = addr.Unmap()
+
+	bits := g.bits6
+	if addr.Is4() {
+		bits = g.bits4
+	}
+	if bits <= 0 {
+		bits = addr.BitLen()
+	}
+
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}
+	}
+	return prefix
+}
+
+func (g *prefixGroupWriter) write(r record) error {
+	prefix := g.prefix(r.key)
+	if prefix != g.current && len(g.keys) > 0 {
+		if err := g.flush(); err != nil {
+			return err
+		}
+	}
+	g.current = prefix
+	g.keys = append(g.keys, r.key)
+	g.values = append(g.values, r.values)
+	return nil
+}
+
+func (g *prefixGroupWriter) flush() error {
+	subdomains := make(map[string]struct{})
+	for _, values := range g.values {
+		for _, v := range values {
+			subdomains[v] = struct{}{}
+		}
+	}
+
+	name := "other"
+	if g.current.IsValid() {
+		name = g.current.String()
+	}
+	header := append(g.header[:0], "# "...)
+	header = append(header, name...)
+	header = append(header, ' ')
+	header = strconv.AppendInt(header, int64(len(g.keys)), 10)
+	header = append(header, " ips "...)
+	header = strconv.AppendInt(header, int64(len(subdomains)), 10)
+	header = append(header, " subdomains"...)
+	g.header = header
+	if err := g.w.entry(header); err != nil {
+		return err
+	}
+
+	for i, key := range g.keys {
+		if err := g.w.write(record{key: key, values: g.values[i]}); err != nil {
+			return err
+		}
+	}
+
+	g.keys = g.keys[:0]
+	g.values = g.values[:0]
+	return nil
+}
+
+func (g *prefixGroupWriter) close() error {
+	if len(g.keys) > 0 {
+		if err := g.flush(); err != nil {
+			return err
+		}
+	}
+	return g.w.close()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestFragmentWrite_groupByPrefix(t *testing.T) {
+	tt := map[string]struct {
+		m    map[string][]string
+		bits int
+		want string
+	}{
+		"ipv4": {
+			m: map[string][]string{
+				"192.0.2.1":    {"a.example.com"},
+				"192.0.2.9":    {"a.example.com", "b.example.com"},
+				"198.51.100.7": {"c.example.com"},
+			},
+			bits: 24,
+			want: "# 192.0.2.0/24 2 ips 2 subdomains\n" +
+				"192.0.2.1 a.example.com\n" +
+				"192.0.2.9 a.example.com,b.example.com\n" +
+				"# 198.51.100.0/24 1 ips 1 subdomains\n" +
+				"198.51.100.7 c.example.com",
+		},
+		"ipv6": {
+			m: map[string][]string{
+				"2001:db8::1":   {"a.example.com"},
+				"2001:db8:1::1": {"b.example.com"},
+			},
+			bits: 24,
+			want: "# 2001:db8::/64 1 ips 1 subdomains\n" +
+				"2001:db8::1 a.example.com\n" +
+				"# 2001:db8:1::/64 1 ips 1 subdomains\n" +
+				"2001:db8:1::1 b.example.com",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			frag := fragment{
+				m:          addrMap(tc.m),
+				sortMode:   sortNumeric,
+				groupBits4: tc.bits,
+				groupBits6: 64,
+			}
+			frag.out = frag.writerSink(out)
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+
+			loaded := fragment{m: make(map[netip.Addr][]string)}
+			if err := loaded.load(bytes.NewReader(out.Bytes())); err != nil {
+				t.Fatalf("unexpected load error: %v", err)
+			}
+			if len(loaded.m) != len(tc.m) {
+				t.Errorf("expected %d loaded entries, got %d", len(tc.m), len(loaded.m))
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+type inputFormat string
+
+const (
+	inputText    inputFormat = "text"
+	inputMassdns inputFormat = "massdns"
+	inputDnsx    inputFormat = "dnsx"
+	inputAmass   inputFormat = "amass"
+	inputCSV     inputFormat = "csv"
+)
+
+func parseInputFormat(s string) (inputFormat, error) {
+	switch format := inputFormat(s); format {
+	case inputText, inputMassdns, inputDnsx, inputAmass, inputCSV:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown input format %q", s)
+	}
+}
+
+func (f inputFormat) resolved() bool {
+	return f != inputText && f != inputCSV
+}
+
+// answer records an already resolved A or AAAA record, or a CNAME whose
+// target's addresses are then recorded for the alias too.
+func (m *ipSubMap) answer(name string, rtype string, data string) error {
+	name = strings.TrimSuffix(name, ".")
+	data = strings.TrimSuffix(data, ".")
+
+	switch strings.ToUpper(rtype) {
+	case "CNAME":
+		if m.cnames == nil {
+			m.cnames = make(map[string][]string)
+		}
+		m.cnames[data] = append(m.cnames[data], name)
+		m.cname(strings.ToLower(name), strings.ToLower(data))
+	case "A", "AAAA":
+		ip, err := netip.ParseAddr(data)
+		if err != nil {
+			return fmt.Errorf("invalid ip address %q for %q", data, name)
+		}
+		if ip = ip.Unmap(); !m.wantsAddr(ip) {
+			return nil
+		}
+		for _, alias := range m.aliases(name) {
+			if m.allowsHost(alias, "") {
+				m.record(alias, ip)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *ipSubMap) aliases(name string) []string {
+	names := []string{name}
+	seen := map[string]bool{name: true}
+	for i := 0; i < len(names); i++ {
+		for _, alias := range m.cnames[names[i]] {
+			if !seen[alias] {
+				seen[alias] = true
+				names = append(names, alias)
+			}
+		}
+	}
+	return names
+}
+
+// massdnsLine parses a line of massdns -o S output: "name. TYPE data".
+func (m *ipSubMap) massdnsLine(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	if len(fields) < 3 {
+		return fmt.Errorf("malformed massdns line %q", line)
+	}
+	return m.answer(fields[0], fields[1], fields[2])
+}
+
+type dnsxRecord struct {
+	Host  string   `json:"host"`
+	A     []string `json:"a"`
+	AAAA  []string `json:"aaaa"`
+	CNAME []string `json:"cname"`
+}
+
+// dnsxLine parses a line of dnsx -json output.
+func (m *ipSubMap) dnsxLine(line string) error {
+	var r dnsxRecord
+	if err := json.Unmarshal([]byte(line), &r); err != nil {
+		return fmt.Errorf("malformed dnsx line %q: %v", line, err)
+	}
+	if r.Host == "" {
+		return fmt.Errorf("dnsx line without host: %q", line)
+	}
+
+	for _, target := range r.CNAME {
+		m.answer(r.Host, "CNAME", target)
+	}
+
+	var errs []error
+	for _, ip := range r.A {
+		if err := m.answer(r.Host, "A", ip); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, ip := range r.AAAA {
+		if err := m.answer(r.Host, "AAAA", ip); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type amassRecord struct {
+	Name      string `json:"name"`
+	Addresses []struct {
+		IP string `json:"ip"`
+	} `json:"addresses"`
+}
+
+// amassLine parses a line of amass enum -json output.
+func (m *ipSubMap) amassLine(line string) error {
+	var r amassRecord
+	if err := json.Unmarshal([]byte(line), &r); err != nil {
+		return fmt.Errorf("malformed amass line %q: %v", line, err)
+	}
+	if r.Name == "" {
+		return fmt.Errorf("amass line without name: %q", line)
+	}
+
+	var errs []error
+	for _, addr := range r.Addresses {
+		if err := m.answer(r.Name, "A", addr.IP); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// csvLine reads the -host-column field of a CSV record, after finding the
+// column in the header on the first line.
+func (m *ipSubMap) csvLine(line string) error {
+	r := csv.NewReader(strings.NewReader(line))
+	r.LazyQuotes = true
+	record, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("malformed csv line %q: %v", line, err)
+	}
+
+	if m.csvHeader == nil {
+		m.csvHeader = record
+		m.hostIndex = -1
+		for i, name := range record {
+			if strings.EqualFold(strings.TrimSpace(name), m.hostColumn) {
+				m.hostIndex = i
+				return nil
+			}
+		}
+		return fmt.Errorf("csv header has no %q column", m.hostColumn)
+	}
+
+	if m.hostIndex < 0 {
+		return nil
+	}
+	if m.hostIndex >= len(record) {
+		return fmt.Errorf("csv line has no %q column: %q", m.hostColumn, line)
+	}
+	host := strings.TrimSpace(record[m.hostIndex])
+	if host == "" {
+		return nil
+	}
+	return m.lookupEntry(host)
+}
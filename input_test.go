@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestIPSubMapMassdns(t *testing.T) {
+	m := &ipSubMap{
+		ipv4:    true,
+		input:   inputMassdns,
+		private: fragment{m: make(map[netip.Addr][]string)},
+		public:  fragment{m: make(map[netip.Addr][]string)},
+	}
+
+	in := strings.Join([]string{
+		"www.example.com. CNAME cdn.example.net.",
+		"cdn.example.net. A 93.184.216.34",
+		"",
+		"db.example.com. A 10.0.0.1",
+		"db.example.com. AAAA 2001:db8::1",
+		"mail.example.com. MX 10 mx.example.com.",
+	}, "\n")
+	if err := m.enumerate(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := m.public.m[netip.MustParseAddr("93.184.216.34")]; !slices.Equal(got, []string{"cdn.example.net", "www.example.com"}) {
+		t.Errorf("expected the CNAME alias to be recorded, got %q", got)
+	}
+	if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; !slices.Equal(got, []string{"db.example.com"}) {
+		t.Errorf("expected db.example.com, got %q", got)
+	}
+	if len(m.public.m)+len(m.private.m) != 2 {
+		t.Errorf("expected the AAAA and MX records to be skipped, got %v %v", m.public.m, m.private.m)
+	}
+
+	if err := m.lookup("broken.example.com. A"); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
+
+func TestIPSubMapAliases(t *testing.T) {
+	m := &ipSubMap{cnames: map[string][]string{
+		"c.example.com": {"b.example.com"},
+		"b.example.com": {"a.example.com", "c.example.com"},
+	}}
+
+	want := []string{"c.example.com", "b.example.com", "a.example.com"}
+	if got := m.aliases("c.example.com"); !slices.Equal(got, want) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestIPSubMapJSONInput(t *testing.T) {
+	tt := map[string]struct {
+		input inputFormat
+		lines []string
+	}{
+		"dnsx": {
+			input: inputDnsx,
+			lines: []string{
+				`{"host":"www.example.com","resolver":["1.1.1.1:53"],"cname":["cdn.example.net"],"a":["93.184.216.34"],"status_code":"NOERROR"}`,
+				`{"host":"db.example.com","a":["10.0.0.1"],"aaaa":["2001:db8::1"]}`,
+			},
+		},
+		"amass": {
+			input: inputAmass,
+			lines: []string{
+				`{"name":"www.example.com","domain":"example.com","addresses":[{"ip":"93.184.216.34","cidr":"93.184.216.0/24","asn":15133,"desc":"EDGECAST"}],"tag":"dns","sources":["DNS"]}`,
+				`{"name":"db.example.com","domain":"example.com","addresses":[{"ip":"10.0.0.1"},{"ip":"2001:db8::1"}]}`,
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			m := &ipSubMap{
+				ipv4:    true,
+				ipv6:    true,
+				input:   tc.input,
+				private: fragment{m: make(map[netip.Addr][]string)},
+				public:  fragment{m: make(map[netip.Addr][]string)},
+			}
+			if err := m.enumerate(strings.NewReader(strings.Join(tc.lines, "\n"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := map[string][]string{
+				"93.184.216.34": {"www.example.com"},
+				"2001:db8::1":   {"db.example.com"},
+			}
+			for ip, subdomains := range want {
+				if got := m.public.m[netip.MustParseAddr(ip)]; !slices.Equal(got, subdomains) {
+					t.Errorf("%s: expected %q, got %q", ip, subdomains, got)
+				}
+			}
+			if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; !slices.Equal(got, []string{"db.example.com"}) {
+				t.Errorf("expected db.example.com, got %q", got)
+			}
+
+			if err := m.lookup(`{"a":["10.0.0.2"]}`); err == nil {
+				t.Error("expected error for a record without name")
+			}
+		})
+	}
+}
+
+func TestIPSubMapCSV(t *testing.T) {
+	tt := map[string]struct {
+		in   string
+		want map[string][]string
+		err  bool
+	}{
+		"host column": {
+			in:   "owner,Address,notes\nops,10.0.0.1,\"db, primary\"\n\"dev\",10.0.0.2,\nqa,,empty\n",
+			want: map[string][]string{"10.0.0.1": {"10.0.0.1"}, "10.0.0.2": {"10.0.0.2"}},
+		},
+		"missing column": {
+			in:   "owner,ip\nops,10.0.0.1\n",
+			want: map[string][]string{},
+			err:  true,
+		},
+		"short line": {
+			in:   "owner,address\nops\nqa,10.0.0.3\n",
+			want: map[string][]string{"10.0.0.3": {"10.0.0.3"}},
+			err:  true,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			m := &ipSubMap{
+				ipv4:       true,
+				mode:       modeClassify,
+				input:      inputCSV,
+				hostColumn: "address",
+				private:    fragment{m: make(map[netip.Addr][]string)},
+			}
+			err := m.enumerate(strings.NewReader(tc.in))
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+			if len(m.private.m) != len(tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, m.private.m)
+			}
+			for ip, subdomains := range tc.want {
+				if got := m.private.m[netip.MustParseAddr(ip)]; !slices.Equal(got, subdomains) {
+					t.Errorf("%s: expected %q, got %q", ip, subdomains, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+type ipv6Format string
+
+const (
+	ipv6Canonical ipv6Format = "canonical"
+	ipv6Expanded  ipv6Format = "expanded"
+)
+
+func parseIPv6Format(s string) (ipv6Format, error) {
+	switch format := ipv6Format(s); format {
+	case ipv6Canonical, ipv6Expanded:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unknown ipv6 format %q", s)
+	}
+}
+
+// outputIPv6Format is set from -ipv6-format before any results are recorded
+// or loaded, so every output and loaded key uses the same representation.
+var outputIPv6Format = ipv6Canonical
+
+func (f ipv6Format) format(addr netip.Addr) string {
+	addr = addr.Unmap()
+	if f == ipv6Expanded && addr.Is6() {
+		return addr.StringExpanded()
+	}
+	return addr.String()
+}
+
+func formatIP(addr netip.Addr) string {
+	return outputIPv6Format.format(addr)
+}
+
+// addrFromIP converts an address returned by the resolver. IPv4-mapped IPv6
+// addresses (::ffff:1.2.3.4) are returned as IPv4, so they are keyed,
+// classified and filtered as IPv4.
+func addrFromIP(ip net.IP) netip.Addr {
+	addr, _ := netip.AddrFromSlice(ip)
+	return addr.Unmap()
+}
+
+// canonicalIP returns an ip address read from an existing output in the form
+// used for map keys, following -ipv6-format. Anything that doesn't parse is returned unchanged.
+func canonicalIP(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return s
+	}
+	return outputIPv6Format.format(addr)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalIP(t *testing.T) {
+	tt := map[string]string{
+		"1.2.3.4":          "1.2.3.4",
+		"::ffff:1.2.3.4":   "1.2.3.4",
+		"::ffff:102:304":   "1.2.3.4",
+		"2001:db8:0::1":    "2001:db8::1",
+		"www.example.com":  "www.example.com",
+		"not an ip at all": "not an ip at all",
+	}
+	for in, want := range tt {
+		if got := canonicalIP(in); got != want {
+			t.Errorf("canonicalIP(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestIPSubMapRecord_mapped(t *testing.T) {
+	m := &ipSubMap{
+		ipv4:    true,
+		public:  fragment{m: make(map[netip.Addr][]string)},
+		private: fragment{m: make(map[netip.Addr][]string)},
+	}
+	if err := m.public.load(strings.NewReader("::ffff:1.2.3.4 a.example.com")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	m.record("b.example.com", netip.MustParseAddr("::ffff:1.2.3.4"))
+	m.record("c.example.com", netip.MustParseAddr("1.2.3.4"))
+	m.record("db.example.com", netip.MustParseAddr("::ffff:10.0.0.1"))
+
+	want := []string{"a.example.com", "b.example.com", "c.example.com"}
+	if got := m.public.m[netip.MustParseAddr("1.2.3.4")]; len(m.public.m) != 1 || !slices.Equal(got, want) {
+		t.Errorf("expected a single 1.2.3.4 entry with %q, got %v", want, m.public.m)
+	}
+	if _, ok := m.private.m[netip.MustParseAddr("10.0.0.1")]; !ok {
+		t.Errorf("expected the mapped private address to be classified as private, got %v", m.private.m)
+	}
+}
+
+func TestFormatIP_expanded(t *testing.T) {
+	outputIPv6Format = ipv6Expanded
+	defer func() { outputIPv6Format = ipv6Canonical }()
+
+	tt := map[string]string{
+		"2001:db8::1":    "2001:0db8:0000:0000:0000:0000:0000:0001",
+		"::ffff:1.2.3.4": "1.2.3.4",
+		"10.0.0.1":       "10.0.0.1",
+	}
+	for in, want := range tt {
+		if got := formatIP(netip.MustParseAddr(in)); got != want {
+			t.Errorf("formatIP(%q): expected %q, got %q", in, want, got)
+		}
+		if got := canonicalIP(in); got != want {
+			t.Errorf("canonicalIP(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestRunDiff_ipv6Format(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txt")
+	new := filepath.Join(dir, "new.txt")
+	os.WriteFile(old, []byte("2001:db8::1 public a.example.com"), 0o644)
+	os.WriteFile(new, []byte("2001:0db8:0000:0000:0000:0000:0000:0001 public a.example.com"), 0o644)
+
+	for _, format := range []string{"canonical", "expanded"} {
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		if code := dispatch([]string{"diff", "-ipv6-format", format, old, new}, nil, stdout, stderr); code != 0 {
+			t.Errorf("%s: expected no differences, got %d: %s%s", format, code, stdout, stderr)
+		}
+	}
+	outputIPv6Format = ipv6Canonical
+}
@@ -0,0 +1,430 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	kafkaProduceKey     = 0
+	kafkaMetadataKey    = 3
+	kafkaBatchSize      = 100
+	kafkaFlushInterval  = time.Second
+	kafkaRequestTimeout = 10 * time.Second
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+type kafkaRecord struct {
+	key   []byte
+	value []byte
+	time  time.Time
+}
+
+type kafkaConn struct {
+	conn        net.Conn
+	r           *bufio.Reader
+	correlation int32
+}
+
+type kafkaReport struct {
+	bootstrap string
+	topic     string
+
+	partitions []int32
+	leaders    map[int32]int32
+	brokers    map[int32]string
+	conns      map[int32]*kafkaConn
+
+	pending   map[int32][]kafkaRecord
+	count     int
+	lastFlush time.Time
+	err       error
+	now       func() time.Time
+
+	// seen holds the first resolution times of the fragments by class.
+	seen map[string]map[string]time.Time
+}
+
+func newKafkaReport(target string) (*kafkaReport, error) {
+	broker, topic, ok := strings.Cut(target, "/")
+	if !ok || broker == "" || topic == "" {
+		return nil, fmt.Errorf("invalid kafka target %q: expected broker:port/topic", target)
+	}
+	if _, _, err := net.SplitHostPort(broker); err != nil {
+		broker = net.JoinHostPort(broker, "9092")
+	}
+
+	r := &kafkaReport{
+		bootstrap: broker,
+		topic:     topic,
+		brokers:   make(map[int32]string),
+		leaders:   make(map[int32]int32),
+		conns:     make(map[int32]*kafkaConn),
+		pending:   make(map[int32][]kafkaRecord),
+		now:       time.Now,
+	}
+	if err := r.loadMetadata(); err != nil {
+		r.close()
+		return nil, err
+	}
+	r.lastFlush = r.now()
+	return r, nil
+}
+
+func dialKafka(addr string) (*kafkaConn, error) {
+	conn, err := net.DialTimeout("tcp", addr, kafkaRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaConn{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *kafkaConn) roundTrip(apiKey, version int16, body []byte) ([]byte, error) {
+	c.correlation++
+
+	var req kafkaEncoder
+	req.int16(apiKey)
+	req.int16(version)
+	req.int32(c.correlation)
+	req.string("ipsubmap")
+	req.buf = append(req.buf, body...)
+
+	msg := binary.BigEndian.AppendUint32(nil, uint32(len(req.buf)))
+	msg = append(msg, req.buf...)
+
+	c.conn.SetDeadline(time.Now().Add(kafkaRequestTimeout))
+	if _, err := c.conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != c.correlation {
+		return nil, errors.New("unexpected correlation id in kafka response")
+	}
+	return resp[4:], nil
+}
+
+func (r *kafkaReport) loadMetadata() error {
+	conn, err := dialKafka(r.bootstrap)
+	if err != nil {
+		return fmt.Errorf("failed to connect to kafka broker %s: %v", r.bootstrap, err)
+	}
+	defer conn.conn.Close()
+
+	var req kafkaEncoder
+	req.int32(1)
+	req.string(r.topic)
+
+	resp, err := conn.roundTrip(kafkaMetadataKey, 1, req.buf)
+	if err != nil {
+		return fmt.Errorf("failed to load kafka metadata: %v", err)
+	}
+
+	d := kafkaDecoder{b: resp}
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		node := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string()
+		r.brokers[node] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32()
+
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		code := d.int16()
+		name := d.string()
+		d.int8()
+		if name == r.topic && code != 0 {
+			return fmt.Errorf("kafka topic %q: %v", r.topic, kafkaError(code))
+		}
+		for j := d.int32(); j > 0 && d.err == nil; j-- {
+			d.int16()
+			partition := d.int32()
+			leader := d.int32()
+			d.skipInt32Array()
+			d.skipInt32Array()
+			if name == r.topic {
+				r.partitions = append(r.partitions, partition)
+				r.leaders[partition] = leader
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid kafka metadata response: %v", d.err)
+	}
+	if len(r.partitions) == 0 {
+		return fmt.Errorf("kafka topic %q has no partitions", r.topic)
+	}
+	return nil
+}
+
+func (r *kafkaReport) add(class string, ip string, subdomain string) {
+	if r.err != nil {
+		return
+	}
+
+	now := r.now()
+	value, _ := json.Marshal(resultRecord{SchemaVersion: schemaVersion, Subdomain: subdomain, IP: ip, Class: class, Timestamp: seenAt(r.seen[class], subdomain, now).UTC()})
+	partition := r.partitions[shardOf(ip, len(r.partitions))]
+	r.pending[partition] = append(r.pending[partition], kafkaRecord{key: []byte(ip), value: value, time: now})
+	r.count++
+
+	if r.count >= kafkaBatchSize || now.Sub(r.lastFlush) >= kafkaFlushInterval {
+		r.err = r.flush()
+	}
+}
+
+func (r *kafkaReport) flush() error {
+	r.lastFlush = r.now()
+	if r.count == 0 {
+		return nil
+	}
+
+	for partition, records := range r.pending {
+		if err := r.produce(partition, records); err != nil {
+			return err
+		}
+		delete(r.pending, partition)
+	}
+	r.count = 0
+	return nil
+}
+
+func (r *kafkaReport) produce(partition int32, records []kafkaRecord) error {
+	leader := r.leaders[partition]
+	conn, ok := r.conns[leader]
+	if !ok {
+		addr, ok := r.brokers[leader]
+		if !ok {
+			return fmt.Errorf("no kafka broker for leader %d of partition %d", leader, partition)
+		}
+		c, err := dialKafka(addr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to kafka broker %s: %v", addr, err)
+		}
+		r.conns[leader] = c
+		conn = c
+	}
+
+	batch := encodeRecordBatch(records)
+
+	var req kafkaEncoder
+	req.int16(-1)
+	req.int16(1)
+	req.int32(int32(kafkaRequestTimeout / time.Millisecond))
+	req.int32(1)
+	req.string(r.topic)
+	req.int32(1)
+	req.int32(partition)
+	req.int32(int32(len(batch)))
+	req.buf = append(req.buf, batch...)
+
+	resp, err := conn.roundTrip(kafkaProduceKey, 3, req.buf)
+	if err != nil {
+		return fmt.Errorf("failed to produce to kafka: %v", err)
+	}
+
+	d := kafkaDecoder{b: resp}
+	for i := d.int32(); i > 0 && d.err == nil; i-- {
+		d.string()
+		for j := d.int32(); j > 0 && d.err == nil; j-- {
+			d.int32()
+			code := d.int16()
+			d.int64()
+			d.int64()
+			if code != 0 {
+				return fmt.Errorf("failed to produce to kafka partition %d: %v", partition, kafkaError(code))
+			}
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid kafka produce response: %v", d.err)
+	}
+	return nil
+}
+
+func (r *kafkaReport) write(_ io.Writer) error {
+	defer r.close()
+	if r.err != nil {
+		return r.err
+	}
+	return r.flush()
+}
+
+func (r *kafkaReport) close() {
+	for node, c := range r.conns {
+		c.conn.Close()
+		delete(r.conns, node)
+	}
+}
+
+func encodeRecordBatch(records []kafkaRecord) []byte {
+	first := records[0].time.UnixMilli()
+	last := first
+
+	var body kafkaEncoder
+	for i, rec := range records {
+		ts := rec.time.UnixMilli()
+		last = max(last, ts)
+
+		var e kafkaEncoder
+		e.int8(0)
+		e.varint(ts - first)
+		e.varint(int64(i))
+		e.varint(int64(len(rec.key)))
+		e.buf = append(e.buf, rec.key...)
+		e.varint(int64(len(rec.value)))
+		e.buf = append(e.buf, rec.value...)
+		e.varint(0)
+
+		body.varint(int64(len(e.buf)))
+		body.buf = append(body.buf, e.buf...)
+	}
+
+	var tail kafkaEncoder
+	tail.int16(0)
+	tail.int32(int32(len(records) - 1))
+	tail.int64(first)
+	tail.int64(last)
+	tail.int64(-1)
+	tail.int16(-1)
+	tail.int32(-1)
+	tail.int32(int32(len(records)))
+	tail.buf = append(tail.buf, body.buf...)
+
+	var batch kafkaEncoder
+	batch.int64(0)
+	batch.int32(int32(4 + 1 + 4 + len(tail.buf)))
+	batch.int32(-1)
+	batch.int8(2)
+	batch.int32(int32(crc32.Checksum(tail.buf, crc32c)))
+	batch.buf = append(batch.buf, tail.buf...)
+	return batch.buf
+}
+
+type kafkaEncoder struct {
+	buf []byte
+}
+
+func (e *kafkaEncoder) int8(v int8) {
+	e.buf = append(e.buf, byte(v))
+}
+
+func (e *kafkaEncoder) int16(v int16) {
+	e.buf = binary.BigEndian.AppendUint16(e.buf, uint16(v))
+}
+
+func (e *kafkaEncoder) int32(v int32) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(v))
+}
+
+func (e *kafkaEncoder) int64(v int64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, uint64(v))
+}
+
+func (e *kafkaEncoder) varint(v int64) {
+	e.buf = binary.AppendVarint(e.buf, v)
+}
+
+func (e *kafkaEncoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+type kafkaDecoder struct {
+	b   []byte
+	err error
+}
+
+func (d *kafkaDecoder) next(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *kafkaDecoder) int8() int8 {
+	if b := d.next(1); b != nil {
+		return int8(b[0])
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int16() int16 {
+	if b := d.next(2); b != nil {
+		return int16(binary.BigEndian.Uint16(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int32() int32 {
+	if b := d.next(4); b != nil {
+		return int32(binary.BigEndian.Uint32(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) int64() int64 {
+	if b := d.next(8); b != nil {
+		return int64(binary.BigEndian.Uint64(b))
+	}
+	return 0
+}
+
+func (d *kafkaDecoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+func (d *kafkaDecoder) skipInt32Array() {
+	n := d.int32()
+	if n > 0 {
+		d.next(int(n) * 4)
+	}
+}
+
+func kafkaError(code int16) error {
+	switch code {
+	case 3:
+		return errors.New("unknown topic or partition")
+	case 5:
+		return errors.New("leader not available")
+	case 6:
+		return errors.New("not leader for partition")
+	case 7:
+		return errors.New("request timed out")
+	case 10:
+		return errors.New("message too large")
+	case 29:
+		return errors.New("topic authorization failed")
+	default:
+		return fmt.Errorf("error code %d", code)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeKafkaBroker struct {
+	ln       net.Listener
+	messages chan resultRecord
+}
+
+func newFakeKafkaBroker(t *testing.T) *fakeKafkaBroker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	b := &fakeKafkaBroker{ln: ln, messages: make(chan resultRecord, 16)}
+	go b.serve(t)
+	t.Cleanup(func() { ln.Close() })
+	return b
+}
+
+func (b *fakeKafkaBroker) serve(t *testing.T) {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		go b.handle(t, conn)
+	}
+}
+
+func (b *fakeKafkaBroker) handle(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(r, req); err != nil {
+			return
+		}
+
+		d := kafkaDecoder{b: req}
+		apiKey := d.int16()
+		d.int16()
+		correlation := d.int32()
+		d.string()
+
+		var resp kafkaEncoder
+		resp.int32(correlation)
+		switch apiKey {
+		case kafkaMetadataKey:
+			host, port, _ := net.SplitHostPort(b.ln.Addr().String())
+			p, _ := strconv.Atoi(port)
+			resp.int32(1)
+			resp.int32(0)
+			resp.string(host)
+			resp.int32(int32(p))
+			resp.int16(-1)
+			resp.int32(0)
+			resp.int32(1)
+			resp.int16(0)
+			resp.string("recon")
+			resp.int8(0)
+			resp.int32(1)
+			resp.int16(0)
+			resp.int32(0)
+			resp.int32(0)
+			resp.int32(0)
+			resp.int32(0)
+		case kafkaProduceKey:
+			b.decodeProduce(t, &d)
+			resp.int32(1)
+			resp.string("recon")
+			resp.int32(1)
+			resp.int32(0)
+			resp.int16(0)
+			resp.int64(0)
+			resp.int64(-1)
+			resp.int32(0)
+		}
+
+		msg := binary.BigEndian.AppendUint32(nil, uint32(len(resp.buf)))
+		conn.Write(append(msg, resp.buf...))
+	}
+}
+
+func (b *fakeKafkaBroker) decodeProduce(t *testing.T, d *kafkaDecoder) {
+	d.string()
+	d.int16()
+	d.int32()
+	d.int32()
+	d.string()
+	d.int32()
+	d.int32()
+	batch := d.next(int(d.int32()))
+
+	bd := kafkaDecoder{b: batch}
+	bd.int64()
+	bd.int32()
+	bd.int32()
+	if magic := bd.int8(); magic != 2 {
+		t.Errorf("expected magic 2, got %d", magic)
+	}
+	crc := uint32(bd.int32())
+	if got := crc32.Checksum(bd.b, crc32c); got != crc {
+		t.Errorf("expected crc %x, got %x", got, crc)
+	}
+	bd.next(2 + 4 + 8 + 8 + 8 + 2 + 4)
+	n := bd.int32()
+
+	rest := bd.b
+	for i := int32(0); i < n; i++ {
+		_, k := binary.Varint(rest)
+		rest = rest[k:]
+		rest = rest[1:]
+		for range 2 {
+			_, k = binary.Varint(rest)
+			rest = rest[k:]
+		}
+		keyLen, k := binary.Varint(rest)
+		rest = rest[k+int(keyLen):]
+		valueLen, k := binary.Varint(rest)
+		value := rest[k : k+int(valueLen)]
+		rest = rest[k+int(valueLen):]
+		_, k = binary.Varint(rest)
+		rest = rest[k:]
+
+		var msg resultRecord
+		if err := json.Unmarshal(value, &msg); err != nil {
+			t.Errorf("invalid message value %q: %v", value, err)
+		}
+		b.messages <- msg
+	}
+}
+
+func TestKafkaReport(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+
+	r, err := newKafkaReport(broker.ln.Addr().String() + "/recon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC) }
+	seen := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	r.seen = map[string]map[string]time.Time{classPrivate: {"b.example.com": seen}}
+
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	r.add(classPrivate, "10.0.0.1", "b.example.com")
+	if err := r.write(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []resultRecord{
+		{SchemaVersion: schemaVersion, Subdomain: "a.example.com", IP: "1.1.1.1", Class: classPublic, Timestamp: r.now()},
+		{SchemaVersion: schemaVersion, Subdomain: "b.example.com", IP: "10.0.0.1", Class: classPrivate, Timestamp: seen},
+	}
+	for _, w := range want {
+		select {
+		case got := <-broker.messages:
+			if got != w {
+				t.Errorf("expected %+v, got %+v", w, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected message %+v", w)
+		}
+	}
+}
+
+func TestNewKafkaReport_invalid(t *testing.T) {
+	for _, target := range []string{"localhost:9092", "/topic", "localhost:9092/"} {
+		if _, err := newKafkaReport(target); err == nil {
+			t.Errorf("expected error for %q", target)
+		}
+	}
+}
+
+func TestIPSubMapWrite_kafkaUnreachable(t *testing.T) {
+	broker := newFakeKafkaBroker(t)
+	r, err := newKafkaReport(broker.ln.Addr().String() + "/recon")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	broker.ln.Close()
+
+	ips := newIPReport(sortNumeric)
+	out := &strings.Builder{}
+	m := &ipSubMap{
+		reports: []reportOutput{
+			{name: "unique ip addresses", out: out, report: ips},
+			{name: "kafka", out: io.Discard, sink: true, report: r},
+		},
+	}
+	for _, report := range m.reports {
+		report.add(classPublic, "1.1.1.1", "a.example.com")
+	}
+
+	failed := m.writeSinks()
+	if failed["kafka"] == nil || len(failed) != 1 {
+		t.Errorf("expected the kafka sink to fail, got %v", failed)
+	}
+	if err := m.write(); err != nil {
+		t.Fatalf("expected local outputs to be written, got %v", err)
+	}
+	if out.String() != "1.1.1.1" {
+		t.Errorf("unexpected local output %q", out.String())
+	}
+}
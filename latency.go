@@ -0,0 +1,127 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+type latency struct {
+	name     string
+	lookups  int
+	failures int
+	total    time.Duration
+	max      time.Duration
+}
+
+func (l *latency) average() time.Duration {
+	if l.lookups == 0 {
+		return 0
+	}
+	return l.total / time.Duration(l.lookups)
+}
+
+type latencyStats struct {
+	zones     map[string]*latency
+	resolvers map[string]*latency
+}
+
+func newLatencyStats() *latencyStats {
+	return &latencyStats{
+		zones:     make(map[string]*latency),
+		resolvers: make(map[string]*latency),
+	}
+}
+
+func (s *latencyStats) observe(zone string, d time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	observe(s.zones, zone, d, err)
+
+	// The system resolver only reports which server it used for failures.
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.Server != "" {
+		observe(s.resolvers, dnsErr.Server, d, err)
+	}
+}
+
+func observe(m map[string]*latency, name string, d time.Duration, err error) {
+	l, ok := m[name]
+	if !ok {
+		l = &latency{name: name}
+		m[name] = l
+	}
+	l.lookups++
+	l.total += d
+	l.max = max(l.max, d)
+	if err != nil {
+		l.failures++
+	}
+}
+
+func slowest(m map[string]*latency, n int) []*latency {
+	all := make([]*latency, 0, len(m))
+	for _, l := range m {
+		all = append(all, l)
+	}
+	slices.SortFunc(all, func(a, b *latency) int {
+		return cmp.Or(cmp.Compare(b.average(), a.average()), strings.Compare(a.name, b.name))
+	})
+	if n > 0 && len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+func (s *latencyStats) write(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for _, section := range []struct {
+		name string
+		m    map[string]*latency
+	}{
+		{name: "ZONE", m: s.zones},
+		{name: "RESOLVER", m: s.resolvers},
+	} {
+		if len(section.m) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s\tLOOKUPS\tFAILURES\tAVG\tMAX\n", section.name)
+		for _, l := range slowest(section.m, 0) {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", l.name, l.lookups, l.failures, l.average().Round(time.Millisecond), l.max.Round(time.Millisecond))
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
+
+// zoneOf approximates the zone of a hostname by its registered domain: the
+// label right below its public suffix.
+func zoneOf(host string) string {
+	labels := strings.Split(strings.TrimSuffix(host, "."), ".")
+	n := 2
+	if len(labels) > 2 && isPublicSuffix(strings.Join(labels[len(labels)-2:], ".")) {
+		n = 3
+	}
+	if len(labels) <= n {
+		return strings.Join(labels, ".")
+	}
+	return strings.Join(labels[len(labels)-n:], ".")
+}
+
+func reverseZoneOf(addr netip.Addr) string {
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, _ := addr.Prefix(bits)
+	return prefix.String()
+}
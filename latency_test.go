@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestLatencyStats(t *testing.T) {
+	s := newLatencyStats()
+	s.observe("example.com", 10*time.Millisecond, nil)
+	s.observe("example.com", 30*time.Millisecond, nil)
+	s.observe("slow.net", 2*time.Second, &net.DNSError{Err: "i/o timeout", Server: "10.0.0.53:53", IsTimeout: true})
+	s.observe("fast.org", time.Millisecond, errors.New("no such host"))
+
+	want := "ZONE         LOOKUPS  FAILURES  AVG   MAX\n" +
+		"slow.net     1        1         2s    2s\n" +
+		"example.com  2        0         20ms  30ms\n" +
+		"fast.org     1        1         1ms   1ms\n" +
+		"\n" +
+		"RESOLVER      LOOKUPS  FAILURES  AVG  MAX\n" +
+		"10.0.0.53:53  1        1         2s   2s\n" +
+		"\n"
+
+	out := &bytes.Buffer{}
+	if err := s.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := out.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	if top := slowest(s.zones, 1); len(top) != 1 || top[0].name != "slow.net" {
+		t.Errorf("expected slow.net to be the slowest zone, got %v", top)
+	}
+
+	var nilStats *latencyStats
+	nilStats.observe("example.com", time.Second, nil)
+}
+
+func TestZoneOf(t *testing.T) {
+	tt := map[string]string{
+		"www.example.com":    "example.com",
+		"a.b.example.com.":   "example.com",
+		"example.com":        "example.com",
+		"localhost":          "localhost",
+		"www.example.co.uk":  "example.co.uk",
+		"a.b.example.com.au": "example.com.au",
+		"co.uk":              "co.uk",
+		"www.example.uk":     "example.uk",
+	}
+	for host, want := range tt {
+		if got := zoneOf(host); got != want {
+			t.Errorf("zoneOf(%q): expected %q, got %q", host, want, got)
+		}
+	}
+
+	if got := reverseZoneOf(netip.MustParseAddr("192.0.2.10")); got != "192.0.2.0/24" {
+		t.Errorf("expected 192.0.2.0/24, got %q", got)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+func logLevel(name string, quiet bool) (slog.Level, error) {
+	if quiet {
+		return slog.LevelError, nil
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return slog.LevelInfo, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+func newLogHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     level,
+	}
+	if format == logFormatJSON {
+		return slog.NewJSONHandler(w, opts)
+	}
+	return slog.NewTextHandler(w, opts)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewLogHandler(t *testing.T) {
+	tt := map[string]struct {
+		format string
+		check  func(t *testing.T, line string)
+	}{
+		"text": {
+			format: logFormatText,
+			check: func(t *testing.T, line string) {
+				if !strings.Contains(line, `msg="Writing output files" app=ipsubmap`) {
+					t.Errorf("unexpected text line %q", line)
+				}
+			},
+		},
+		"json": {
+			format: logFormatJSON,
+			check: func(t *testing.T, line string) {
+				var record map[string]any
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatalf("invalid json line %q: %v", line, err)
+				}
+				if record["msg"] != "Writing output files" || record["app"] != "ipsubmap" || record["level"] != "INFO" {
+					t.Errorf("unexpected json record %v", record)
+				}
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			logger := slog.New(newLogHandler(out, tc.format, slog.LevelInfo)).With(slog.String("app", "ipsubmap"))
+			logger.Info("Writing output files")
+			tc.check(t, strings.TrimSpace(out.String()))
+		})
+	}
+}
+
+func TestLogLevel(t *testing.T) {
+	tt := map[string]struct {
+		name    string
+		quiet   bool
+		want    slog.Level
+		wantErr bool
+	}{
+		"debug":      {name: "debug", want: slog.LevelDebug},
+		"upper case": {name: "WARN", want: slog.LevelWarn},
+		"quiet":      {name: "debug", quiet: true, want: slog.LevelError},
+		"unknown":    {name: "verbose", want: slog.LevelInfo, wantErr: true},
+		"quiet wins": {name: "verbose", quiet: true, want: slog.LevelError},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := logLevel(tc.name, tc.quiet)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -2,273 +2,2116 @@ package main
 
 import (
 	"bufio"
+	"cmp"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
+	"net/netip"
 	"os"
-	"sort"
+	"regexp"
+	"slices"
 	"strings"
+	"text/template"
+	"time"
 )
 
 type Flags struct {
-	inputFile      string
-	outputPrivate  string
-	outputPublic   string
-	outputLoopback string
-	ipv4           bool
-	ipv6           bool
+	inputFile        string
+	fileHeader       string
+	outputPrivate    string
+	outputPublic     string
+	outputLoopback   string
+	outputByHost     string
+	outputCombined   string
+	outputIPs        string
+	outputUnresolved string
+	outputErrors     string
+	outputOOS        string
+	ipv4             bool
+	ipv6             bool
+	ipv6Format       string
+	mode             string
+	lookupMode       lookupMode
+	input            string
+	inputFormat      inputFormat
+	hostColumn       string
+	append           bool
+	force            bool
+	flushInterval    time.Duration
+	logInterval      time.Duration
+	stream           bool
+	spillDir         string
+	sortBudget       int
+	sort             string
+	sortMode         sortMode
+	failOn           string
+	failOnClasses    []string
+	scopeFile        string
+	scopeH1          string
+	scopeBugcrowd    string
+	cidrs            cidrFilter
+	match            string
+	exclude          string
+	hosts            hostFilter
+	excludeFile      string
+	ignoreIPs        string
+	report           string
+	reportBaseline   string
+	outputNew        string
+	outputGone       string
+	outputDot        string
+	format           string
+	outputFormat     outputFormat
+	timestamps       bool
+	outputNmap       string
+	nmapAggregate    bool
+	outputURLs       string
+	urlsPerIP        bool
+	outputShared     string
+	sharedThreshold  int
+	outputCIDRs      string
+	outputLatency    string
+	maxSubsPerIP     int
+	outputOverflow   string
+	expand           string
+	expansions       []string
+	outputExpanded   string
+	outputPTRZone    string
+	sourceIP         string
+	backend          string
+	resolverBackend  resolverBackend
+	iface            string
+	authoritative    bool
+	tryAXFR          bool
+	workers          int
+	maxDuration      time.Duration
+	perZone          int
+	negativeCache    string
+	outputExternal   string
+	negativeTTL      time.Duration
+	dryRun           bool
+	outputRemaining  string
+	vantages         vantageList
+	outputGeo        string
+	samples          int
+	outputRotation   string
+	permute          bool
+	permutationWords string
+	brute            bool
+	wordlist         string
+	domain           string
+	domains          []string
+	discover         bool
+	ctURL            string
+	groupByPrefix    int
+	groupByPrefix6   int
+	shards           int
+	maxFileSize      string
+	maxFileBytes     int64
+	template         string
+	ipSep            string
+	subSep           string
+	lineTemplate     *template.Template
+	webhook          string
+	webhookFindings  bool
+	notifySlack      string
+	notifyDiscord    string
+	outputElastic    string
+	outputKafka      string
+	outputSplunk     string
+	splunkToken      string
+	splunkIndex      string
+	splunkSourcetype string
+	logSyslog        string
+	syslogFindings   bool
+	logFormat        string
+	otelEndpoint     string
+	pprofAddr        string
+	config           string
+	version          bool
+	printSchema      bool
+	logLevel         string
+	quiet            bool
 }
 
 func (f *Flags) Validate() error {
-	in, err := os.Stat(f.inputFile)
+	switch {
+	case f.inputFile == "" && (f.brute || f.discover || (f.tryAXFR && f.domain != "")):
+	case isURL(f.inputFile):
+		if f.fileHeader != "" {
+			if _, _, err := parseHeader(f.fileHeader); err != nil {
+				return fmt.Errorf("invalid -file-header: %v", err)
+			}
+		}
+	default:
+		in, err := os.Stat(f.inputFile)
+		if err != nil {
+			return fmt.Errorf("failed to stat input file: %v", err)
+		}
+
+		if in.IsDir() {
+			return fmt.Errorf("input file is a directory")
+		}
+	}
+
+	outputs := f.outputPaths()
+	others := append(outputs[1:], f.failOn, f.webhook, f.notifySlack, f.notifyDiscord, f.outputElastic, f.outputKafka, f.outputSplunk)
+	if allEmptyStrings(outputs[0], others...) && !f.syslogFindings {
+		return fmt.Errorf("no output files specified")
+	}
+
+	if f.append && f.force {
+		return fmt.Errorf("-append and -force are mutually exclusive")
+	}
+
+	for _, out := range outputs {
+		remote, ok := parseRemote(out)
+		if !ok {
+			continue
+		}
+		if f.append {
+			return fmt.Errorf("-append cannot be used with %s:// outputs", remote.scheme)
+		}
+		if err := remote.validate(); err != nil {
+			return err
+		}
+	}
+
+	if !f.append && !f.force {
+		for _, out := range outputs {
+			if out == "" || out == stdoutPath {
+				continue
+			}
+			if err := refuseExisting(out); err != nil {
+				return err
+			}
+		}
+
+		for _, path := range []string{f.outputPrivate, f.outputPublic, f.outputLoopback} {
+			if _, remote := parseRemote(path); f.maxFileSize == "" || path == "" || path == stdoutPath || remote {
+				continue
+			}
+			for _, out := range shardPaths(path, f.shards) {
+				segments, err := existingSegments(out)
+				if err != nil {
+					return fmt.Errorf("failed to check output segments: %v", err)
+				}
+				if len(segments) > 0 {
+					return fmt.Errorf("output file %q already exists", segments[0].path)
+				}
+			}
+		}
+	}
+
+	if !f.ipv4 && !f.ipv6 {
+		return fmt.Errorf("no ip version specified")
+	}
+
+	if f.reportBaseline != "" && allEmptyStrings(f.report, f.webhook, f.notifySlack, f.notifyDiscord, f.outputNew, f.outputGone) {
+		return fmt.Errorf("-report-baseline requires -report, -out-new, -out-gone, -webhook or a notification")
+	}
+
+	if f.reportBaseline == "" && (f.outputNew != "" || f.outputGone != "") {
+		return fmt.Errorf("-out-new and -out-gone require -report-baseline")
+	}
+
+	if f.logFormat != logFormatText && f.logFormat != logFormatJSON {
+		return fmt.Errorf("unknown log format %q", f.logFormat)
+	}
+
+	if _, err := logLevel(f.logLevel, false); err != nil {
+		return err
+	}
+
+	if f.logSyslog != "" {
+		if _, _, err := parseSyslogTarget(f.logSyslog); err != nil {
+			return err
+		}
+	}
+	if f.syslogFindings && f.logSyslog == "" {
+		return fmt.Errorf("-syslog-findings requires -log-syslog")
+	}
+
+	if f.webhookFindings && f.reportBaseline == "" {
+		return fmt.Errorf("-webhook-findings requires -report-baseline")
+	}
+
+	if f.sharedThreshold < 1 {
+		return fmt.Errorf("shared threshold must be at least 1")
+	}
+
+	if f.failOn != "" {
+		classes, err := parseClasses(f.failOn)
+		if err != nil {
+			return fmt.Errorf("invalid -fail-on: %v", err)
+		}
+		f.failOnClasses = classes
+	}
+
+	if f.match != "" {
+		re, err := regexp.Compile(f.match)
+		if err != nil {
+			return fmt.Errorf("invalid -match: %v", err)
+		}
+		f.hosts.match = re
+	}
+
+	if f.exclude != "" {
+		re, err := regexp.Compile(f.exclude)
+		if err != nil {
+			return fmt.Errorf("invalid -exclude: %v", err)
+		}
+		f.hosts.exclude = re
+	}
+
+	if _, err := parseIPv6Format(f.ipv6Format); err != nil {
+		return err
+	}
+
+	lookup, err := parseLookupMode(f.mode)
+	if err != nil {
+		return err
+	}
+	f.lookupMode = lookup
+
+	input, err := parseInputFormat(f.input)
+	if err != nil {
+		return err
+	}
+	if input.resolved() && lookup != modeResolve {
+		return fmt.Errorf("-input-format %s cannot be combined with -mode %s", input, lookup)
+	}
+	if input == inputCSV && f.hostColumn == "" {
+		return fmt.Errorf("-input-format csv requires -host-column")
+	}
+	f.inputFormat = input
+
+	mode, err := parseSortMode(f.sort)
+	if err != nil {
+		return err
+	}
+	f.sortMode = mode
+
+	format, err := parseFormat(f.format)
+	if err != nil {
+		return err
+	}
+	if format == formatHosts && mode == sortSubdomain {
+		return fmt.Errorf("-format hosts cannot be combined with -sort subdomain")
+	}
+	if format.inventory() && (f.stream || f.append) {
+		return fmt.Errorf("-format %s cannot be combined with -stream or -append", format)
+	}
+	if format.structured() && (mode == sortSubdomain || f.append) {
+		return fmt.Errorf("-format %s cannot be combined with -sort subdomain or -append", format)
+	}
+	if format == formatSQLite && (f.outputCombined == "" || !allEmptyStrings(f.outputPrivate, f.outputPublic, f.outputLoopback) || f.append) {
+		return fmt.Errorf("-format sqlite writes the -out database only, and cannot be combined with per-class outputs or -append")
+	}
+	// Records always carry a timestamp, so -timestamps changes nothing there.
+	if f.timestamps && !format.inventory() && !format.records() {
+		return fmt.Errorf("-timestamps requires -format ansible, ansible-yaml, zone, json, csv, msgpack or parquet")
+	}
+	f.outputFormat = format
+
+	if f.groupByPrefix < 0 || f.groupByPrefix > 32 {
+		return fmt.Errorf("-group-by-prefix must be between 0 and 32")
+	}
+	if f.groupByPrefix6 < 0 || f.groupByPrefix6 > 128 {
+		return fmt.Errorf("-group-by-prefix6 must be between 0 and 128")
+	}
+	if f.groupByPrefix > 0 && (mode == sortSubdomain || format.inventory() || format.structured()) {
+		return fmt.Errorf("-group-by-prefix cannot be combined with -sort subdomain or -format %s", format)
+	}
+
+	f.ipSep, f.subSep = unescape(f.ipSep), unescape(f.subSep)
+	if err := validateSeparators(f.ipSep, f.subSep); err != nil {
+		return err
+	}
+
+	if f.template != "" {
+		t, err := parseLineTemplate(f.template)
+		if err != nil {
+			return fmt.Errorf("invalid -template: %v", err)
+		}
+		if format.inventory() || format.structured() {
+			return fmt.Errorf("-format %s cannot be combined with -template", format)
+		}
+		if mode == sortSubdomain || f.append {
+			return fmt.Errorf("-template cannot be combined with -sort subdomain or -append")
+		}
+		f.lineTemplate = t
+	}
+
+	if f.expand != "" {
+		rules, err := parseExpansions(f.expand)
+		if err != nil {
+			return fmt.Errorf("invalid -expand: %v", err)
+		}
+		if lookup != modeResolve || input.resolved() {
+			return fmt.Errorf("-expand requires -mode resolve and hostname input")
+		}
+		f.expansions = rules
+	}
+	if f.permute {
+		if f.permutationWords == "" {
+			return fmt.Errorf("-permute requires -permutation-words")
+		}
+		if lookup != modeResolve || input.resolved() {
+			return fmt.Errorf("-permute requires -mode resolve and hostname input")
+		}
+	}
+	if f.maxDuration < 0 {
+		return fmt.Errorf("-max-duration must not be negative")
+	}
+	if f.outputRemaining != "" && f.maxDuration == 0 {
+		return fmt.Errorf("-out-remaining requires -max-duration")
+	}
+	if f.workers < 1 {
+		return fmt.Errorf("-workers must be at least 1")
+	}
+	if f.outputExternal != "" && lookup != modeResolve {
+		return fmt.Errorf("-out-external requires -mode resolve")
+	}
+	if f.negativeCache != "" && f.negativeTTL <= 0 {
+		return fmt.Errorf("-negative-ttl must be positive")
+	}
+	if f.perZone < 0 {
+		return fmt.Errorf("-per-zone must not be negative")
+	}
+	if f.samples < 1 {
+		return fmt.Errorf("-samples must be at least 1")
+	}
+
+	if len(f.vantages) > 0 {
+		if f.outputGeo == "" {
+			return fmt.Errorf("-vantage requires -out-geo")
+		}
+		if lookup != modeResolve || input.resolved() {
+			return fmt.Errorf("-vantage requires -mode resolve and hostname input")
+		}
+	} else if f.outputGeo != "" {
+		return fmt.Errorf("-out-geo requires -vantage")
+	}
+
+	if f.authoritative && (lookup != modeResolve || input.resolved()) {
+		return fmt.Errorf("-authoritative requires -mode resolve and hostname input")
+	}
+	if f.tryAXFR && (lookup != modeResolve || input.resolved()) {
+		return fmt.Errorf("-try-axfr requires -mode resolve and hostname input")
+	}
+
+	backend, err := parseResolverBackend(f.backend)
 	if err != nil {
-		return fmt.Errorf("failed to stat input file: %v", err)
+		return err
+	}
+	if backend == backendCgo && (f.sourceIP != "" || f.iface != "") {
+		return fmt.Errorf("-source-ip and -interface require the go resolver backend")
+	}
+	f.resolverBackend = backend
+
+	if f.sourceIP != "" {
+		if f.iface != "" {
+			return fmt.Errorf("-source-ip cannot be combined with -interface")
+		}
+		if net.ParseIP(f.sourceIP) == nil {
+			return fmt.Errorf("invalid -source-ip %q", f.sourceIP)
+		}
+	}
+
+	if f.domain != "" {
+		domains, err := parseDomains(f.domain)
+		if err != nil {
+			return fmt.Errorf("invalid -domain: %v", err)
+		}
+		f.domains = domains
+	}
+	if f.brute {
+		if f.wordlist == "" || f.domain == "" {
+			return fmt.Errorf("-brute requires -wordlist and -domain")
+		}
+		if lookup != modeResolve || input.resolved() {
+			return fmt.Errorf("-brute requires -mode resolve and hostname input")
+		}
+	}
+	if f.discover {
+		if f.domain == "" {
+			return fmt.Errorf("discover requires -domain")
+		}
+		if !isDiscoverURL(f.ctURL) {
+			return fmt.Errorf("invalid -ct-url %q: expected an http(s) url containing {domain}", f.ctURL)
+		}
+		if lookup != modeResolve || input != inputText {
+			return fmt.Errorf("discover requires -mode resolve and -input-format text")
+		}
+	}
+	if f.outputExpanded != "" && f.expand == "" && !f.permute && !f.brute && !f.tryAXFR {
+		return fmt.Errorf("-out-expanded requires -expand, -permute, -brute or -try-axfr")
+	}
+
+	if f.maxSubsPerIP < 0 {
+		return fmt.Errorf("-max-subs-per-ip must not be negative")
+	}
+	if f.maxSubsPerIP > 0 && (mode == sortSubdomain || format.inventory() || f.append) {
+		return fmt.Errorf("-max-subs-per-ip cannot be combined with -sort subdomain, -format %s or -append", format)
+	}
+	if f.outputOverflow != "" && f.maxSubsPerIP == 0 {
+		return fmt.Errorf("-out-overflow requires -max-subs-per-ip")
+	}
+
+	if f.shards < 1 {
+		return fmt.Errorf("-shards must be at least 1")
+	}
+	if f.shards > 1 && format.inventory() {
+		return fmt.Errorf("-format %s cannot be combined with -shards", format)
+	}
+
+	if f.maxFileSize != "" {
+		size, err := parseSize(f.maxFileSize)
+		if err != nil {
+			return fmt.Errorf("invalid -max-file-size: %v", err)
+		}
+		if size > 0 && (format.inventory() || format == formatParquet) {
+			return fmt.Errorf("-format %s cannot be combined with -max-file-size", format)
+		}
+		f.maxFileBytes = size
+	}
+
+	var stdout int
+	for _, path := range []string{f.outputPrivate, f.outputPublic, f.outputLoopback} {
+		if path == stdoutPath {
+			stdout++
+		}
+	}
+	if stdout > 1 {
+		return fmt.Errorf("only one of -out-private, -out-public and -out-loopback can be written to stdout")
+	}
+	if stdout > 0 && (f.append || f.shards > 1 || f.maxFileBytes > 0) {
+		return fmt.Errorf("stdout output cannot be combined with -append, -shards or -max-file-size")
+	}
+
+	if f.sortBudget < 0 {
+		return fmt.Errorf("sort budget must not be negative")
+	}
+
+	if f.flushInterval < 0 {
+		return fmt.Errorf("flush interval must not be negative")
+	}
+
+	if f.logInterval < 0 {
+		return fmt.Errorf("log interval must not be negative")
+	}
+
+	return nil
+}
+
+func (f *Flags) outputPaths() []string {
+	var paths []string
+	for _, path := range []string{f.outputPrivate, f.outputPublic, f.outputLoopback} {
+		paths = append(paths, shardPaths(path, f.shards)...)
+	}
+
+	return append(paths,
+		f.outputByHost,
+		f.outputCombined,
+		f.outputIPs,
+		f.outputUnresolved,
+		f.outputErrors,
+		f.outputOOS,
+		f.report,
+		f.outputDot,
+		f.outputNmap,
+		nmapMapPath(f.outputNmap),
+		f.outputURLs,
+		f.outputShared,
+		f.outputCIDRs,
+		f.outputNew,
+		f.outputGone,
+		f.outputLatency,
+		f.outputOverflow,
+		f.outputExpanded,
+		f.outputPTRZone,
+		f.outputGeo,
+		f.outputRotation,
+		f.outputRemaining,
+		f.outputExternal,
+	)
+}
+
+// refuseExisting returns an error when the output file out already exists.
+func refuseExisting(out string) error {
+	if _, err := os.Stat(out); !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("output file %q already exists", out)
+	}
+	return nil
+}
+
+func allEmptyStrings(first string, others ...string) bool {
+	if first != "" {
+		return false
+	}
+
+	for _, s := range others {
+		if s != "" {
+			return false
+		}
+	}
+
+	return true
+}
+
+type ipSubMap struct {
+	private  fragment
+	public   fragment
+	loopback fragment
+
+	ipv4  bool
+	ipv6  bool
+	mode  lookupMode
+	input inputFormat
+	// in is the input file, removed on close when it was downloaded.
+	in io.Closer
+
+	hostColumn string
+	hostIndex  int
+	csvHeader  []string
+
+	reports []reportOutput
+	counts  map[string]int
+
+	scope        *scope
+	cidrs        *cidrFilter
+	hosts        *hostFilter
+	exclusions   *exclusionList
+	droppedHosts int
+	droppedIPs   int
+	ignored      prefixList
+	ignoredIPs   int
+	failures     map[string]int
+	cnames       map[string][]string
+	latency      *latencyStats
+	progress     *progress
+
+	expansions       []string
+	permutationWords []string
+	inputNames       []string
+	brute            *bruteForce
+	vantages         vantageList
+	samples          int
+	axfrDomains      []string
+	tryTransfer      bool
+	zones            *authoritative
+	transfers        []transfer
+	workers          int
+	perZone          int
+	followCNAMEs     bool
+	pending          *pendingLine
+	deadline         time.Time
+	expired          bool
+	unprocessed      int
+	remaining        io.Writer
+	remainingOut     *bufio.Writer
+	remainingErr     error
+	seen             map[string]bool
+
+	flushInterval time.Duration
+	lastFlush     time.Time
+
+	tracer *tracer
+	span   *span
+}
+
+// fragment is not safe for concurrent use. The workers only resolve; the
+// pipeline records their results in input order on a single goroutine, so
+// adding workers never contends on a fragment.
+type fragment struct {
+	out     sink
+	m       map[netip.Addr][]string
+	seen    map[string]time.Time
+	spill   *spill
+	partial string
+
+	sortBudget int
+	sortDir    string
+	sortMode   sortMode
+	format     outputFormat
+	class      string
+	groupBits4 int
+	groupBits6 int
+	maxValues  int
+	overflow   *overflowReport
+	template   *template.Template
+	ipSep      string
+	subSep     string
+}
+
+// append is only called by the recording stage, which runs on one goroutine
+// while the pipeline workers resolve, so fragments need no locking.
+func (f *fragment) append(ip netip.Addr, subdomain string) {
+	if f.spill != nil {
+		f.spill.add(formatIP(ip), subdomain)
+		return
+	}
+	if f.m == nil {
+		return
+	}
+	i, found := slices.BinarySearch(f.m[ip], subdomain)
+	if found {
+		return
+	}
+	f.m[ip] = slices.Insert(f.m[ip], i, subdomain)
+	if _, ok := f.seen[subdomain]; !ok && f.seen != nil {
+		f.seen[subdomain] = time.Now()
+	}
+}
+
+func (f *fragment) write() error {
+	if f.out == nil {
+		return nil
+	}
+	return f.writeTo(f.out)
+}
+
+// writeTo writes the records of the fragment to s in output order.
+func (f *fragment) writeTo(s sink) error {
+	if f.spill != nil {
+		err := f.spill.each(func(key string, values []string) error {
+			return s.write(record{key: key, values: values})
+		})
+		if err != nil {
+			return err
+		}
+		return s.close()
+	}
+
+	if f.format.inventory() {
+		for addr, subdomains := range f.m {
+			if err := s.write(record{key: formatIP(addr), values: subdomains}); err != nil {
+				return err
+			}
+		}
+		return s.close()
+	}
+
+	if f.sortMode == sortSubdomain {
+		return f.writeBySubdomain(s)
+	}
+
+	if f.sortBudget > 0 && len(f.m) > f.sortBudget {
+		keys := func(yield func(key string) error) error {
+			for addr := range f.m {
+				if err := yield(formatIP(addr)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return f.writeExternal(s, keys, func(key string) []string {
+			addr, _ := netip.ParseAddr(key)
+			return f.m[addr]
+		})
+	}
+
+	type addrRecord struct {
+		addr netip.Addr
+		record
+	}
+	records := make([]addrRecord, 0, len(f.m))
+	for addr, subdomains := range f.m {
+		records = append(records, addrRecord{addr: addr, record: record{key: formatIP(addr), values: subdomains}})
+	}
+	slices.SortFunc(records, func(a, b addrRecord) int {
+		if f.sortMode == sortNumeric {
+			return a.addr.Compare(b.addr)
+		}
+		return strings.Compare(a.key, b.key)
+	})
+
+	for _, r := range records {
+		if err := s.write(r.record); err != nil {
+			return err
+		}
+	}
+
+	return s.close()
+}
+
+// writeBySubdomain writes a record per subdomain with its ip addresses.
+func (f *fragment) writeBySubdomain(s sink) error {
+	m := invertAddrs(f.m)
+	if f.sortBudget > 0 && len(m) > f.sortBudget {
+		keys := func(yield func(key string) error) error {
+			for k := range m {
+				if err := yield(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return f.writeExternal(s, keys, func(key string) []string { return m[key] })
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		if err := s.write(record{key: k, values: m[k]}); err != nil {
+			return err
+		}
+	}
+
+	return s.close()
+}
+
+// writeExternal writes the records in the order of their keys sorted on disk,
+// for fragments over the sort budget. keys calls yield with every key, and
+// values returns the values of a key.
+func (f *fragment) writeExternal(s sink, keys func(yield func(key string) error) error, values func(key string) []string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		w := bufio.NewWriter(pw)
+		err := keys(func(k string) error {
+			_, err := w.WriteString(sortKey(k, f.sortMode) + "\t" + k + "\n")
+			return err
+		})
+		if err != nil {
+			pw.CloseWithError(err)
+			return
+		}
+		pw.CloseWithError(w.Flush())
+	}()
+
+	sorted, cleanup, err := sortRecords(pr, f.sortDir, f.sortBudget)
+	pr.Close()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	for {
+		line, err := sorted()
+		if errors.Is(err, io.EOF) {
+			return s.close()
+		}
+		if err != nil {
+			return err
+		}
+		_, k, _ := strings.Cut(line, "\t")
+		if err := s.write(record{key: k, values: values(k)}); err != nil {
+			return err
+		}
+	}
+}
+
+// writerSink returns a sink encoding records in the fragment's format onto
+// outs, split by shardOf when there are several.
+func (f *fragment) writerSink(outs ...io.Writer) sink {
+	if len(outs) > 1 {
+		s := make(shardSink, len(outs))
+		for i := range outs {
+			s[i] = f.writerSink(outs[i])
+		}
+		return s
+	}
+	if f.format.inventory() {
+		return &inventorySink{out: outs[0], format: f.format, class: f.class, seen: f.seen, m: make(map[string][]string)}
+	}
+
+	if f.format == formatParquet {
+		w := newParquetWriter(outs[0], f.class, f.seen)
+		w.maxValues, w.overflow = f.maxValues, f.overflow
+		return w
+	}
+
+	w := f.encoderSink(outs[0])
+	if f.groupBits4 > 0 || f.groupBits6 > 0 {
+		return &prefixGroupWriter{w: w, bits4: f.groupBits4, bits6: f.groupBits6}
+	}
+	return w
+}
+
+func (f *fragment) snapshot() error {
+	if f.partial == "" || (f.m == nil && f.spill == nil) {
+		return nil
+	}
+
+	out, err := createAtomic(f.partial)
+	if err != nil {
+		return err
+	}
+
+	if err := f.writeTo(f.writerSink(out)); err != nil {
+		out.Abort()
+		return err
+	}
+
+	return out.Commit()
+}
+
+func (f *fragment) removePartial() error {
+	if f.partial == "" {
+		return nil
+	}
+	if err := os.Remove(f.partial); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (f *fragment) close() error {
+	if f.spill == nil {
+		return nil
+	}
+	return f.spill.Close()
+}
+
+func (f *fragment) load(in io.Reader) error {
+	return scanLines(in, func(n int, line string) error {
+		if strings.HasPrefix(line, "#") {
+			return nil
+		}
+
+		ipSep, subSep := f.separators()
+		key, values, ok := strings.Cut(line, ipSep)
+		if !ok || key == "" || values == "" {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+
+		for _, value := range strings.Split(values, subSep) {
+			ip, subdomain := key, value
+			if f.sortMode == sortSubdomain {
+				ip, subdomain = value, key
+			}
+			addr, err := netip.ParseAddr(ip)
+			if err != nil {
+				return fmt.Errorf("malformed line %d: %q", n, line)
+			}
+			f.append(addr.Unmap(), subdomain)
+		}
+		return nil
+	})
+}
+
+func scanLines(in io.Reader, fn func(n int, line string) error) error {
+	scanner := bufio.NewScanner(in)
+	for n := 1; scanner.Scan(); n++ {
+		line := cleanLine(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if err := fn(n, line); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+type fragmentOptions struct {
+	append      bool
+	force       bool
+	stream      bool
+	spillDir    string
+	sortBudget  int
+	sortMode    sortMode
+	format      outputFormat
+	shards      int
+	maxFileSize int64
+	ipSep       string
+	subSep      string
+}
+
+func openFragment(path string, opts fragmentOptions) ([]*atomicFile, fragment, error) {
+	frag := fragment{
+		m:          make(map[netip.Addr][]string),
+		sortBudget: opts.sortBudget,
+		sortDir:    opts.spillDir,
+		sortMode:   opts.sortMode,
+		format:     opts.format,
+		ipSep:      opts.ipSep,
+		subSep:     opts.subSep,
+	}
+	if opts.stream {
+		sp, err := newSpill(opts.spillDir, opts.sortMode)
+		if err != nil {
+			return nil, fragment{}, err
+		}
+		if opts.sortBudget > 0 {
+			sp.chunkSize = opts.sortBudget
+		}
+		frag = fragment{spill: sp, format: opts.format, ipSep: opts.ipSep, subSep: opts.subSep}
+	}
+
+	if path == stdoutPath {
+		return nil, frag, nil
+	}
+
+	paths := shardPaths(path, opts.shards)
+	if opts.append {
+		var existing []string
+		for _, path := range paths {
+			existing = append(existing, path)
+			if opts.maxFileSize <= 0 {
+				continue
+			}
+			segments, err := existingSegments(path)
+			if err != nil {
+				frag.close()
+				return nil, fragment{}, err
+			}
+			for _, s := range segments {
+				existing = append(existing, s.path)
+			}
+		}
+
+		for _, path := range existing {
+			in, err := os.Open(path)
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+			case err != nil:
+				frag.close()
+				return nil, fragment{}, err
+			default:
+				err := frag.load(in)
+				in.Close()
+				if err != nil {
+					frag.close()
+					return nil, fragment{}, fmt.Errorf("failed to load existing output %q: %v", path, err)
+				}
+			}
+		}
+	}
+
+	outs := make([]*atomicFile, 0, len(paths))
+	for _, path := range paths {
+		out, err := createAtomic(path)
+		if err != nil {
+			abortAll(outs)
+			frag.close()
+			return nil, fragment{}, err
+		}
+		out.maxSize = opts.maxFileSize
+		out.contentType = opts.format.contentType()
+		out.replace = opts.append || opts.force
+		outs = append(outs, out)
+	}
+
+	return outs, frag, nil
+}
+
+func (m *ipSubMap) enumerate(in io.Reader) (err error) {
+	parent := m.span
+	m.span = m.tracer.start("enumerate", parent, spanKindInternal)
+	defer func() {
+		m.span.end(err)
+		m.span = parent
+	}()
+
+	if m.exclusions != nil {
+		if err := m.exclusions.reload(); err != nil {
+			return err
+		}
+	}
+
+	stop := make(chan struct{})
+	lines, readErr := m.pipeline(in, stop)
+	m.remainingOut = bufio.NewWriter(io.Discard)
+	if m.remaining != nil {
+		m.remainingOut = bufio.NewWriter(m.remaining)
+	}
+	defer func() { m.remainingOut = nil }()
+	var errs []error
+	m.lastFlush = time.Now()
+	for p := range lines {
+		if p.parent == nil && m.overBudget(p.line) {
+			if !stopped(stop) {
+				close(stop)
+			}
+			continue
+		}
+
+		<-p.done
+		m.pending = p
+		switch {
+		case p.parent == nil:
+			if err := m.lookup(p.line); err != nil {
+				errs = append(errs, err)
+			}
+		case p.parent.expand:
+			m.resolveCandidate(p.line, p.kind, p.source, nil)
+		}
+		m.pending = nil
+
+		if m.flushInterval > 0 && time.Since(m.lastFlush) >= m.flushInterval {
+			if err := m.flush(); err != nil {
+				errs = append(errs, err)
+			}
+			m.lastFlush = time.Now()
+		}
+	}
+	if err := readErr(); err != nil {
+		return fmt.Errorf("failed to read input: %v", err)
+	}
+
+	if !m.expired {
+		if m.permutationWords != nil {
+			m.permute()
+		}
+		if m.brute != nil {
+			m.bruteForce()
+		}
+		if m.tryTransfer {
+			m.tryAXFR(m.axfrDomains)
+		}
+	}
+
+	if m.remainingErr == nil {
+		if err := m.remainingOut.Flush(); err != nil {
+			m.remainingErr = fmt.Errorf("failed to write unprocessed input: %v", err)
+		}
+	}
+	return errors.Join(append(errs, m.remainingErr)...)
+}
+
+// pastDeadline reports whether the -max-duration deadline has passed.
+func (m *ipSubMap) pastDeadline() bool {
+	if !m.expired && !m.deadline.IsZero() && time.Now().After(m.deadline) {
+		m.expired = true
+	}
+	return m.expired
+}
+
+// overBudget reports whether the -max-duration deadline has passed. Once it
+// has, name is counted as unprocessed and written to -out-remaining instead of
+// being resolved.
+func (m *ipSubMap) overBudget(name string) bool {
+	if !m.pastDeadline() {
+		return false
+	}
+	m.unprocessed++
+	if m.remainingOut != nil && m.remainingErr == nil {
+		if _, err := fmt.Fprintln(m.remainingOut, name); err != nil {
+			m.remainingErr = fmt.Errorf("failed to write unprocessed input: %v", err)
+		}
+	}
+	return true
+}
+
+func cleanLine(line string) string {
+	line = strings.TrimPrefix(line, "\ufeff")
+	return strings.TrimRight(line, "\r")
+}
+
+func (m *ipSubMap) flush() error {
+	var errs []error
+	for _, f := range m.fragments() {
+		if err := f.snapshot(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to flush partial results to %q: %v", f.partial, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *ipSubMap) removePartials() error {
+	var errs []error
+	for _, f := range m.fragments() {
+		if err := f.removePartial(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *ipSubMap) close() {
+	for _, f := range m.fragments() {
+		f.close()
+	}
+	if m.in != nil {
+		m.in.Close()
+	}
+}
+
+func (m *ipSubMap) fragments() []*fragment {
+	return []*fragment{&m.private, &m.public, &m.loopback}
+}
+
+func (m *ipSubMap) write() (err error) {
+	span := m.tracer.start("write", m.span, spanKindInternal)
+	defer func() { span.end(err) }()
+
+	var errs []error
+	if err := m.private.write(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write private ip subdomains: %v", err))
+	}
+
+	if err := m.public.write(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write public ip subdomains: %v", err))
+	}
+
+	if err := m.loopback.write(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to write loopback ip subdomains: %v", err))
+	}
+
+	for _, r := range m.reports {
+		if r.sink {
+			continue
+		}
+		if err := r.write(r.out); err != nil {
+			errs = append(errs, fmt.Errorf("failed to write %s: %v", r.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// writeSinks flushes the sink reports and returns the ones that failed, by
+// name.
+func (m *ipSubMap) writeSinks() map[string]error {
+	failed := make(map[string]error)
+	for _, r := range m.reports {
+		if !r.sink {
+			continue
+		}
+		if err := r.write(r.out); err != nil {
+			failed[r.name] = err
+		}
+	}
+	return failed
+}
+
+func (m *ipSubMap) lookup(line string) error {
+	m.progress.entry()
+
+	switch m.input {
+	case inputMassdns:
+		return m.massdnsLine(line)
+	case inputDnsx:
+		return m.dnsxLine(line)
+	case inputAmass:
+		return m.amassLine(line)
+	case inputCSV:
+		return m.csvLine(line)
+	}
+	return m.lookupEntry(line)
+}
+
+func (m *ipSubMap) lookupEntry(line string) error {
+	switch m.mode {
+	case modeReverse:
+		return m.reverse(line)
+	case modeClassify:
+		return m.classifyLine(line)
+	}
+
+	if !m.allowsHost(line, "") {
+		return nil
+	}
+	if m.seen != nil {
+		if m.seen[line] {
+			return nil
+		}
+		m.seen[line] = true
+		if m.permutationWords != nil || m.tryTransfer {
+			m.inputNames = append(m.inputNames, line)
+		}
 	}
 
-	if in.IsDir() {
-		return fmt.Errorf("input file is a directory")
-	}
+	err := m.resolve(line)
+	m.expand(line)
+	return err
+}
 
-	if allEmptyStrings(f.outputPrivate, f.outputPublic, f.outputLoopback) {
-		return fmt.Errorf("no output files specified")
+func (m *ipSubMap) allowsHost(host string, ip string) bool {
+	if ok, reason := m.filterHost(host); !ok {
+		m.droppedHosts++
+		m.exclude(host, ip, reason)
+		return false
 	}
+	return true
+}
 
-	if f.outputPrivate != "" {
-		_, err := os.Stat(f.outputPrivate)
-		if !errors.Is(err, os.ErrNotExist) {
-			return fmt.Errorf("output file %q already exists", f.outputPrivate)
+// filterHost checks host against the scope and host filters without
+// recording anything, so that it can be called from the pipeline.
+func (m *ipSubMap) filterHost(host string) (bool, string) {
+	if m.scope != nil {
+		if ok, reason := m.scope.hostInScope(host); !ok {
+			return false, reason
 		}
 	}
 
-	if f.outputPublic != "" {
-		_, err := os.Stat(f.outputPublic)
-		if !errors.Is(err, os.ErrNotExist) {
-			return fmt.Errorf("output file %q already exists", f.outputPublic)
+	if m.hosts != nil {
+		if ok, reason := m.hosts.allows(host); !ok {
+			return false, reason
 		}
 	}
 
-	if f.outputLoopback != "" {
-		_, err := os.Stat(f.outputLoopback)
-		if !errors.Is(err, os.ErrNotExist) {
-			return fmt.Errorf("output file %q already exists", f.outputLoopback)
+	if m.exclusions != nil {
+		if ok, reason := m.exclusions.allows(host); !ok {
+			return false, reason
 		}
 	}
 
-	if !f.ipv4 && !f.ipv6 {
-		return fmt.Errorf("no ip version specified")
+	return true, ""
+}
+
+func (m *ipSubMap) resolve(subdomain string) error {
+	ips, err := m.resolveIPs(subdomain)
+	if len(m.vantages) > 0 {
+		m.compareVantages(subdomain, ips, err)
+	}
+	if err != nil {
+		m.fail(subdomain, err)
+		return fmt.Errorf("failed to resolve subdomain %q: %v", subdomain, err)
+	}
+
+	if !m.recordAll(subdomain, ips) {
+		m.fail(subdomain, errNoAddresses)
+	}
+	if m.followCNAMEs {
+		if target := m.canonicalName(subdomain); target != "" {
+			m.cname(subdomain, target)
+		}
 	}
 
 	return nil
 }
 
-func allEmptyStrings(first string, others ...string) bool {
-	if first != "" {
-		return false
+var lookupIP = net.LookupIP
+
+func (m *ipSubMap) resolveIPs(subdomain string) ([]net.IP, error) {
+	var ips []net.IP
+	var err error
+	var elapsed time.Duration
+	if p := m.pending; p != nil && p.resolved && p.line == subdomain {
+		p.resolved = false
+		ips, err, elapsed = p.ips, p.err, p.elapsed
+	} else {
+		ips, elapsed, err = m.timedLookup(m.span, subdomain)
 	}
+	m.latency.observe(zoneOf(subdomain), elapsed, err)
+	return ips, err
+}
 
-	for _, s := range others {
-		if s != "" {
-			return false
+// recordAll records the addresses of the requested ip versions and reports
+// whether there were any.
+func (m *ipSubMap) recordAll(subdomain string, ips []net.IP) bool {
+	resolved := false
+	for _, ip := range ips {
+		addr := addrFromIP(ip)
+		if !m.wantsAddr(addr) {
+			continue
 		}
+		resolved = true
+		m.record(subdomain, addr)
 	}
+	return resolved
+}
 
-	return true
+// wantsAddr reports whether addr is of one of the requested ip versions.
+func (m *ipSubMap) wantsAddr(addr netip.Addr) bool {
+	return addr.Is4() && m.ipv4 || addr.Is6() && m.ipv6
 }
 
-type ipSubMap struct {
-	private  fragment
-	public   fragment
-	loopback fragment
+func (m *ipSubMap) record(subdomain string, ip netip.Addr) {
+	ip = ip.Unmap()
+	ipStr := formatIP(ip)
+	if m.scope != nil {
+		if ok, reason := m.scope.ipInScope(ip); !ok {
+			m.droppedIPs++
+			m.exclude(subdomain, ipStr, reason)
+			return
+		}
+	}
+
+	if m.cidrs != nil {
+		if ok, reason := m.cidrs.allows(ip); !ok {
+			m.droppedIPs++
+			m.exclude(subdomain, ipStr, reason)
+			return
+		}
+	}
 
-	ipv4 bool
-	ipv6 bool
+	if m.ignored != nil && m.ignored.contains(ip) {
+		m.ignoredIPs++
+		return
+	}
+
+	class := classify(ip)
+	m.count(class)
+	m.fragment(class).append(ip, subdomain)
+	for _, r := range m.reports {
+		r.add(class, ipStr, subdomain)
+	}
 }
 
-type fragment struct {
-	out io.Writer
-	m   map[string][]string
+var errNoAddresses = errors.New("no addresses of the requested ip versions")
+
+func (m *ipSubMap) fail(subdomain string, err error) {
+	if m.failures == nil {
+		m.failures = make(map[string]int)
+	}
+	m.failures[errorCategory(err)]++
+
+	for _, r := range m.reports {
+		if f, ok := r.report.(failureReport); ok {
+			f.fail(subdomain, err)
+		}
+	}
 }
 
-func (f *fragment) append(ip string, subdomain string) {
-	if f.m == nil {
-		return
+func (m *ipSubMap) exclude(subdomain string, ip string, reason string) {
+	for _, r := range m.reports {
+		if e, ok := r.report.(exclusionReport); ok {
+			e.exclude(subdomain, ip, reason)
+		}
 	}
-	f.m[ip] = append(f.m[ip], subdomain)
 }
 
-func (f *fragment) write() error {
-	if f.m == nil || len(f.m) == 0 || f.out == nil {
-		return nil
+func (m *ipSubMap) count(class string) {
+	if m.counts == nil {
+		m.counts = make(map[string]int)
 	}
-	keys := make([]string, 0, len(f.m))
-	for k := range f.m {
-		keys = append(keys, k)
+	m.counts[class]++
+	m.progress.add(class)
+}
+
+func classify(ip netip.Addr) string {
+	switch {
+	case ip.IsLoopback():
+		return classLoopback
+	case ip.IsPrivate():
+		return classPrivate
+	default:
+		return classPublic
+	}
+}
+
+func (m *ipSubMap) fragment(class string) *fragment {
+	switch class {
+	case classLoopback:
+		return &m.loopback
+	case classPrivate:
+		return &m.private
+	default:
+		return &m.public
 	}
+}
 
-	sort.Strings(keys)
+func (f *Flags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&f.version, "version", false, "Print version and build information and exit")
+	fs.BoolVar(&f.printSchema, "print-schema", false, "Print the JSON Schema of the JSON documents written by ipsubmap and exit")
+	fs.StringVar(&f.config, "config", "", "YAML or TOML file with flag values. Flags on the command line take precedence")
+	fs.StringVar(&f.inputFile, "file", "", "Input file, or an http(s) URL to download it from")
+	fs.StringVar(&f.fileHeader, "file-header", "", "Header sent when downloading -file from a URL, e.g. \"Authorization: Bearer <token>\"")
+	fs.StringVar(&f.outputPrivate, "out-private", "", "Output file for private ip subdomains")
+	fs.StringVar(&f.outputPublic, "out-public", "", "Output file for public ip subdomains")
+	fs.StringVar(&f.outputLoopback, "out-loopback", "", "Output file for loopback ip subdomains")
+	fs.StringVar(&f.outputCombined, "out", "", "Output file with all ip subdomains and their class in a single file")
+	fs.StringVar(&f.outputIPs, "out-ips", "", "Output file with only the unique ip addresses, grouped by class")
+	fs.StringVar(&f.outputUnresolved, "out-unresolved", "", "Output file for subdomains that did not resolve to any usable ip address")
+	fs.StringVar(&f.outputErrors, "errors-out", "", "Output file for failed lookups, one JSON object per line")
+	fs.StringVar(&f.outputOOS, "out-oos", "", "Output file for hostnames and ip addresses dropped by scope or filters, with the reason")
+	fs.StringVar(&f.report, "report", "", "Human readable report file. Written as HTML when the name ends in .html, Markdown otherwise")
+	fs.StringVar(&f.reportBaseline, "report-baseline", "", "Previous combined output (-out) to compare against in the report")
+	fs.StringVar(&f.outputNew, "out-new", "", "Output file with the results not in -report-baseline, in the -out format")
+	fs.StringVar(&f.outputGone, "out-gone", "", "Output file with the -report-baseline results that are gone, in the -out format")
+	fs.StringVar(&f.outputLatency, "out-latency", "", "Output file with lookup counts, failures and average and maximum latency per zone and resolver, slowest first")
+	fs.StringVar(&f.outputDot, "out-dot", "", "Graphviz DOT file with the subdomain to ip address graph, colored by class")
+	fs.StringVar(&f.outputNmap, "out-nmap", "", "Target list for nmap/masscan -iL with unique ip addresses. A companion <name>.map<ext> file maps each ip address to its hostnames")
+	fs.BoolVar(&f.nmapAggregate, "nmap-aggregate", false, "Aggregate -out-nmap targets into CIDRs")
+	fs.StringVar(&f.outputURLs, "out-urls", "", "Output file with https:// URLs for every resolved subdomain, for httpx, aquatone or nuclei")
+	fs.BoolVar(&f.urlsPerIP, "urls-per-ip", false, "Write one -out-urls line per resolved ip address, followed by a tab and the Host header to send")
+	fs.StringVar(&f.outputShared, "out-shared", "", "Output file listing ip addresses hosting at least -shared-threshold subdomains, most shared first")
+	fs.IntVar(&f.sharedThreshold, "shared-threshold", 5, "Minimum number of subdomains for an ip address to be listed in -out-shared")
+	fs.StringVar(&f.outputPTRZone, "out-ptr-zone", "", "Output file with in-addr.arpa and ip6.arpa PTR records pointing each ip address at its subdomains, as a zone file fragment")
+	fs.StringVar(&f.outputCIDRs, "out-cidrs", "", "Output file aggregating public ip addresses into the minimal set of CIDRs, with ip address and subdomain counts")
+	fs.IntVar(&f.groupByPrefix, "group-by-prefix", 0, "Group output lines under their containing IPv4 prefix of this length, with a subtotal header per prefix")
+	fs.IntVar(&f.groupByPrefix6, "group-by-prefix6", 64, "IPv6 prefix length used with -group-by-prefix")
+	fs.StringVar(&f.template, "template", "", `Go template for each line of -out-private, -out-public and -out-loopback, e.g. '{{.IP}}\t{{.Class}}\t{{join .Subdomains " "}}'`)
+	fs.StringVar(&f.ipSep, "ip-sep", " ", `Separator between the ip address and its subdomains in -out-private, -out-public and -out-loopback. \t is a tab`)
+	fs.StringVar(&f.subSep, "sub-sep", "", "Separator between subdomains in -out-private, -out-public and -out-loopback, defaults to a comma, or a space with -format hosts")
+	fs.IntVar(&f.maxSubsPerIP, "max-subs-per-ip", 0, "Only list the first N subdomains of an ip address in -out-private, -out-public and -out-loopback. 0 lists all")
+	fs.StringVar(&f.outputOverflow, "out-overflow", "", "Output file with the full subdomain list of ip addresses truncated by -max-subs-per-ip, in the -out format")
+	fs.IntVar(&f.shards, "shards", 1, "Split each of -out-private, -out-public and -out-loopback into this many files, partitioned by ip address hash")
+	fs.StringVar(&f.maxFileSize, "max-file-size", "", "Rotate -out-private, -out-public and -out-loopback into numbered files (public.1.txt, ...) once they exceed this size, e.g. 100MB")
+	fs.StringVar(&f.webhook, "webhook", "", "URL to POST a JSON summary to when the run completes")
+	fs.BoolVar(&f.webhookFindings, "webhook-findings", false, "Include new and gone results compared to -report-baseline in the -webhook payload")
+	fs.StringVar(&f.notifySlack, "notify-slack", "", "Slack incoming webhook URL to post a run summary to")
+	fs.StringVar(&f.notifyDiscord, "notify-discord", "", "Discord webhook URL to post a run summary to")
+	fs.StringVar(&f.outputElastic, "out-elastic", "", "Elasticsearch index url (http://host:9200/index) to bulk index one document per resolved subdomain and ip address into")
+	fs.StringVar(&f.outputKafka, "out-kafka", "", "Kafka broker and topic (broker:9092/topic) to produce a message per resolved subdomain and ip address to")
+	fs.StringVar(&f.outputSplunk, "out-splunk", "", "Splunk HTTP Event Collector url (https://host:8088) to send an event per resolved subdomain and ip address to")
+	fs.StringVar(&f.splunkToken, "splunk-token", os.Getenv("SPLUNK_HEC_TOKEN"), "Splunk HTTP Event Collector token, defaults to $SPLUNK_HEC_TOKEN")
+	fs.StringVar(&f.splunkIndex, "splunk-index", "", "Splunk index for -out-splunk events, defaults to the token's default index")
+	fs.StringVar(&f.splunkSourcetype, "splunk-sourcetype", "ipsubmap", "Splunk sourcetype for -out-splunk events")
+	fs.StringVar(&f.logSyslog, "log-syslog", "", "Also send logs to syslog: local, udp://host:port or tcp://host:port")
+	fs.BoolVar(&f.syslogFindings, "syslog-findings", false, "Also send each resolved subdomain and ip address to -log-syslog")
+	fs.StringVar(&f.logFormat, "log-format", logFormatText, "Log format: text or json")
+	fs.StringVar(&f.logLevel, "log-level", "info", "Minimum log level: debug, info, warn or error")
+	fs.BoolVar(&f.quiet, "quiet", false, "Only log errors")
+	fs.DurationVar(&f.logInterval, "log-interval", 0, "Log the number of input entries and results per class at this interval during enumeration (e.g. 30s)")
+	fs.StringVar(&f.otelEndpoint, "otel-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "OTLP/HTTP collector endpoint (http://localhost:4318) to export enumeration and lookup traces to")
+	fs.StringVar(&f.pprofAddr, "pprof-addr", "", "Address (:6060) to serve net/http/pprof profiles on while the run is in progress")
+	fs.StringVar(&f.outputByHost, "out-by-host", "", "Output file mapping each subdomain to its ip addresses and classes")
+	fs.StringVar(&f.failOn, "fail-on", "", "Comma separated classes (private, public, loopback) that make the run exit with status 3 if any results land in them")
+	fs.StringVar(&f.scopeFile, "scope", "", "Scope file with hostnames (*.example.com for subdomains), ip addresses and CIDRs, one per line. Prefix an entry with ! to exclude it")
+	fs.StringVar(&f.scopeH1, "scope-h1", "", "HackerOne scope export (structured scopes JSON) to filter by")
+	fs.StringVar(&f.scopeBugcrowd, "scope-bugcrowd", "", "Bugcrowd scope export (targets JSON) to filter by")
+	fs.Var(&f.cidrs.include, "include-cidr", "Only keep ip addresses within this CIDR. Can be repeated")
+	fs.Var(&f.cidrs.exclude, "exclude-cidr", "Drop ip addresses within this CIDR. Can be repeated")
+	fs.StringVar(&f.ignoreIPs, "ignore-ips", "", "File with known ip addresses and CIDRs, one per line, whose results are left out of every output without being reported")
+	fs.StringVar(&f.expand, "expand", "", "Comma separated expansions also resolved for each input hostname: apex for its registered domain, or a label such as www to prefix the registered domain with")
+	fs.StringVar(&f.outputExpanded, "out-expanded", "", "Output file listing the -expand, -permute, -brute and -try-axfr hostnames that resolved, with the expansion and the input hostname they came from")
+	fs.BoolVar(&f.permute, "permute", false, "After the input, resolve permutations of each input hostname built from -permutation-words and numbers, keeping those that resolve")
+	fs.StringVar(&f.permutationWords, "permutation-words", "", "File with words for -permute, one per line")
+	fs.BoolVar(&f.brute, "brute", false, "After the input, resolve <word>.<domain> for every -wordlist word and -domain, keeping names that resolve outside of wildcard records. -file is optional")
+	fs.StringVar(&f.wordlist, "wordlist", "", "File with subdomain labels for -brute, one per line")
+	fs.StringVar(&f.domain, "domain", "", "Comma separated apex domains to brute force with -brute, transfer with -try-axfr, or look up with discover")
+	fs.Var(&f.vantages, "vantage", "Also resolve each hostname through a DNS-over-HTTPS JSON endpoint as name=url, such as eu=https://dns.google/resolve?edns_client_subnet=85.0.0.0/24. Can be repeated")
+	fs.StringVar(&f.outputGeo, "out-geo", "", "Output file listing the hostnames whose answers differ between -vantage endpoints and the local resolver")
+	fs.BoolVar(&f.dryRun, "dry-run", false, "Validate the flags, check the input for duplicate and invalid lines and check that the resolver answers, then exit without resolving")
+	fs.DurationVar(&f.maxDuration, "max-duration", 0, "Stop resolving once this much time has passed and write what was resolved so far, such as 2h. 0 means no limit")
+	fs.StringVar(&f.outputRemaining, "out-remaining", "", "Output file with the input lines left unprocessed when -max-duration is reached, to be used as -file of a follow-up run")
+	fs.IntVar(&f.workers, "workers", 1, "Number of hostnames resolved concurrently while the input is read and results are recorded, including -expand, -permute, -brute and -try-axfr candidates; -mode reverse and -input-format csv resolve one name at a time")
+	fs.StringVar(&f.outputExternal, "out-external", "", "Output file listing the subdomains that are aliases (CNAME) of names in another registered domain, with that domain and the alias target")
+	fs.StringVar(&f.negativeCache, "negative-cache", "", "File caching hostnames that do not exist across runs. They are not queried again until their entry expires")
+	fs.DurationVar(&f.negativeTTL, "negative-ttl", time.Hour, "How long -negative-cache entries are kept. With -authoritative, at most the negative TTL from the SOA record of their zone")
+	fs.IntVar(&f.perZone, "per-zone", 0, "Maximum number of -workers resolving hostnames of the same registered domain at once. 0 means no limit")
+	fs.IntVar(&f.samples, "samples", 1, "Number of times each hostname is resolved, collecting every address a round-robin pool rotates through")
+	fs.StringVar(&f.outputRotation, "out-rotation", "", "Output file with the number of distinct ip addresses each subdomain resolved to, followed by the addresses")
+	fs.BoolVar(&f.authoritative, "authoritative", false, "Query the nameservers of each hostname's zone directly instead of the recursive resolver")
+	fs.BoolVar(&f.tryAXFR, "try-axfr", false, "After the input, attempt a zone transfer of the zone of each input hostname and -domain from each of its nameservers, and resolve the transferred names")
+	fs.StringVar(&f.backend, "resolver-backend", string(backendSystem), "DNS resolver implementation: system (chosen by the Go runtime), go (pure Go, reads /etc/resolv.conf and /etc/hosts) or cgo (the C library, with NSS modules)")
+	fs.StringVar(&f.sourceIP, "source-ip", "", "Local ip address dns queries are sent from")
+	fs.StringVar(&f.iface, "interface", "", "Network interface dns queries are sent from, using its first address")
+	fs.StringVar(&f.match, "match", "", "Only resolve hostnames matching this regular expression")
+	fs.StringVar(&f.exclude, "exclude", "", "Skip hostnames matching this regular expression")
+	fs.StringVar(&f.excludeFile, "exclude-file", "", "File with hostnames to skip (*.example.com for subdomains), one per line")
+	fs.StringVar(&f.mode, "mode", string(modeResolve), "Input mode: resolve (hostnames), reverse (ip addresses and CIDRs, looked up by PTR) or classify (subdomain followed by ip addresses, or ip addresses only, without DNS lookups)")
+	fs.StringVar(&f.input, "input-format", string(inputText), "Input file format: text (one entry per line), csv (see -host-column), or massdns (massdns -o S), dnsx (dnsx -json) or amass (amass enum -json) output, used without resolving again")
+	fs.StringVar(&f.hostColumn, "host-column", "", "Name of the -input-format csv header column with the hostnames")
+	fs.BoolVar(&f.ipv4, "ipv4", true, "Resolve ipv4 addresses. True by default")
+	fs.BoolVar(&f.ipv6, "ipv6", true, "Resolve ipv6 addresses. True by default")
+	fs.StringVar(&f.ipv6Format, "ipv6-format", string(ipv6Canonical), "How ipv6 addresses are written: canonical (2001:db8::1) or expanded (2001:0db8:0000:0000:0000:0000:0000:0001)")
+	fs.DurationVar(&f.flushInterval, "flush-interval", 0, "Periodically snapshot results to <output>.partial files during enumeration (e.g. 60s)")
+	fs.BoolVar(&f.stream, "stream", false, "Spill results to disk during enumeration to keep memory bounded on huge inputs")
+	fs.StringVar(&f.spillDir, "spill-dir", "", "Directory for temporary spill files in stream mode. Defaults to the system temp directory")
+	fs.StringVar(&f.format, "format", string(formatList), "Format of the per-class output files: list (ip followed by comma separated subdomains), hosts (/etc/hosts lines), ansible or ansible-yaml (inventory with one group per class), zone (A and AAAA records), proto (length-delimited protobuf Mapping messages, see ipsubmap.proto), parquet (one row per subdomain and ip address), msgpack (one MessagePack map per subdomain and ip address), json (one JSON record per subdomain and ip address, a line each), csv (one row per subdomain and ip address), or sqlite (writes -out as a SQLite database instead)")
+	fs.BoolVar(&f.timestamps, "timestamps", false, "Include the time each hostname was first resolved (RFC3339, UTC) in every -format ansible, ansible-yaml or zone record. Json, csv, msgpack and parquet records always include it")
+	fs.StringVar(&f.sort, "sort", string(sortNumeric), "Output ordering: lexical or numeric by ip address, or subdomain for one line per subdomain listing its ip addresses")
+	fs.IntVar(&f.sortBudget, "sort-budget", defaultSpillChunkSize, "Maximum number of entries sorted in memory before falling back to an external merge sort in -spill-dir. 0 disables the limit")
+	fs.BoolVar(&f.force, "force", false, "Overwrite existing output files")
+	fs.BoolVar(&f.append, "append", false, "Merge results into existing output files instead of refusing to overwrite them")
+}
 
-	output := fmt.Sprintf("%s %s", keys[0], strings.Join(f.m[keys[0]], ","))
-	if _, err := f.out.Write([]byte(output)); err != nil {
-		return err
+func runResolve(args []string) {
+	fs := flag.NewFlagSet("resolve", flag.ExitOnError)
+	var flags Flags
+	flags.register(fs)
+	fs.Parse(args)
+	resolveWith(fs, &flags)
+}
+
+func runDiscover(args []string) {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	flags := Flags{discover: true}
+	flags.register(fs)
+	flags.registerDiscover(fs)
+	fs.Parse(args)
+	resolveWith(fs, &flags)
+}
+
+func resolveWith(fs *flag.FlagSet, flags *Flags) {
+
+	if flags.version {
+		fmt.Println(readBuildDetails())
+		os.Exit(0)
 	}
-	for _, k := range keys[1:] {
-		output := fmt.Sprintf("\n%s %s", k, strings.Join(f.m[k], ","))
-		if _, err := f.out.Write([]byte(output)); err != nil {
-			return err
+	if flags.printSchema {
+		fmt.Print(outputSchema)
+		os.Exit(0)
+	}
+
+	configErr := applyEnv(fs, os.Environ())
+	if configErr == nil && flags.config != "" {
+		values, err := loadConfig(flags.config)
+		if err == nil {
+			err = applyConfig(fs, values)
 		}
+		configErr = err
 	}
 
-	return nil
-}
+	// Invalid levels fall back to info here and are reported by Validate.
+	level, _ := logLevel(flags.logLevel, flags.quiet)
+	handler := newLogHandler(os.Stderr, flags.logFormat, level)
+	logger := slog.New(handler).With(slog.String("app", "ipsubmap"))
 
-func (m *ipSubMap) enumerate(in io.Reader) error {
-	scanner := bufio.NewScanner(in)
-	var errs []error
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			continue
+	if configErr != nil {
+		logger.Error("failed to load configuration", "error", configErr)
+		os.Exit(1)
+	}
+
+	if err := flags.Validate(); err != nil {
+		logger.Error("failed to validate flags", "error", err)
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	outputIPv6Format = ipv6Format(flags.ipv6Format)
+
+	if err := useResolverBackend(flags.resolverBackend); err != nil {
+		logger.Error("failed to select resolver backend", "error", err)
+		os.Exit(1)
+	}
+
+	var source net.IP
+	if flags.sourceIP != "" || flags.iface != "" {
+		source = net.ParseIP(flags.sourceIP)
+		if flags.iface != "" {
+			ip, err := interfaceAddr(flags.iface, flags.ipv6 && !flags.ipv4)
+			if err != nil {
+				logger.Error("failed to find interface address", "error", err)
+				os.Exit(1)
+			}
+			source = ip
+		}
+		bindSource(source)
+		logger.Debug("Binding lookups to source address", "ip", source)
+	}
+	var auth *authoritative
+	if flags.authoritative {
+		auth = newAuthoritative(source)
+		lookupIP = auth.lookupIP
+	}
+	probeIP = lookupIP
+	var negative *negativeCache
+	if flags.negativeCache != "" {
+		negative = newNegativeCache(flags.negativeTTL, lookupIP)
+		if auth != nil {
+			negative.soaTTL = auth.negativeTTL
+		}
+		if err := negative.loadFile(flags.negativeCache); err != nil {
+			logger.Error("failed to load negative cache", "error", err)
+			os.Exit(1)
 		}
+		lookupIP = negative.lookupIP
+	}
+	// Samples need every lookup to reach the resolver.
+	lookups := newLookupGroup(lookupIP, flags.samples <= 1)
+	lookupIP = lookups.lookupIP
 
-		if err := m.resolve(line); err != nil {
-			errs = append(errs, err)
+	var syslogOut syslogWriter
+	if flags.logSyslog != "" {
+		w, err := dialSyslog(flags.logSyslog)
+		if err != nil {
+			logger.Error("failed to connect to syslog", "error", err)
+			os.Exit(1)
 		}
+		syslogOut = w
+		logger = slog.New(multiHandler{handler, newSyslogHandler(w, level)}).With(slog.String("app", "ipsubmap"))
 	}
 
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read input: %v", err)
+	if flags.pprofAddr != "" {
+		_, addr, err := startPprof(flags.pprofAddr)
+		if err != nil {
+			logger.Error("failed to start pprof server", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("Serving pprof profiles", "url", "http://"+addr.String()+"/debug/pprof/")
 	}
 
-	return errors.Join(errs...)
-}
+	var in io.ReadCloser = io.NopCloser(strings.NewReader(""))
+	if flags.inputFile != "" {
+		var err error
+		in, err = openInput(flags.inputFile, flags.fileHeader)
+		if err != nil {
+			logger.Error("failed to open input file", "error", err)
+			os.Exit(1)
+		}
+	}
 
-func (m *ipSubMap) write() error {
-	var errs []error
-	if err := m.private.write(); err != nil {
-		errs = append(errs, fmt.Errorf("failed to write private ip subdomains: %v", err))
+	var buf io.Reader = bufio.NewReader(in)
+	if flags.discover {
+		names, n, err := discoverInput(flags.ctURL, flags.domains)
+		if err != nil {
+			logger.Error("failed to discover names", "error", err)
+			in.Close()
+			os.Exit(1)
+		}
+		logger.Info("Discovered names from certificate transparency", "domains", len(flags.domains), "names", n)
+		buf = io.MultiReader(buf, strings.NewReader("\n"+names))
 	}
 
-	if err := m.public.write(); err != nil {
-		errs = append(errs, fmt.Errorf("failed to write public ip subdomains: %v", err))
+	mapper := &ipSubMap{
+		ipv4:  flags.ipv4,
+		ipv6:  flags.ipv6,
+		mode:  flags.lookupMode,
+		input: flags.inputFormat,
+		in:    in,
+
+		hostColumn: flags.hostColumn,
+		latency:    newLatencyStats(),
+		expansions: flags.expansions,
+		vantages:   flags.vantages,
+		samples:    flags.samples,
+		workers:    flags.workers,
+
+		flushInterval: flags.flushInterval,
+	}
+	mapper.followCNAMEs = flags.outputExternal != "" && !flags.inputFormat.resolved()
+	mapper.perZone = flags.perZone
+	if flags.otelEndpoint != "" {
+		service := cmp.Or(os.Getenv("OTEL_SERVICE_NAME"), "ipsubmap")
+		t, err := newTracer(flags.otelEndpoint, service)
+		if err != nil {
+			logger.Error("failed to set up tracing", "error", err)
+			mapper.close()
+			os.Exit(1)
+		}
+		mapper.tracer = t
+		mapper.span = t.start("ipsubmap", nil, spanKindInternal, stringAttr("input", flags.inputFile))
+	}
+	for _, src := range []struct {
+		path   string
+		format string
+	}{
+		{path: flags.scopeFile, format: scopeFormatText},
+		{path: flags.scopeH1, format: scopeFormatH1},
+		{path: flags.scopeBugcrowd, format: scopeFormatBugcrowd},
+	} {
+		if src.path == "" {
+			continue
+		}
+		if mapper.scope == nil {
+			mapper.scope = &scope{}
+		}
+
+		skipped, err := mapper.scope.loadFile(src.path, src.format)
+		if err != nil {
+			logger.Error("failed to load scope", "error", err)
+			mapper.close()
+			os.Exit(1)
+		}
+		if len(skipped) > 0 {
+			logger.Warn("Skipped unsupported scope assets", "file", src.path, "count", len(skipped), "assets", skipped)
+		}
 	}
 
-	if err := m.loopback.write(); err != nil {
-		errs = append(errs, fmt.Errorf("failed to write loopback ip subdomains: %v", err))
+	if flags.expand != "" || flags.permute || flags.brute || flags.tryAXFR {
+		mapper.seen = make(map[string]bool)
+	}
+	if flags.tryAXFR {
+		mapper.tryTransfer = true
+		mapper.axfrDomains = flags.domains
+		mapper.zones = auth
+	}
+	if flags.brute {
+		words, err := loadWords("-wordlist", flags.wordlist)
+		if err != nil {
+			logger.Error("failed to load wordlist", "error", err)
+			mapper.close()
+			os.Exit(1)
+		}
+		mapper.brute = &bruteForce{words: words, domains: flags.domains}
+	}
+	if flags.permute {
+		words, err := loadWords("-permutation-words", flags.permutationWords)
+		if err != nil {
+			logger.Error("failed to load permutation words", "error", err)
+			mapper.close()
+			os.Exit(1)
+		}
+		mapper.permutationWords = words
 	}
 
-	return errors.Join(errs...)
-}
+	if len(flags.cidrs.include) > 0 || len(flags.cidrs.exclude) > 0 {
+		mapper.cidrs = &flags.cidrs
+	}
 
-func (m *ipSubMap) resolve(subdomain string) error {
-	ips, err := net.LookupIP(subdomain)
-	if err != nil {
-		return fmt.Errorf("failed to resolve subdomain %q: %v", subdomain, err)
+	if flags.hosts.match != nil || flags.hosts.exclude != nil {
+		mapper.hosts = &flags.hosts
 	}
 
-	for _, ip := range ips {
-		if ip.To4() == nil && !m.ipv6 {
-			continue
+	if flags.ignoreIPs != "" {
+		l, err := loadIgnoreList(flags.ignoreIPs)
+		if err != nil {
+			logger.Error("failed to load ignored ip addresses", "error", err)
+			mapper.close()
+			os.Exit(1)
+		}
+		mapper.ignored = l
+	}
+
+	if flags.excludeFile != "" {
+		l, err := loadExclusionList(flags.excludeFile)
+		if err != nil {
+			logger.Error("failed to load exclusion list", "error", err)
+			mapper.close()
+			os.Exit(1)
 		}
-		if ip.To4() != nil && !m.ipv4 {
+		mapper.exclusions = l
+	}
+
+	if flags.dryRun {
+		code := dryRun(logger, buf, flags)
+		mapper.close()
+		os.Exit(code)
+	}
+
+	var overflow *overflowReport
+	if flags.outputOverflow != "" {
+		overflow = newOverflowReport(flags.sortMode)
+	}
+
+	var outputs []*atomicFile
+	for _, o := range []struct {
+		class string
+		path  string
+		frag  *fragment
+	}{
+		{class: "private", path: flags.outputPrivate, frag: &mapper.private},
+		{class: "public", path: flags.outputPublic, frag: &mapper.public},
+		{class: "loopback", path: flags.outputLoopback, frag: &mapper.loopback},
+	} {
+		if o.path == "" {
 			continue
 		}
 
-		ipStr := ip.String()
-		switch {
-		case ip.IsLoopback():
-			m.loopback.append(ipStr, subdomain)
-		case ip.IsPrivate():
-			m.private.append(ipStr, subdomain)
-		default:
-			m.public.append(ipStr, subdomain)
+		outs, frag, err := openFragment(o.path, fragmentOptions{
+			append:      flags.append,
+			force:       flags.force,
+			stream:      flags.stream,
+			spillDir:    flags.spillDir,
+			sortBudget:  flags.sortBudget,
+			sortMode:    flags.sortMode,
+			format:      flags.outputFormat,
+			shards:      flags.shards,
+			maxFileSize: flags.maxFileBytes,
+			ipSep:       flags.ipSep,
+			subSep:      flags.subSep,
+		})
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to create output (%s) file", o.class), "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
+		if _, remote := parseRemote(o.path); flags.flushInterval > 0 && !remote && o.path != stdoutPath {
+			frag.partial = o.path + ".partial"
+		}
+		frag.class = o.class
+		if flags.timestamps || flags.outputFormat.records() || (flags.outputKafka != "" && !flags.outputFormat.inventory()) {
+			frag.seen = make(map[string]time.Time)
+		}
+		if flags.groupByPrefix > 0 {
+			frag.groupBits4 = flags.groupByPrefix
+			frag.groupBits6 = flags.groupByPrefix6
 		}
+		frag.template = flags.lineTemplate
+		frag.maxValues = flags.maxSubsPerIP
+		frag.overflow = overflow
+		if o.path == stdoutPath {
+			frag.out = frag.stdoutSink(os.Stdout)
+		} else {
+			files := make([]io.Writer, len(outs))
+			for i, out := range outs {
+				files[i] = out
+			}
+			frag.out = frag.writerSink(files...)
+		}
+		outputs = append(outputs, outs...)
+		*o.frag = frag
 	}
 
-	return nil
-}
-
-func main() {
-	logger := slog.New(
-		slog.NewTextHandler(
-			os.Stdout,
-			&slog.HandlerOptions{
-				AddSource: true,
-				Level:     slog.LevelInfo,
-			},
-		),
-	)
+	nmap := newNmapReport(flags.nmapAggregate)
+	if flags.outputNmap != "" {
+		out, err := createAtomic(nmapMapPath(flags.outputNmap))
+		if err != nil {
+			logger.Error("failed to create output (nmap map) file", "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
+		outputs = append(outputs, out)
+		nmap.mapOut = out
+	}
 
-	logger = logger.With(slog.String("app", "ipsubmap"))
-	var flags Flags
+	if flags.outputRemaining != "" {
+		out, err := createAtomic(flags.outputRemaining)
+		if err != nil {
+			logger.Error("failed to create output (remaining) file", "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
+		outputs = append(outputs, out)
+		mapper.remaining = out
+	}
 
-	flag.StringVar(&flags.inputFile, "file", "", "Input file")
-	flag.StringVar(&flags.outputPrivate, "out-private", "", "Output file for private ip subdomains")
-	flag.StringVar(&flags.outputPublic, "out-public", "", "Output file for public ip subdomains")
-	flag.StringVar(&flags.outputLoopback, "out-loopback", "", "Output file for loopback ip subdomains")
-	flag.BoolVar(&flags.ipv4, "ipv4", true, "Resolve ipv4 addresses. True by default")
-	flag.BoolVar(&flags.ipv6, "ipv6", true, "Resolve ipv6 addresses. True by default")
+	var latencyOut *atomicFile
+	if flags.outputLatency != "" {
+		out, err := createAtomic(flags.outputLatency)
+		if err != nil {
+			logger.Error("failed to create output (latency) file", "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
+		outputs = append(outputs, out)
+		latencyOut = out
+	}
 
-	flag.Parse()
+	summary := newSummaryReport(flags.report, flags.sortMode)
+	if flags.reportBaseline != "" {
+		if err := summary.loadBaseline(flags.reportBaseline); err != nil {
+			logger.Error("failed to load report baseline", "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
+	}
 
-	if err := flags.Validate(); err != nil {
-		logger.Error("failed to validate flags", "error", err)
-		flag.Usage()
-		os.Exit(1)
+	baseline := newCombinedReport(flags.sortMode)
+	if flags.outputNew != "" || flags.outputGone != "" {
+		if err := loadFile(flags.reportBaseline, baseline); err != nil {
+			logger.Error("failed to load report baseline", "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
 	}
 
-	in, err := os.Open(flags.inputFile)
-	if err != nil {
-		logger.Error("failed to open input file", "error", err)
-		os.Exit(1)
+	var combined report = newCombinedReport(flags.sortMode)
+	if flags.outputFormat == formatSQLite {
+		combined = newSQLiteReport()
 	}
-	defer in.Close()
 
-	buf := bufio.NewReader(in)
+	for _, o := range []struct {
+		name string
+		path string
+		r    report
+	}{
+		{name: "subdomain ip addresses", path: flags.outputByHost, r: newHostReport()},
+		{name: "combined ip subdomains", path: flags.outputCombined, r: combined},
+		{name: "unique ip addresses", path: flags.outputIPs, r: newIPReport(flags.sortMode)},
+		{name: "unresolved subdomains", path: flags.outputUnresolved, r: newUnresolvedReport()},
+		{name: "lookup errors", path: flags.outputErrors, r: newErrorReport()},
+		{name: "out of scope results", path: flags.outputOOS, r: newOutOfScopeReport()},
+		{name: "report", path: flags.report, r: summary},
+		{name: "graph", path: flags.outputDot, r: newDotReport(flags.sortMode)},
+		{name: "nmap targets", path: flags.outputNmap, r: nmap},
+		{name: "urls", path: flags.outputURLs, r: newURLReport(flags.urlsPerIP)},
+		{name: "shared ip addresses", path: flags.outputShared, r: newSharedReport(flags.sharedThreshold)},
+		{name: "cidrs", path: flags.outputCIDRs, r: newCIDRReport()},
+		{name: "overflow", path: flags.outputOverflow, r: overflow},
+		{name: "expanded subdomains", path: flags.outputExpanded, r: newExpansionReport()},
+		{name: "ptr zone", path: flags.outputPTRZone, r: newPTRZoneReport()},
+		{name: "geo differences", path: flags.outputGeo, r: newGeoReport(flags.vantages)},
+		{name: "answer rotation", path: flags.outputRotation, r: newRotationReport()},
+		{name: "external cname targets", path: flags.outputExternal, r: newExternalReport()},
+		{name: "new results", path: flags.outputNew, r: newDeltaReport(baseline, false)},
+		{name: "gone results", path: flags.outputGone, r: newDeltaReport(baseline, true)},
+	} {
+		if o.path == "" {
+			continue
+		}
 
-	mapper := &ipSubMap{
-		ipv4: flags.ipv4,
-		ipv6: flags.ipv6,
+		out, err := openReport(o.path, flags.append, o.r)
+		if err != nil {
+			logger.Error(fmt.Sprintf("failed to create output (%s) file", o.name), "error", err)
+			abortAll(outputs)
+			mapper.close()
+			os.Exit(1)
+		}
+		outputs = append(outputs, out)
+		mapper.reports = append(mapper.reports, reportOutput{name: o.name, out: out, report: o.r})
 	}
-	if flags.outputPrivate != "" {
-		out, err := os.Create(flags.outputPrivate)
+
+	if flags.outputElastic != "" {
+		r, err := newElasticReport(flags.outputElastic)
 		if err != nil {
-			logger.Error("failed to create output (private) file", "error", err)
+			logger.Error("failed to create elasticsearch output", "error", err)
+			abortAll(outputs)
+			mapper.close()
 			os.Exit(1)
 		}
-		defer out.Close()
-		mapper.private = fragment{out: out, m: make(map[string][]string)}
+		mapper.reports = append(mapper.reports, reportOutput{name: "elasticsearch", out: io.Discard, sink: true, report: r})
 	}
 
-	if flags.outputPublic != "" {
-		out, err := os.Create(flags.outputPublic)
+	if flags.outputKafka != "" {
+		r, err := newKafkaReport(flags.outputKafka)
 		if err != nil {
-			logger.Error("failed to create output (public) file", "error", err)
+			logger.Error("failed to create kafka output", "error", err)
+			abortAll(outputs)
+			mapper.close()
 			os.Exit(1)
 		}
-		defer out.Close()
-		mapper.public = fragment{out: out, m: make(map[string][]string)}
+		r.seen = map[string]map[string]time.Time{
+			classPublic:   mapper.public.seen,
+			classPrivate:  mapper.private.seen,
+			classLoopback: mapper.loopback.seen,
+		}
+		mapper.reports = append(mapper.reports, reportOutput{name: "kafka", out: io.Discard, sink: true, report: r})
 	}
 
-	if flags.outputLoopback != "" {
-		out, err := os.Create(flags.outputLoopback)
+	if flags.outputSplunk != "" {
+		r, err := newSplunkReport(flags.outputSplunk, flags.splunkToken, flags.splunkIndex, flags.splunkSourcetype)
 		if err != nil {
-			logger.Error("failed to create output (loopback) file", "error", err)
+			logger.Error("failed to create splunk output", "error", err)
+			abortAll(outputs)
+			mapper.close()
 			os.Exit(1)
 		}
-		defer out.Close()
-		mapper.loopback = fragment{out: out, m: make(map[string][]string)}
+		mapper.reports = append(mapper.reports, reportOutput{name: "splunk", out: io.Discard, sink: true, report: r})
 	}
 
+	if flags.syslogFindings {
+		mapper.reports = append(mapper.reports, reportOutput{name: "syslog findings", out: io.Discard, sink: true, report: &syslogReport{w: syslogOut}})
+	}
+
+	notify := flags.webhook != "" || flags.notifySlack != "" || flags.notifyDiscord != ""
+	if notify && flags.report == "" {
+		mapper.reports = append(mapper.reports, reportOutput{name: "webhook summary", out: io.Discard, report: summary})
+	}
+
+	stopProgress := func() {}
+	if flags.logInterval > 0 {
+		mapper.progress = newProgress()
+		stopProgress = mapper.progress.report(logger, flags.logInterval)
+	}
+
+	started := time.Now()
+	if flags.maxDuration > 0 {
+		mapper.deadline = started.Add(flags.maxDuration)
+	}
 	if err := mapper.enumerate(buf); err != nil {
 		logger.Error("Encountered errors while enumerating", "error", err)
 	}
+	stopProgress()
+	if mapper.expired {
+		logger.Warn("Reached -max-duration, stopped resolving", "max_duration", flags.maxDuration, "unprocessed", mapper.unprocessed)
+	}
+	if mapper.scope != nil || mapper.cidrs != nil || mapper.hosts != nil || mapper.exclusions != nil {
+		logger.Info("Dropped filtered results", "subdomains", mapper.droppedHosts, "ips", mapper.droppedIPs)
+	}
+	if mapper.ignored != nil {
+		logger.Info("Ignored known ip addresses", "results", mapper.ignoredIPs)
+	}
+	for _, t := range mapper.transfers {
+		logger.Warn("Zone transfer allowed", "zone", t.zone, "nameserver", t.server, "names", len(t.names))
+	}
+	if len(mapper.failures) > 0 {
+		var counts []any
+		for _, category := range errorCategories {
+			if n := mapper.failures[category]; n > 0 {
+				counts = append(counts, category, n)
+			}
+		}
+		logger.Info("Failed lookups", counts...)
+	}
+	for _, l := range slowest(mapper.latency.zones, 3) {
+		logger.Info("Slow zone", "zone", l.name, "lookups", l.lookups, "failures", l.failures, "avg", l.average().Round(time.Millisecond), "max", l.max.Round(time.Millisecond))
+	}
+	for _, l := range slowest(mapper.latency.resolvers, 3) {
+		logger.Info("Slow resolver", "resolver", l.name, "failures", l.failures, "avg", l.average().Round(time.Millisecond), "max", l.max.Round(time.Millisecond))
+	}
+	failedSinks := mapper.writeSinks()
+	for name, err := range failedSinks {
+		logger.Warn("failed to write to sink", "sink", name, "error", err)
+		summary.sinkFailed(name, err)
+	}
+
 	logger.Info("Writing output files")
 
-	if err := mapper.write(); err != nil {
+	err := mapper.write()
+	if err == nil && latencyOut != nil {
+		if err = mapper.latency.write(latencyOut); err != nil {
+			err = fmt.Errorf("failed to write latency: %v", err)
+		}
+	}
+	if err != nil {
 		logger.Error("Encountered errors while writing", "error", err)
+		abortAll(outputs)
+		mapper.close()
+		os.Exit(1)
+	}
+	mapper.close()
+
+	var errs []error
+	for _, out := range outputs {
+		if err := out.Commit(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		logger.Error("Encountered errors while saving output files", "error", err)
 		os.Exit(1)
 	}
+
+	if err := mapper.removePartials(); err != nil {
+		logger.Warn("failed to remove partial output files", "error", err)
+	}
+
+	if lookups.shared > 0 {
+		logger.Info("Shared duplicate lookups", "lookups", lookups.shared)
+	}
+
+	if negative != nil {
+		logger.Info("Skipped lookups of cached missing names", "hits", negative.hits)
+		if err := saveNegativeCache(flags.negativeCache, negative); err != nil {
+			logger.Warn("failed to save negative cache", "error", err)
+		}
+	}
+
+	mapper.span.end(nil)
+	if err := mapper.tracer.shutdown(); err != nil {
+		logger.Warn("failed to export traces", "error", err)
+	}
+
+	if flags.webhook != "" {
+		payload := newWebhookPayload(summary.view(), flags.webhookFindings)
+		payload.Input = flags.inputFile
+		payload.StartedAt = started
+		payload.FinishedAt = time.Now()
+		payload.Dropped["subdomains"] = mapper.droppedHosts
+		payload.Dropped["ips"] = mapper.droppedIPs
+		if err := postJSON(flags.webhook, payload); err != nil {
+			logger.Warn("failed to send webhook", "error", err)
+		}
+	}
+
+	if flags.notifySlack != "" || flags.notifyDiscord != "" {
+		text := notificationText(flags.inputFile, summary.view())
+		if flags.notifySlack != "" {
+			if err := notifySlack(flags.notifySlack, text); err != nil {
+				logger.Warn("failed to notify slack", "error", err)
+			}
+		}
+		if flags.notifyDiscord != "" {
+			if err := notifyDiscord(flags.notifyDiscord, text); err != nil {
+				logger.Warn("failed to notify discord", "error", err)
+			}
+		}
+	}
+
+	failed := false
+	for _, class := range flags.failOnClasses {
+		if n := mapper.counts[class]; n > 0 {
+			logger.Error("Found results in failing class", "class", class, "count", n)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(3)
+	}
+	if len(failedSinks) > 0 {
+		os.Exit(4)
+	}
 }
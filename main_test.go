@@ -2,6 +2,11 @@ package main
 
 import (
 	"bytes"
+	"cmp"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,9 +20,9 @@ func TestFragmentWrite_noWriter(t *testing.T) {
 
 	t.Run("map only", func(t *testing.T) {
 		frag := fragment{
-			m: map[string][]string{
+			m: addrMap(map[string][]string{
 				"1.1.1.1": {"example.com"},
-			},
+			}),
 		}
 		if err := frag.write(); err != nil {
 			t.Errorf("unexpected error: %v", err)
@@ -25,24 +30,39 @@ func TestFragmentWrite_noWriter(t *testing.T) {
 	})
 }
 
+func TestFragmentAppend(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{m: make(map[netip.Addr][]string)}
+	frag.out = frag.writerSink(out)
+	for _, subdomain := range []string{"c.com", "a.com", "a.com", "b.com", "a.com", "c.com"} {
+		frag.append(netip.MustParseAddr("1.1.1.1"), subdomain)
+	}
+
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "1.1.1.1 a.com,b.com,c.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
 func TestFragmentWrite(t *testing.T) {
 	tt := map[string]struct {
 		frag fragment
 		want string
 	}{
 		"empty": {
-			frag: fragment{
-				out: &bytes.Buffer{},
-			},
+			frag: fragment{},
 			want: "",
 		},
 		"valid format": {
 			frag: fragment{
-				out: &bytes.Buffer{},
-				m: map[string][]string{
+				m: addrMap(map[string][]string{
 					"2.2.2.2": {"example.com"},
 					"1.1.1.1": {"example.com", "example.org"},
-				},
+				}),
 			},
 			want: "1.1.1.1 example.com,example.org\n2.2.2.2 example.com",
 		},
@@ -50,14 +70,228 @@ func TestFragmentWrite(t *testing.T) {
 
 	for name, tc := range tt {
 		t.Run(name, func(t *testing.T) {
-			if err := tc.frag.write(); err != nil {
+			out := &bytes.Buffer{}
+			if err := tc.frag.writeTo(tc.frag.writerSink(out)); err != nil {
 				t.Errorf("unexpected error: %v", err)
 			}
 
-			got := tc.frag.out.(*bytes.Buffer).String()
+			got := out.String()
 			if got != tc.want {
 				t.Errorf("expected %q, got %q", tc.want, got)
 			}
 		})
 	}
 }
+
+func TestCleanLine(t *testing.T) {
+	tt := map[string]struct {
+		line string
+		want string
+	}{
+		"plain":    {line: "example.com", want: "example.com"},
+		"crlf":     {line: "example.com\r", want: "example.com"},
+		"bom":      {line: "\ufeffexample.com", want: "example.com"},
+		"bom crlf": {line: "\ufeffexample.com\r", want: "example.com"},
+		"only cr":  {line: "\r", want: ""},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := cleanLine(tc.line); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFragmentLoad(t *testing.T) {
+	frag := fragment{m: make(map[netip.Addr][]string)}
+	in := "1.1.1.1 example.com,example.org\r\n2.2.2.2 example.com"
+	if err := frag.load(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	frag.append(netip.MustParseAddr("1.1.1.1"), "example.com")
+	frag.append(netip.MustParseAddr("1.1.1.1"), "example.net")
+
+	out := &bytes.Buffer{}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "1.1.1.1 example.com,example.net,example.org\n2.2.2.2 example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestFragmentLoad_malformed(t *testing.T) {
+	for _, in := range []string{"1.1.1.1\n", "example.com 1.1.1.1\n"} {
+		frag := fragment{m: make(map[netip.Addr][]string)}
+		if err := frag.load(strings.NewReader(in)); err == nil {
+			t.Errorf("expected error for malformed line %q", in)
+		}
+	}
+}
+
+func TestFlagsValidate_existingOutput(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.txt")
+	out := filepath.Join(dir, "public.txt")
+	rotated := filepath.Join(dir, "rotated.txt")
+	for _, name := range []string{in, out, filepath.Join(dir, "rotated.3.txt")} {
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatalf("failed to create %q: %v", name, err)
+		}
+	}
+
+	tt := map[string]struct {
+		flags   Flags
+		out     string
+		wantErr bool
+	}{
+		"exists":            {flags: Flags{}, wantErr: true},
+		"append":            {flags: Flags{append: true}},
+		"force":             {flags: Flags{force: true}},
+		"append force":      {flags: Flags{append: true, force: true}, wantErr: true},
+		"timestamps list":   {flags: Flags{timestamps: true}, wantErr: true},
+		"timestamps json":   {flags: Flags{timestamps: true, force: true, format: string(formatJSON)}},
+		"timestamps csv":    {flags: Flags{timestamps: true, force: true, format: string(formatCSV)}},
+		"segment exists":    {flags: Flags{maxFileSize: "1MB"}, out: rotated, wantErr: true},
+		"segment force":     {flags: Flags{maxFileSize: "1MB", force: true}, out: rotated},
+		"segment no rotate": {flags: Flags{}, out: rotated},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			tc.flags.inputFile = in
+			tc.flags.outputPublic = cmp.Or(tc.out, out)
+			tc.flags.ipv4 = true
+			tc.flags.sort = string(sortNumeric)
+			tc.flags.format = cmp.Or(tc.flags.format, string(formatList))
+			tc.flags.sharedThreshold = 1
+			tc.flags.shards = 1
+			tc.flags.samples = 1
+			tc.flags.workers = 1
+			tc.flags.backend = string(backendSystem)
+			tc.flags.mode = string(modeResolve)
+			tc.flags.ipv6Format = string(ipv6Canonical)
+			tc.flags.input = string(inputText)
+			tc.flags.ipSep = " "
+			tc.flags.logFormat = logFormatText
+			tc.flags.logLevel = "info"
+			err := tc.flags.Validate()
+			if tc.wantErr && err == nil {
+				t.Error("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFlagsValidate_stdoutOutput(t *testing.T) {
+	in := filepath.Join(t.TempDir(), "in.txt")
+	if err := os.WriteFile(in, nil, 0o644); err != nil {
+		t.Fatalf("failed to create %q: %v", in, err)
+	}
+
+	tt := map[string]struct {
+		flags   Flags
+		wantErr bool
+	}{
+		"public":        {flags: Flags{outputPublic: "-", shards: 1}},
+		"two classes":   {flags: Flags{outputPublic: "-", outputPrivate: "-", shards: 1}, wantErr: true},
+		"append":        {flags: Flags{outputPublic: "-", shards: 1, append: true}, wantErr: true},
+		"shards":        {flags: Flags{outputPublic: "-", shards: 2}, wantErr: true},
+		"max file size": {flags: Flags{outputPublic: "-", shards: 1, maxFileSize: "1MB"}, wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			tc.flags.inputFile = in
+			tc.flags.ipv4 = true
+			tc.flags.sort = string(sortNumeric)
+			tc.flags.format = string(formatList)
+			tc.flags.sharedThreshold = 1
+			tc.flags.samples = 1
+			tc.flags.workers = 1
+			tc.flags.backend = string(backendSystem)
+			tc.flags.mode = string(modeResolve)
+			tc.flags.ipv6Format = string(ipv6Canonical)
+			tc.flags.input = string(inputText)
+			tc.flags.ipSep = " "
+			tc.flags.logFormat = logFormatText
+			tc.flags.logLevel = "info"
+			err := tc.flags.Validate()
+			if tc.wantErr && err == nil {
+				t.Error("expected error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestFragmentSnapshot(t *testing.T) {
+	partial := filepath.Join(t.TempDir(), "public.txt.partial")
+	frag := fragment{
+		m: addrMap(map[string][]string{
+			"1.1.1.1": {"example.com"},
+		}),
+		partial: partial,
+	}
+
+	if err := frag.snapshot(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(partial)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "1.1.1.1 example.com"; string(got) != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	if err := frag.removePartial(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := os.Stat(partial); !os.IsNotExist(err) {
+		t.Errorf("expected partial file to be removed, got %v", err)
+	}
+}
+
+func TestFragmentWrite_hostsFormat(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{
+		m:      make(map[netip.Addr][]string),
+		format: formatHosts,
+	}
+	frag.out = frag.writerSink(out)
+	if err := frag.load(strings.NewReader("# pinned origins\n1.1.1.1 a.example.com b.example.com")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	frag.append(netip.MustParseAddr("1.1.1.1"), "c.example.com")
+
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "1.1.1.1 a.example.com b.example.com c.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+// addrMap keys m by parsed ip address, as fragments are.
+func addrMap(m map[string][]string) map[netip.Addr][]string {
+	addrs := make(map[netip.Addr][]string, len(m))
+	for ip, subdomains := range m {
+		addrs[netip.MustParseAddr(ip)] = subdomains
+	}
+	return addrs
+}
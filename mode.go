@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+type lookupMode string
+
+const (
+	modeResolve  lookupMode = "resolve"
+	modeReverse  lookupMode = "reverse"
+	modeClassify lookupMode = "classify"
+)
+
+func parseLookupMode(s string) (lookupMode, error) {
+	switch mode := lookupMode(s); mode {
+	case modeResolve, modeReverse, modeClassify:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q", s)
+	}
+}
+
+func (m *ipSubMap) classifyLine(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	subdomain, ips := fields[0], fields[1:]
+	if len(ips) == 0 {
+		ips = fields
+	} else if !m.allowsHost(subdomain, "") {
+		return nil
+	}
+
+	for _, s := range ips {
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			return fmt.Errorf("invalid ip address %q in line %q", s, line)
+		}
+		if ip = ip.Unmap(); !m.wantsAddr(ip) {
+			continue
+		}
+		m.record(subdomain, ip)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestIPSubMapClassifyLine(t *testing.T) {
+	m := &ipSubMap{
+		ipv4:     true,
+		mode:     modeClassify,
+		private:  fragment{m: make(map[netip.Addr][]string)},
+		public:   fragment{m: make(map[netip.Addr][]string)},
+		loopback: fragment{m: make(map[netip.Addr][]string)},
+	}
+
+	for _, line := range []string{
+		"db.example.com 10.0.0.1",
+		"www.example.com 93.184.216.34 2606:2800:220:1::1",
+		"127.0.0.1",
+		"",
+	} {
+		if err := m.lookup(line); err != nil {
+			t.Fatalf("unexpected error for %q: %v", line, err)
+		}
+	}
+
+	want := map[*fragment]map[string][]string{
+		&m.private:  {"10.0.0.1": {"db.example.com"}},
+		&m.public:   {"93.184.216.34": {"www.example.com"}},
+		&m.loopback: {"127.0.0.1": {"127.0.0.1"}},
+	}
+	for frag, entries := range want {
+		if len(frag.m) != len(entries) {
+			t.Errorf("expected %v, got %v", entries, frag.m)
+		}
+		for ip, subdomains := range entries {
+			if got := frag.m[netip.MustParseAddr(ip)]; !slices.Equal(got, subdomains) {
+				t.Errorf("%s: expected %q, got %q", ip, subdomains, got)
+			}
+		}
+	}
+
+	if err := m.lookup("www.example.com not-an-ip"); err == nil {
+		t.Error("expected error for invalid ip address")
+	}
+}
+
+func TestParseLookupMode(t *testing.T) {
+	for _, s := range []string{"resolve", "reverse", "classify"} {
+		if _, err := parseLookupMode(s); err != nil {
+			t.Errorf("unexpected error for %q: %v", s, err)
+		}
+	}
+	if _, err := parseLookupMode("massdns"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
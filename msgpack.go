@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// msgpackEncoder writes one MessagePack map per subdomain and ip address,
+// with the fields of the JSON record schema.
+type msgpackEncoder struct {
+	class string
+	seen  map[string]time.Time
+	now   time.Time
+}
+
+func (e msgpackEncoder) encode(b []byte, r record) ([]byte, error) {
+	for _, subdomain := range r.values {
+		b = append(b, 0x85)
+		b = appendMsgpackString(b, "schema_version")
+		b = append(b, schemaVersion)
+		b = appendMsgpackString(b, "subdomain")
+		b = appendMsgpackString(b, subdomain)
+		b = appendMsgpackString(b, "ip")
+		b = appendMsgpackString(b, r.key)
+		b = appendMsgpackString(b, "class")
+		b = appendMsgpackString(b, e.class)
+		b = appendMsgpackString(b, "timestamp")
+		b = appendMsgpackString(b, formatSeen(seenAt(e.seen, subdomain, e.now)))
+	}
+	return b, nil
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= 0xff:
+		b = append(b, 0xd9, byte(n))
+	case n <= 0xffff:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
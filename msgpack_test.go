@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readMsgpack decodes maps of strings and positive fixints written by
+// msgpackEncoder.
+func readMsgpack(t *testing.T, b []byte) []map[string]any {
+	t.Helper()
+	value := func() any {
+		switch c := b[0]; {
+		case c < 0x80:
+			b = b[1:]
+			return int(c)
+		case c&0xe0 == 0xa0:
+			n := int(c & 0x1f)
+			s := string(b[1 : 1+n])
+			b = b[1+n:]
+			return s
+		case c == 0xd9:
+			n := int(b[1])
+			s := string(b[2 : 2+n])
+			b = b[2+n:]
+			return s
+		case c == 0xda:
+			n := int(binary.BigEndian.Uint16(b[1:]))
+			s := string(b[3 : 3+n])
+			b = b[3+n:]
+			return s
+		}
+		t.Fatalf("unexpected msgpack type %#x", b[0])
+		return nil
+	}
+
+	var maps []map[string]any
+	for len(b) > 0 {
+		if b[0]&0xf0 != 0x80 {
+			t.Fatalf("expected fixmap, got %#x", b[0])
+		}
+		n := int(b[0] & 0x0f)
+		b = b[1:]
+		m := make(map[string]any)
+		for range n {
+			k := value().(string)
+			m[k] = value()
+		}
+		maps = append(maps, m)
+	}
+	return maps
+}
+
+func TestFragmentWrite_msgpack(t *testing.T) {
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	long := strings.Repeat("a", 300) + ".example.com"
+	out := &bytes.Buffer{}
+	frag := fragment{
+		class:  classPublic,
+		format: formatMsgpack,
+		seen:   map[string]time.Time{"a.example.com": seen, long: seen},
+		m: addrMap(map[string][]string{
+			"1.1.1.1": {"a.example.com", long},
+		}),
+	}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []map[string]any{
+		{"schema_version": schemaVersion, "subdomain": "a.example.com", "ip": "1.1.1.1", "class": classPublic, "timestamp": "2024-05-01T12:00:00Z"},
+		{"schema_version": schemaVersion, "subdomain": long, "ip": "1.1.1.1", "class": classPublic, "timestamp": "2024-05-01T12:00:00Z"},
+	}
+	if got := readMsgpack(t, out.Bytes()); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
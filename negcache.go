@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// negativeCache remembers names that do not exist across runs, so that they
+// are not queried again until their entry expires. When soaTTL knows the
+// negative TTL of a name's zone, entries expire no later than it allows.
+type negativeCache struct {
+	ttl    time.Duration
+	now    func() time.Time
+	next   func(host string) ([]net.IP, error)
+	soaTTL func(host string) (time.Duration, bool)
+
+	mu      sync.Mutex
+	expires map[string]time.Time
+	hits    int
+}
+
+func newNegativeCache(ttl time.Duration, next func(host string) ([]net.IP, error)) *negativeCache {
+	return &negativeCache{
+		ttl:     ttl,
+		now:     time.Now,
+		next:    next,
+		expires: make(map[string]time.Time),
+	}
+}
+
+// load reads "<name> <unix expiry>" lines, skipping expired entries.
+func (c *negativeCache) load(in io.Reader) error {
+	now := c.now()
+	return scanLines(in, func(n int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+		expiry, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+		if t := time.Unix(expiry, 0); t.After(now) {
+			c.expires[fields[0]] = t
+		}
+		return nil
+	})
+}
+
+func (c *negativeCache) loadFile(path string) error {
+	in, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return c.load(in)
+}
+
+func (c *negativeCache) write(out io.Writer) error {
+	now := c.now()
+	names := make([]string, 0, len(c.expires))
+	for name, expiry := range c.expires {
+		if expiry.After(now) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	w := bufio.NewWriter(out)
+	for _, name := range names {
+		fmt.Fprintf(w, "%s %d\n", name, c.expires[name].Unix())
+	}
+	return w.Flush()
+}
+
+// lookupIP answers names cached as not existing without a query, and caches
+// the names the next lookup reports as not existing.
+func (c *negativeCache) lookupIP(host string) ([]net.IP, error) {
+	c.mu.Lock()
+	expiry, ok := c.expires[host]
+	if ok && expiry.After(c.now()) {
+		c.hits++
+		c.mu.Unlock()
+		return nil, &net.DNSError{Err: "no such host (cached)", Name: host, IsNotFound: true}
+	}
+	c.mu.Unlock()
+
+	ips, err := c.next(host)
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		ttl := c.ttl
+		if c.soaTTL != nil {
+			if soa, ok := c.soaTTL(host); ok {
+				ttl = min(ttl, soa)
+			}
+		}
+		if ttl > 0 {
+			c.mu.Lock()
+			c.expires[host] = c.now().Add(ttl)
+			c.mu.Unlock()
+		}
+	}
+	return ips, err
+}
+
+func saveNegativeCache(path string, c *negativeCache) error {
+	out, err := createAtomic(path)
+	if err != nil {
+		return err
+	}
+	if err := c.write(out); err != nil {
+		out.Abort()
+		return err
+	}
+	return out.Commit()
+}
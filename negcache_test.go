@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegativeCacheLookupIP(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	queried := make(map[string]int)
+	c := newNegativeCache(time.Hour, func(host string) ([]net.IP, error) {
+		queried[host]++
+		switch host {
+		case "www.example.com":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case "flaky.example.com":
+			return nil, &net.DNSError{Err: "i/o timeout", Name: host, IsTimeout: true}
+		}
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	})
+	c.now = func() time.Time { return now }
+	if err := c.load(strings.NewReader("cached.example.com 1700000100\nexpired.example.com 1699999999\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for range 2 {
+		for _, host := range []string{"www.example.com", "flaky.example.com", "missing.example.com", "cached.example.com", "expired.example.com"} {
+			_, err := c.lookupIP(host)
+			var dnsErr *net.DNSError
+			if host != "www.example.com" && !errors.As(err, &dnsErr) {
+				t.Errorf("%s: expected a dns error, got %v", host, err)
+			}
+		}
+	}
+
+	want := map[string]int{"www.example.com": 2, "flaky.example.com": 2, "missing.example.com": 1, "expired.example.com": 1}
+	for host, n := range want {
+		if queried[host] != n {
+			t.Errorf("%s: expected %d queries, got %d", host, n, queried[host])
+		}
+	}
+	if queried["cached.example.com"] != 0 {
+		t.Errorf("expected cached name not to be queried, got %d", queried["cached.example.com"])
+	}
+	if c.hits != 4 {
+		t.Errorf("expected 4 cache hits, got %d", c.hits)
+	}
+
+	now = now.Add(30 * time.Minute)
+	out := &bytes.Buffer{}
+	if err := c.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantOut := "expired.example.com 1700003600\nmissing.example.com 1700003600\n"
+	if out.String() != wantOut {
+		t.Errorf("expected %q, got %q", wantOut, out.String())
+	}
+}
+
+func TestNegativeCacheLookupIP_soaTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	c := newNegativeCache(time.Hour, func(host string) ([]net.IP, error) {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	})
+	c.now = func() time.Time { return now }
+	c.soaTTL = func(host string) (time.Duration, bool) {
+		switch host {
+		case "short.example.com":
+			return time.Minute, true
+		case "zero.example.com":
+			return 0, true
+		case "long.example.com":
+			return 24 * time.Hour, true
+		}
+		return 0, false
+	}
+
+	for _, host := range []string{"short.example.com", "zero.example.com", "long.example.com", "unknown.example.com"} {
+		c.lookupIP(host)
+	}
+
+	want := map[string]time.Time{
+		"short.example.com":   now.Add(time.Minute),
+		"long.example.com":    now.Add(time.Hour),
+		"unknown.example.com": now.Add(time.Hour),
+	}
+	if len(c.expires) != len(want) {
+		t.Errorf("expected %d entries, got %v", len(want), c.expires)
+	}
+	for host, expiry := range want {
+		if !c.expires[host].Equal(expiry) {
+			t.Errorf("%s: expected expiry %v, got %v", host, expiry, c.expires[host])
+		}
+	}
+}
+
+func TestNegativeCacheLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "negative.txt")
+	c := newNegativeCache(time.Hour, nil)
+	if err := c.loadFile(path); err != nil {
+		t.Fatalf("expected a missing cache to be empty, got %v", err)
+	}
+
+	c.expires["missing.example.com"] = time.Now().Add(time.Hour)
+	if err := saveNegativeCache(path, c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	loaded := newNegativeCache(time.Hour, nil)
+	if err := loaded.loadFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := loaded.expires["missing.example.com"]; !ok {
+		t.Errorf("expected the saved entry to be loaded, got %v", loaded.expires)
+	}
+
+	if err := loaded.load(strings.NewReader("missing.example.com soon\n")); err == nil {
+		t.Error("expected error for malformed line")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+type nmapReport struct {
+	hosts     map[netip.Addr][]string
+	aggregate bool
+	mapOut    io.Writer
+}
+
+func newNmapReport(aggregate bool) *nmapReport {
+	return &nmapReport{
+		hosts:     make(map[netip.Addr][]string),
+		aggregate: aggregate,
+	}
+}
+
+func nmapMapPath(path string) string {
+	if path == "" {
+		return ""
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".map" + ext
+}
+
+func (r *nmapReport) add(_ string, ip string, subdomain string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	if !slices.Contains(r.hosts[addr], subdomain) {
+		r.hosts[addr] = append(r.hosts[addr], subdomain)
+	}
+}
+
+func (r *nmapReport) write(out io.Writer) error {
+	addrs := make([]netip.Addr, 0, len(r.hosts))
+	for addr := range r.hosts {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+
+	w := bufio.NewWriter(out)
+	if r.aggregate {
+		for _, prefix := range aggregatePrefixes(addrs) {
+			if prefix.IsSingleIP() {
+				fmt.Fprintln(w, prefix.Addr())
+			} else {
+				fmt.Fprintln(w, prefix)
+			}
+		}
+	} else {
+		for _, addr := range addrs {
+			fmt.Fprintln(w, addr)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if r.mapOut == nil {
+		return nil
+	}
+
+	w = bufio.NewWriter(r.mapOut)
+	for _, addr := range addrs {
+		subdomains := slices.Clone(r.hosts[addr])
+		slices.Sort(subdomains)
+		fmt.Fprintf(w, "%s %s\n", addr, strings.Join(subdomains, ","))
+	}
+	return w.Flush()
+}
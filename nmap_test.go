@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNmapReport(t *testing.T) {
+	tt := map[string]struct {
+		aggregate bool
+		want      string
+	}{
+		"plain":     {want: "10.0.0.0\n10.0.0.1\n192.0.2.7\n"},
+		"aggregate": {aggregate: true, want: "10.0.0.0/31\n192.0.2.7\n"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			r := newNmapReport(tc.aggregate)
+			mapOut := &bytes.Buffer{}
+			r.mapOut = mapOut
+			r.add(classPublic, "192.0.2.7", "b.example.com")
+			r.add(classPrivate, "10.0.0.1", "c.example.com")
+			r.add(classPrivate, "::ffff:10.0.0.0", "d.example.com")
+			r.add(classPublic, "192.0.2.7", "a.example.com")
+
+			out := &bytes.Buffer{}
+			if err := r.write(out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+
+			wantMap := "10.0.0.0 d.example.com\n10.0.0.1 c.example.com\n192.0.2.7 a.example.com,b.example.com\n"
+			if got := mapOut.String(); got != wantMap {
+				t.Errorf("expected map %q, got %q", wantMap, got)
+			}
+		})
+	}
+}
+
+func TestNmapMapPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"targets.txt":      "targets.map.txt",
+		"out/targets":      "out/targets.map",
+		"":                 "",
+		"dir.v2/targets.l": "dir.v2/targets.map.l",
+	} {
+		if got := nmapMapPath(path); got != want {
+			t.Errorf("nmapMapPath(%q): expected %q, got %q", path, want, got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	notifyNewLimit      = 10
+	discordContentLimit = 2000
+)
+
+func notificationText(input string, v summaryView) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ipsubmap run finished for `%s`\n", input)
+
+	for _, c := range v.Classes {
+		fmt.Fprintf(&b, "• %s: %d ips", c.Name, c.IPs)
+		if v.HasBaseline {
+			fmt.Fprintf(&b, " (%+d)", c.IPs-c.PreviousIPs)
+		}
+		fmt.Fprintf(&b, ", %d subdomains\n", c.Subdomains)
+	}
+
+	if v.ErrorsTotal > 0 {
+		fmt.Fprintf(&b, "Lookup errors: %d\n", v.ErrorsTotal)
+	}
+
+	if len(v.New) > 0 {
+		fmt.Fprintf(&b, "New results:\n")
+		for i, row := range v.New {
+			if i == notifyNewLimit {
+				fmt.Fprintf(&b, "… and %d more\n", len(v.New)-notifyNewLimit)
+				break
+			}
+			fmt.Fprintf(&b, "• %s `%s` %s\n", row.Class, row.IP, strings.Join(row.Subdomains, ", "))
+		}
+	}
+	if len(v.Gone) > 0 {
+		fmt.Fprintf(&b, "Gone: %d ips\n", len(v.Gone))
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+type discordMessage struct {
+	Content string `json:"content"`
+}
+
+func notifySlack(url string, text string) error {
+	return postJSON(url, slackMessage{Text: text})
+}
+
+func notifyDiscord(url string, text string) error {
+	if r := []rune(text); len(r) > discordContentLimit {
+		text = string(r[:discordContentLimit-1]) + "…"
+	}
+	return postJSON(url, discordMessage{Content: text})
+}
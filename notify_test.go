@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNotificationText(t *testing.T) {
+	r := newSummaryReport("", sortNumeric)
+	r.baseline = map[[2]string]struct{}{{"1.1.1.1", "a.example.com"}: {}}
+	r.previous = map[string]int{classPublic: 1}
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	r.add(classPublic, "2.2.2.2", "b.example.com")
+	r.add(classPrivate, "10.0.0.1", "dev.example.com")
+
+	want := "ipsubmap run finished for `subdomains.txt`\n" +
+		"• private: 1 ips (+1), 1 subdomains\n" +
+		"• public: 2 ips (+1), 2 subdomains\n" +
+		"• loopback: 0 ips (+0), 0 subdomains\n" +
+		"New results:\n" +
+		"• private `10.0.0.1` dev.example.com\n" +
+		"• public `2.2.2.2` b.example.com"
+	if got := notificationText("subdomains.txt", r.view()); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var got map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	tt := map[string]struct {
+		notify func(url, text string) error
+		text   string
+		field  string
+		want   int
+	}{
+		"slack":             {notify: notifySlack, text: "hello", field: "text", want: 5},
+		"discord":           {notify: notifyDiscord, text: "hello", field: "content", want: 5},
+		"discord truncated": {notify: notifyDiscord, text: strings.Repeat("a", 3000), field: "content", want: discordContentLimit},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got = nil
+			if err := tc.notify(srv.URL, tc.text); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if n := len([]rune(got[tc.field])); n != tc.want {
+				t.Errorf("expected %s of %d characters, got %d", tc.field, tc.want, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+type sortMode string
+
+const (
+	sortLexical   sortMode = "lexical"
+	sortNumeric   sortMode = "numeric"
+	sortSubdomain sortMode = "subdomain"
+)
+
+func parseSortMode(s string) (sortMode, error) {
+	switch mode := sortMode(s); mode {
+	case sortLexical, sortNumeric, sortSubdomain:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown sort mode %q", s)
+	}
+}
+
+// sortKey returns a string whose lexical order matches the requested order of
+// ip, so that records can be sorted as plain strings by the external sorter.
+func sortKey(ip string, mode sortMode) string {
+	if mode != sortNumeric {
+		return ip
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "z" + ip
+	}
+	if addr.Is4() {
+		b := addr.As4()
+		return "4" + hex.EncodeToString(b[:])
+	}
+	b := addr.As16()
+	return "6" + hex.EncodeToString(b[:]) + addr.Zone()
+}
+
+func sortIPs(keys []string, mode sortMode) {
+	if mode != sortNumeric {
+		slices.Sort(keys)
+		return
+	}
+
+	type entry struct {
+		key  string
+		addr netip.Addr
+		ok   bool
+	}
+	entries := make([]entry, len(keys))
+	for i, k := range keys {
+		addr, err := netip.ParseAddr(k)
+		entries[i] = entry{key: k, addr: addr, ok: err == nil}
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		switch {
+		case a.ok && b.ok:
+			if c := a.addr.Compare(b.addr); c != 0 {
+				return c
+			}
+			return strings.Compare(a.key, b.key)
+		case a.ok:
+			return -1
+		case b.ok:
+			return 1
+		default:
+			return strings.Compare(a.key, b.key)
+		}
+	})
+
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+}
+
+func invert(m map[string][]string) map[string][]string {
+	inverted := make(map[string][]string)
+	for ip, subdomains := range m {
+		for _, subdomain := range subdomains {
+			inverted[subdomain] = append(inverted[subdomain], ip)
+		}
+	}
+
+	for _, ips := range inverted {
+		sortIPs(ips, sortNumeric)
+	}
+
+	return inverted
+}
+
+// invertAddrs returns the ip addresses of every subdomain in m, in numeric
+// order.
+func invertAddrs(m map[netip.Addr][]string) map[string][]string {
+	addrs := make(map[string][]netip.Addr)
+	for addr, subdomains := range m {
+		for _, subdomain := range subdomains {
+			addrs[subdomain] = append(addrs[subdomain], addr)
+		}
+	}
+
+	inverted := make(map[string][]string, len(addrs))
+	for subdomain, list := range addrs {
+		slices.SortFunc(list, netip.Addr.Compare)
+		ips := make([]string, len(list))
+		for i, addr := range list {
+			ips[i] = formatIP(addr)
+		}
+		inverted[subdomain] = ips
+	}
+	return inverted
+}
+
+func ipSortMode(mode sortMode) sortMode {
+	if mode == sortLexical {
+		return sortLexical
+	}
+	return sortNumeric
+}
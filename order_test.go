@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSortIPs(t *testing.T) {
+	tt := map[string]struct {
+		mode sortMode
+		want []string
+	}{
+		"lexical": {
+			mode: sortLexical,
+			want: []string{"10.0.0.1", "2.2.2.2", "2001:db8::1", "9.9.9.9", "::1", "invalid"},
+		},
+		"numeric": {
+			mode: sortNumeric,
+			want: []string{"2.2.2.2", "9.9.9.9", "10.0.0.1", "::1", "2001:db8::1", "invalid"},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			keys := []string{"9.9.9.9", "invalid", "2001:db8::1", "10.0.0.1", "::1", "2.2.2.2"}
+			sortIPs(keys, tc.mode)
+			if !slices.Equal(keys, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, keys)
+			}
+
+			keyed := []string{"9.9.9.9", "invalid", "2001:db8::1", "10.0.0.1", "::1", "2.2.2.2"}
+			slices.SortFunc(keyed, func(a, b string) int {
+				return strings.Compare(sortKey(a, tc.mode), sortKey(b, tc.mode))
+			})
+			if !slices.Equal(keyed, tc.want) {
+				t.Errorf("expected sort keys to order as %v, got %v", tc.want, keyed)
+			}
+		})
+	}
+}
+
+func TestFragmentWrite_sortSubdomain(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{
+		m: addrMap(map[string][]string{
+			"10.0.0.1": {"b.example.com"},
+			"2.2.2.2":  {"a.example.com", "b.example.com"},
+		}),
+		sortMode: sortSubdomain,
+	}
+	frag.out = frag.writerSink(out)
+
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "a.example.com 2.2.2.2\nb.example.com 2.2.2.2,10.0.0.1"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestFragmentWrite_numericOrder(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{
+		m: addrMap(map[string][]string{
+			"2001:db8::1": {"c.example.com"},
+			"10.0.0.10":   {"b.example.com"},
+			"10.0.0.9":    {"a.example.com"},
+			"::1":         {"d.example.com"},
+		}),
+		sortMode: sortNumeric,
+	}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "10.0.0.9 a.example.com\n10.0.0.10 b.example.com\n::1 d.example.com\n2001:db8::1 c.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestInvertAddrs(t *testing.T) {
+	got := invertAddrs(addrMap(map[string][]string{
+		"10.0.0.10":   {"a.example.com"},
+		"2001:db8::1": {"a.example.com", "b.example.com"},
+		"10.0.0.9":    {"a.example.com"},
+	}))
+
+	want := map[string][]string{
+		"a.example.com": {"10.0.0.9", "10.0.0.10", "2001:db8::1"},
+		"b.example.com": {"2001:db8::1"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	for subdomain, ips := range want {
+		if !slices.Equal(got[subdomain], ips) {
+			t.Errorf("expected %s to have %v, got %v", subdomain, ips, got[subdomain])
+		}
+	}
+}
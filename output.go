@@ -0,0 +1,429 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	classPrivate  = "private"
+	classPublic   = "public"
+	classLoopback = "loopback"
+)
+
+type report interface {
+	add(class string, ip string, subdomain string)
+	write(out io.Writer) error
+}
+
+type loader interface {
+	load(in io.Reader) error
+}
+
+type typedReport interface {
+	contentType() string
+}
+
+type reportOutput struct {
+	name string
+	out  io.Writer
+	// sink reports deliver results to a remote service instead of a local
+	// file. Their failures do not abort the run.
+	sink bool
+	report
+}
+
+func openReport(path string, appendMode bool, r report) (*atomicFile, error) {
+	if l, ok := r.(loader); ok && appendMode {
+		in, err := os.Open(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, err
+		default:
+			err := l.load(in)
+			in.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to load existing output %q: %v", path, err)
+			}
+		}
+	}
+
+	out, err := createAtomic(path)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := r.(typedReport); ok {
+		out.contentType = t.contentType()
+	}
+	return out, nil
+}
+
+type hostReport struct {
+	hosts map[string]*hostEntry
+}
+
+type hostEntry struct {
+	ips     []string
+	classes []string
+}
+
+func newHostReport() *hostReport {
+	return &hostReport{hosts: make(map[string]*hostEntry)}
+}
+
+func (r *hostReport) add(class string, ip string, subdomain string) {
+	entry, ok := r.hosts[subdomain]
+	if !ok {
+		entry = &hostEntry{}
+		r.hosts[subdomain] = entry
+	}
+	if !slices.Contains(entry.ips, ip) {
+		entry.ips = append(entry.ips, ip)
+	}
+	if !slices.Contains(entry.classes, class) {
+		entry.classes = append(entry.classes, class)
+	}
+}
+
+func (r *hostReport) write(out io.Writer) error {
+	hosts := make([]string, 0, len(r.hosts))
+	for host := range r.hosts {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+
+	for i, host := range hosts {
+		entry := r.hosts[host]
+		sortIPs(entry.ips, sortNumeric)
+		slices.Sort(entry.classes)
+
+		sep := "\n"
+		if i == 0 {
+			sep = ""
+		}
+		output := fmt.Sprintf("%s%s %s %s", sep, host, strings.Join(entry.ips, ","), strings.Join(entry.classes, ","))
+		if _, err := out.Write([]byte(output)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *hostReport) load(in io.Reader) error {
+	return scanLines(in, func(n int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+
+		for _, ip := range strings.Split(fields[1], ",") {
+			for _, class := range strings.Split(fields[2], ",") {
+				r.add(class, canonicalIP(ip), fields[0])
+			}
+		}
+		return nil
+	})
+}
+
+type combinedReport struct {
+	ips      map[string]*combinedEntry
+	sortMode sortMode
+}
+
+type combinedEntry struct {
+	class      string
+	subdomains []string
+}
+
+func newCombinedReport(mode sortMode) *combinedReport {
+	return &combinedReport{
+		ips:      make(map[string]*combinedEntry),
+		sortMode: ipSortMode(mode),
+	}
+}
+
+func (r *combinedReport) add(class string, ip string, subdomain string) {
+	entry, ok := r.ips[ip]
+	if !ok {
+		entry = &combinedEntry{class: class}
+		r.ips[ip] = entry
+	}
+	if !slices.Contains(entry.subdomains, subdomain) {
+		entry.subdomains = append(entry.subdomains, subdomain)
+	}
+}
+
+func (r *combinedReport) write(out io.Writer) error {
+	ips := make([]string, 0, len(r.ips))
+	for ip := range r.ips {
+		ips = append(ips, ip)
+	}
+	sortIPs(ips, r.sortMode)
+
+	for i, ip := range ips {
+		entry := r.ips[ip]
+		sep := "\n"
+		if i == 0 {
+			sep = ""
+		}
+		output := fmt.Sprintf("%s%s %s %s", sep, ip, entry.class, strings.Join(entry.subdomains, ","))
+		if _, err := out.Write([]byte(output)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *combinedReport) load(in io.Reader) error {
+	return scanLines(in, func(n int, line string) error {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+
+		for _, subdomain := range strings.Split(fields[2], ",") {
+			r.add(fields[1], canonicalIP(fields[0]), subdomain)
+		}
+		return nil
+	})
+}
+
+var classes = []string{classPrivate, classPublic, classLoopback}
+
+func parseClasses(s string) ([]string, error) {
+	var parsed []string
+	for _, class := range strings.Split(s, ",") {
+		class = strings.TrimSpace(class)
+		if !slices.Contains(classes, class) {
+			return nil, fmt.Errorf("unknown class %q", class)
+		}
+		if !slices.Contains(parsed, class) {
+			parsed = append(parsed, class)
+		}
+	}
+	return parsed, nil
+}
+
+type ipReport struct {
+	ips      map[string]map[string]struct{}
+	sortMode sortMode
+}
+
+func newIPReport(mode sortMode) *ipReport {
+	return &ipReport{
+		ips:      make(map[string]map[string]struct{}),
+		sortMode: ipSortMode(mode),
+	}
+}
+
+func (r *ipReport) add(class string, ip string, _ string) {
+	if r.ips[class] == nil {
+		r.ips[class] = make(map[string]struct{})
+	}
+	r.ips[class][ip] = struct{}{}
+}
+
+func (r *ipReport) write(out io.Writer) error {
+	first := true
+	for _, class := range classes {
+		ips := make([]string, 0, len(r.ips[class]))
+		for ip := range r.ips[class] {
+			ips = append(ips, ip)
+		}
+		sortIPs(ips, r.sortMode)
+
+		for _, ip := range ips {
+			sep := "\n"
+			if first {
+				sep = ""
+			}
+			first = false
+			if _, err := out.Write([]byte(sep + ip)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *ipReport) load(in io.Reader) error {
+	return scanLines(in, func(n int, line string) error {
+		ip, err := netip.ParseAddr(line)
+		if err != nil {
+			return fmt.Errorf("malformed line %d: %q", n, line)
+		}
+		r.add(classify(ip.Unmap()), canonicalIP(line), "")
+		return nil
+	})
+}
+
+type failureReport interface {
+	fail(subdomain string, err error)
+}
+
+type unresolvedReport struct {
+	names map[string]struct{}
+}
+
+func newUnresolvedReport() *unresolvedReport {
+	return &unresolvedReport{names: make(map[string]struct{})}
+}
+
+func (r *unresolvedReport) add(_ string, _ string, subdomain string) {
+	delete(r.names, subdomain)
+}
+
+func (r *unresolvedReport) fail(subdomain string, _ error) {
+	r.names[subdomain] = struct{}{}
+}
+
+func (r *unresolvedReport) write(out io.Writer) error {
+	names := make([]string, 0, len(r.names))
+	for name := range r.names {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	_, err := out.Write([]byte(strings.Join(names, "\n")))
+	return err
+}
+
+func (r *unresolvedReport) load(in io.Reader) error {
+	return scanLines(in, func(_ int, line string) error {
+		r.names[line] = struct{}{}
+		return nil
+	})
+}
+
+type exclusionReport interface {
+	exclude(subdomain string, ip string, reason string)
+}
+
+type outOfScopeReport struct {
+	lines []string
+}
+
+func newOutOfScopeReport() *outOfScopeReport {
+	return &outOfScopeReport{}
+}
+
+func (r *outOfScopeReport) add(string, string, string) {}
+
+func (r *outOfScopeReport) exclude(subdomain string, ip string, reason string) {
+	if ip == "" {
+		ip = "-"
+	}
+	r.lines = append(r.lines, fmt.Sprintf("%s %s %s", subdomain, ip, reason))
+}
+
+func (r *outOfScopeReport) write(out io.Writer) error {
+	_, err := out.Write([]byte(strings.Join(r.lines, "\n")))
+	return err
+}
+
+func (r *outOfScopeReport) load(in io.Reader) error {
+	return scanLines(in, func(_ int, line string) error {
+		r.lines = append(r.lines, line)
+		return nil
+	})
+}
+
+type urlReport struct {
+	hosts map[string][]string
+	perIP bool
+}
+
+func newURLReport(perIP bool) *urlReport {
+	return &urlReport{hosts: make(map[string][]string), perIP: perIP}
+}
+
+func (r *urlReport) add(_ string, ip string, subdomain string) {
+	if !slices.Contains(r.hosts[subdomain], ip) {
+		r.hosts[subdomain] = append(r.hosts[subdomain], ip)
+	}
+}
+
+func (r *urlReport) write(out io.Writer) error {
+	hosts := make([]string, 0, len(r.hosts))
+	for host := range r.hosts {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+
+	var lines []string
+	for _, host := range hosts {
+		if !r.perIP {
+			lines = append(lines, "https://"+host)
+			continue
+		}
+
+		ips := r.hosts[host]
+		sortIPs(ips, sortNumeric)
+		for _, ip := range ips {
+			lines = append(lines, fmt.Sprintf("https://%s\tHost: %s", urlHost(ip), host))
+		}
+	}
+
+	_, err := out.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
+
+func urlHost(ip string) string {
+	if strings.Contains(ip, ":") {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+type sharedReport struct {
+	ips       map[string]*combinedEntry
+	threshold int
+}
+
+func newSharedReport(threshold int) *sharedReport {
+	return &sharedReport{ips: make(map[string]*combinedEntry), threshold: threshold}
+}
+
+func (r *sharedReport) add(class string, ip string, subdomain string) {
+	entry, ok := r.ips[ip]
+	if !ok {
+		entry = &combinedEntry{class: class}
+		r.ips[ip] = entry
+	}
+	if !slices.Contains(entry.subdomains, subdomain) {
+		entry.subdomains = append(entry.subdomains, subdomain)
+	}
+}
+
+func (r *sharedReport) write(out io.Writer) error {
+	var ips []string
+	for ip, entry := range r.ips {
+		if len(entry.subdomains) >= r.threshold {
+			ips = append(ips, ip)
+		}
+	}
+	sortIPs(ips, sortNumeric)
+	slices.SortStableFunc(ips, func(a, b string) int {
+		return len(r.ips[b].subdomains) - len(r.ips[a].subdomains)
+	})
+
+	lines := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		entry := r.ips[ip]
+		subdomains := slices.Clone(entry.subdomains)
+		slices.Sort(subdomains)
+		lines = append(lines, fmt.Sprintf("%d %s %s %s", len(subdomains), ip, entry.class, strings.Join(subdomains, ",")))
+	}
+
+	_, err := out.Write([]byte(strings.Join(lines, "\n")))
+	return err
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestHostReport(t *testing.T) {
+	r := newHostReport()
+	if err := r.load(strings.NewReader("b.example.com 10.0.0.1 private")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.add(classPublic, "2.2.2.2", "b.example.com")
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "a.example.com 1.1.1.1 public\nb.example.com 2.2.2.2,10.0.0.1 private,public"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestCombinedReport(t *testing.T) {
+	r := newCombinedReport(sortNumeric)
+	if err := r.load(strings.NewReader("10.0.0.1 private b.example.com")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.add(classPublic, "2.2.2.2", "a.example.com")
+	r.add(classPublic, "2.2.2.2", "b.example.com")
+	r.add(classPrivate, "10.0.0.1", "b.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "2.2.2.2 public a.example.com,b.example.com\n10.0.0.1 private b.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestIPReport(t *testing.T) {
+	r := newIPReport(sortNumeric)
+	if err := r.load(strings.NewReader("127.0.0.1\n10.0.0.1")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.add(classPublic, "9.9.9.9", "a.example.com")
+	r.add(classPublic, "1.1.1.1", "b.example.com")
+	r.add(classPublic, "9.9.9.9", "c.example.com")
+	r.add(classPrivate, "10.0.0.1", "d.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "10.0.0.1\n1.1.1.1\n9.9.9.9\n127.0.0.1"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestUnresolvedReport(t *testing.T) {
+	r := newUnresolvedReport()
+	if err := r.load(strings.NewReader("c.example.com\nb.example.com")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.fail("a.example.com", errNoAddresses)
+	r.add(classPublic, "1.1.1.1", "b.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "a.example.com\nc.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	got, err := parseClasses("private, loopback,private")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{classPrivate, classLoopback}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := parseClasses("private,internal"); err == nil {
+		t.Error("expected error for unknown class")
+	}
+}
+
+func TestOutOfScopeReport(t *testing.T) {
+	r := newOutOfScopeReport()
+	r.exclude("admin.example.com", "", `host excluded by "admin.example.com"`)
+	r.exclude("www.example.com", "10.1.2.3", "address excluded by 10.1.0.0/16")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "admin.example.com - host excluded by \"admin.example.com\"\nwww.example.com 10.1.2.3 address excluded by 10.1.0.0/16"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestURLReport(t *testing.T) {
+	tt := map[string]struct {
+		perIP bool
+		want  string
+	}{
+		"hosts":  {want: "https://a.example.com\nhttps://b.example.com"},
+		"per ip": {perIP: true, want: "https://1.1.1.1\tHost: a.example.com\nhttps://[2001:db8::1]\tHost: a.example.com\nhttps://1.1.1.1\tHost: b.example.com"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			r := newURLReport(tc.perIP)
+			r.add(classPublic, "2001:db8::1", "a.example.com")
+			r.add(classPublic, "1.1.1.1", "b.example.com")
+			r.add(classPublic, "1.1.1.1", "a.example.com")
+
+			out := &bytes.Buffer{}
+			if err := r.write(out); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestSharedReport(t *testing.T) {
+	r := newSharedReport(2)
+	r.add(classPublic, "2.2.2.2", "a.example.com")
+	r.add(classPublic, "2.2.2.2", "b.example.com")
+	r.add(classPublic, "1.1.1.1", "c.example.com")
+	r.add(classPublic, "1.1.1.1", "d.example.com")
+	r.add(classPublic, "3.3.3.3", "e.example.com")
+	r.add(classPublic, "3.3.3.3", "g.example.com")
+	r.add(classPublic, "3.3.3.3", "f.example.com")
+	r.add(classPublic, "4.4.4.4", "h.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "3 3.3.3.3 public e.example.com,f.example.com,g.example.com\n" +
+		"2 1.1.1.1 public c.example.com,d.example.com\n" +
+		"2 2.2.2.2 public a.example.com,b.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"slices"
+)
+
+type overflowReport struct {
+	entries *combinedReport
+}
+
+func newOverflowReport(mode sortMode) *overflowReport {
+	return &overflowReport{entries: newCombinedReport(mode)}
+}
+
+func (r *overflowReport) add(string, string, string) {}
+
+func (r *overflowReport) overflow(class string, ip string, subdomains []string) {
+	if r == nil {
+		return
+	}
+	r.entries.ips[ip] = &combinedEntry{class: class, subdomains: slices.Clone(subdomains)}
+}
+
+func (r *overflowReport) write(out io.Writer) error {
+	return r.entries.write(out)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestFragmentWrite_maxValues(t *testing.T) {
+	overflow := newOverflowReport(sortNumeric)
+	out := &bytes.Buffer{}
+	frag := fragment{
+		m:         make(map[netip.Addr][]string),
+		class:     classPublic,
+		maxValues: 2,
+		overflow:  overflow,
+	}
+	frag.out = frag.writerSink(out)
+	for _, subdomain := range []string{"d.example.com", "a.example.com", "c.example.com", "b.example.com"} {
+		frag.append(netip.MustParseAddr("1.1.1.1"), subdomain)
+	}
+	frag.append(netip.MustParseAddr("8.8.8.8"), "dns.example.com")
+
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "1.1.1.1 a.example.com,b.example.com\n8.8.8.8 dns.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	full := &bytes.Buffer{}
+	if err := overflow.write(full); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want = "1.1.1.1 public a.example.com,b.example.com,c.example.com,d.example.com"
+	if got := full.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// parquetRowGroupSize is the number of rows buffered before they are written
+// as a row group.
+const parquetRowGroupSize = 100000
+
+const parquetMagic = "PAR1"
+
+// Parquet physical and converted types, see parquet.thrift.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+)
+
+// Thrift compact protocol field types.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+var parquetColumns = []struct {
+	name      string
+	typ       int32
+	converted int32
+}{
+	{"subdomain", parquetByteArray, parquetUTF8},
+	{"ip", parquetByteArray, parquetUTF8},
+	{"class", parquetByteArray, parquetUTF8},
+	{"timestamp", parquetInt64, parquetTimestampMillis},
+}
+
+// parquetWriter writes one row per subdomain and ip address as an
+// uncompressed, PLAIN encoded parquet file with the parquetColumns.
+type parquetWriter struct {
+	out   io.Writer
+	class string
+	seen  map[string]time.Time
+	now   time.Time
+
+	offset    int64
+	rows      int
+	columns   [4][]byte
+	rowGroups []parquetRowGroup
+
+	maxValues int
+	overflow  *overflowReport
+}
+
+type parquetRowGroup struct {
+	rows    int
+	offsets [4]int64
+	sizes   [4]int
+}
+
+func newParquetWriter(out io.Writer, class string, seen map[string]time.Time) *parquetWriter {
+	return &parquetWriter{out: out, class: class, seen: seen, now: time.Now()}
+}
+
+func (w *parquetWriter) write(r record) error {
+	key, values := r.key, r.values
+	if w.maxValues > 0 && len(values) > w.maxValues {
+		w.overflow.overflow(w.class, key, values)
+		values = values[:w.maxValues]
+	}
+
+	for _, subdomain := range values {
+		seen, ok := w.seen[subdomain]
+		if !ok {
+			seen = w.now
+		}
+		w.columns[0] = appendParquetString(w.columns[0], subdomain)
+		w.columns[1] = appendParquetString(w.columns[1], key)
+		w.columns[2] = appendParquetString(w.columns[2], w.class)
+		w.columns[3] = binary.LittleEndian.AppendUint64(w.columns[3], uint64(seen.UnixMilli()))
+		w.rows++
+		if w.rows == parquetRowGroupSize {
+			if err := w.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *parquetWriter) writeBytes(b []byte) error {
+	n, err := w.out.Write(b)
+	w.offset += int64(n)
+	return err
+}
+
+// flush writes the buffered rows as a row group with a single data page per
+// column.
+func (w *parquetWriter) flush() error {
+	if w.offset == 0 {
+		if err := w.writeBytes([]byte(parquetMagic)); err != nil {
+			return err
+		}
+	}
+	if w.rows == 0 {
+		return nil
+	}
+
+	g := parquetRowGroup{rows: w.rows}
+	for i, data := range w.columns {
+		var t thriftWriter
+		t.begin(0)
+		t.i32(1, 0) // DATA_PAGE
+		t.i32(2, int32(len(data)))
+		t.i32(3, int32(len(data)))
+		t.begin(5)
+		t.i32(1, int32(w.rows))
+		t.i32(2, 0) // PLAIN
+		t.i32(3, 3) // RLE
+		t.i32(4, 3)
+		t.end()
+		t.end()
+
+		g.offsets[i] = w.offset
+		g.sizes[i] = len(t.buf) + len(data)
+		if err := w.writeBytes(t.buf); err != nil {
+			return err
+		}
+		if err := w.writeBytes(data); err != nil {
+			return err
+		}
+		w.columns[i] = data[:0]
+	}
+	w.rowGroups = append(w.rowGroups, g)
+	w.rows = 0
+	return nil
+}
+
+func (w *parquetWriter) close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	var t thriftWriter
+	t.begin(0)
+	t.i32(1, 1)
+	t.list(2, thriftStruct, len(parquetColumns)+1)
+	t.begin(0)
+	t.str(4, "schema")
+	t.i32(5, int32(len(parquetColumns)))
+	t.end()
+	for _, c := range parquetColumns {
+		t.begin(0)
+		t.i32(1, c.typ)
+		t.i32(3, 0) // REQUIRED
+		t.str(4, c.name)
+		t.i32(6, c.converted)
+		t.end()
+	}
+
+	var total int64
+	for _, g := range w.rowGroups {
+		total += int64(g.rows)
+	}
+	t.i64(3, total)
+
+	t.list(4, thriftStruct, len(w.rowGroups))
+	for _, g := range w.rowGroups {
+		t.begin(0)
+		t.list(1, thriftStruct, len(parquetColumns))
+		var size int64
+		for i, c := range parquetColumns {
+			size += int64(g.sizes[i])
+			t.begin(0)
+			t.i64(2, g.offsets[i])
+			t.begin(3)
+			t.i32(1, c.typ)
+			t.list(2, thriftI32, 1)
+			t.buf = binary.AppendVarint(t.buf, 0) // PLAIN
+			t.list(3, thriftBinary, 1)
+			t.buf = binary.AppendUvarint(t.buf, uint64(len(c.name)))
+			t.buf = append(t.buf, c.name...)
+			t.i32(4, 0) // UNCOMPRESSED
+			t.i64(5, int64(g.rows))
+			t.i64(6, int64(g.sizes[i]))
+			t.i64(7, int64(g.sizes[i]))
+			t.i64(9, g.offsets[i])
+			t.end()
+			t.end()
+		}
+		t.i64(2, size)
+		t.i64(3, int64(g.rows))
+		t.end()
+	}
+	t.str(6, "ipsubmap "+readBuildDetails().Version)
+	t.end()
+
+	t.buf = binary.LittleEndian.AppendUint32(t.buf, uint32(len(t.buf)))
+	return w.writeBytes(append(t.buf, parquetMagic...))
+}
+
+func appendParquetString(b []byte, s string) []byte {
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// thriftWriter encodes the thrift compact protocol structs of the parquet
+// metadata.
+type thriftWriter struct {
+	buf  []byte
+	last []int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.buf = append(t.buf, byte(delta)<<4|typ)
+	} else {
+		t.buf = append(t.buf, typ)
+		t.buf = binary.AppendVarint(t.buf, int64(id))
+	}
+	*last = id
+}
+
+// begin starts a struct, as field id of the current struct or, with id 0, as
+// a top level struct or list element.
+func (t *thriftWriter) begin(id int16) {
+	if id > 0 {
+		t.field(id, thriftStruct)
+	}
+	t.last = append(t.last, 0)
+}
+
+func (t *thriftWriter) end() {
+	t.buf = append(t.buf, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.buf = binary.AppendVarint(t.buf, int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.buf = binary.AppendVarint(t.buf, v)
+}
+
+func (t *thriftWriter) str(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.buf = binary.AppendUvarint(t.buf, uint64(len(s)))
+	t.buf = append(t.buf, s...)
+}
+
+// list starts a list field of n elements of typ, which are appended next.
+func (t *thriftWriter) list(id int16, typ byte, n int) {
+	t.field(id, thriftList)
+	if n < 15 {
+		t.buf = append(t.buf, byte(n)<<4|typ)
+		return
+	}
+	t.buf = append(t.buf, 0xf0|typ)
+	t.buf = binary.AppendUvarint(t.buf, uint64(n))
+}
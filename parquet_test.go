@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// thriftStructAt decodes the thrift compact struct at the start of b into a
+// map by field id, and returns the number of bytes read.
+func thriftStructAt(t *testing.T, b []byte) (map[int16]any, int) {
+	t.Helper()
+	fields := make(map[int16]any)
+	var last int16
+	n := 0
+	for {
+		header := b[n]
+		n++
+		if header == 0 {
+			return fields, n
+		}
+		typ := header & 0x0f
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			v, m := binary.Varint(b[n:])
+			id = int16(v)
+			n += m
+		}
+		last = id
+		v, m := thriftValueAt(t, typ, b[n:])
+		fields[id] = v
+		n += m
+	}
+}
+
+func thriftValueAt(t *testing.T, typ byte, b []byte) (any, int) {
+	t.Helper()
+	switch typ {
+	case thriftI32, thriftI64:
+		return binary.Varint(b)
+	case thriftBinary:
+		size, m := binary.Uvarint(b)
+		return string(b[m : m+int(size)]), m + int(size)
+	case thriftStruct:
+		return thriftStructAt(t, b)
+	case thriftList:
+		size, elem, n := int(b[0]>>4), b[0]&0x0f, 1
+		if size == 15 {
+			s, m := binary.Uvarint(b[1:])
+			size, n = int(s), 1+m
+		}
+		values := make([]any, size)
+		for i := range values {
+			v, m := thriftValueAt(t, elem, b[n:])
+			values[i] = v
+			n += m
+		}
+		return values, n
+	}
+	t.Fatalf("unexpected thrift type %d", typ)
+	return nil, 0
+}
+
+func TestFragmentWrite_parquet(t *testing.T) {
+	seen := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	out := &bytes.Buffer{}
+	frag := fragment{
+		class:  classPublic,
+		format: formatParquet,
+		seen:   map[string]time.Time{"a.example.com": seen, "b.example.com": seen},
+		m: addrMap(map[string][]string{
+			"2.2.2.2": {"b.example.com"},
+			"1.1.1.1": {"a.example.com", "b.example.com"},
+		}),
+	}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := out.Bytes()
+	if !bytes.HasPrefix(b, []byte(parquetMagic)) || !bytes.HasSuffix(b, []byte(parquetMagic)) {
+		t.Fatalf("missing magic in %q", b)
+	}
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	meta, n := thriftStructAt(t, b[len(b)-8-size:])
+	if n != size {
+		t.Fatalf("expected footer of %d bytes, read %d", size, n)
+	}
+	if rows := meta[3]; rows != int64(3) {
+		t.Fatalf("expected 3 rows, got %v", rows)
+	}
+	if schema := meta[2].([]any); len(schema) != len(parquetColumns)+1 {
+		t.Fatalf("expected %d schema elements, got %d", len(parquetColumns)+1, len(schema))
+	}
+
+	var want [4][]byte
+	for _, row := range [][2]string{{"a.example.com", "1.1.1.1"}, {"b.example.com", "1.1.1.1"}, {"b.example.com", "2.2.2.2"}} {
+		want[0] = appendParquetString(want[0], row[0])
+		want[1] = appendParquetString(want[1], row[1])
+		want[2] = appendParquetString(want[2], classPublic)
+		want[3] = binary.LittleEndian.AppendUint64(want[3], uint64(seen.UnixMilli()))
+	}
+
+	group := meta[4].([]any)[0].(map[int16]any)
+	for i, c := range group[1].([]any) {
+		column := c.(map[int16]any)[3].(map[int16]any)
+		if path := column[3].([]any)[0]; path != parquetColumns[i].name {
+			t.Errorf("expected column %q, got %q", parquetColumns[i].name, path)
+		}
+		offset := column[9].(int64)
+		page, n := thriftStructAt(t, b[offset:])
+		if values := page[5].(map[int16]any)[1]; values != int64(3) {
+			t.Errorf("expected 3 values in column %q, got %v", parquetColumns[i].name, values)
+		}
+		data := b[int(offset)+n : int(offset)+n+int(page[3].(int64))]
+		if !bytes.Equal(data, want[i]) {
+			t.Errorf("expected column %q data %q, got %q", parquetColumns[i].name, want[i], data)
+		}
+		if total := column[7].(int64); total != int64(n)+page[3].(int64) {
+			t.Errorf("expected column %q size %d, got %d", parquetColumns[i].name, int64(n)+page[3].(int64), total)
+		}
+	}
+}
+
+func TestFragmentWrite_parquetEmpty(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{class: classPublic, format: formatParquet, m: map[netip.Addr][]string{}}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	b := out.Bytes()
+	size := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	if len(b) != 4+size+8 {
+		t.Fatalf("expected only magic and footer, got %q", b)
+	}
+	meta, _ := thriftStructAt(t, b[4:])
+	if rows := meta[3]; rows != int64(0) {
+		t.Fatalf("expected no rows, got %v", rows)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const expandPermute = "permute"
+
+// loadWords loads the word list given by flag, which names the file in errors.
+func loadWords(flag string, path string) ([]string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %v", flag, err)
+	}
+	defer in.Close()
+
+	var words []string
+	seen := make(map[string]bool)
+	err = scanLines(in, func(n int, line string) error {
+		word := strings.ToLower(strings.TrimSpace(line))
+		if word == "" || strings.HasPrefix(word, "#") || seen[word] {
+			return nil
+		}
+		if !labelPattern.MatchString(word) {
+			return fmt.Errorf("line %d: %q is not a hostname label", n, line)
+		}
+		seen[word] = true
+		words = append(words, word)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s %q: %v", flag, path, err)
+	}
+	return words, nil
+}
+
+// permutations generates altdns style candidates for host by adding a word as
+// a new label, joining it to the first label, and numbering the first label.
+func permutations(host string, words []string) []string {
+	label, rest, ok := strings.Cut(host, ".")
+	if !ok || rest == "" {
+		return nil
+	}
+
+	var out []string
+	for _, word := range words {
+		out = append(out,
+			word+"."+host,
+			word+"-"+label+"."+rest,
+			label+"-"+word+"."+rest,
+			word+label+"."+rest,
+			label+word+"."+rest,
+		)
+	}
+
+	base := strings.TrimRight(label, "0123456789")
+	if n, err := strconv.Atoi(label[len(base):]); err == nil {
+		for _, m := range []int{n - 1, n + 1} {
+			if m >= 0 {
+				out = append(out, base+strconv.Itoa(m)+"."+rest)
+			}
+		}
+	} else {
+		for m := 1; m <= 3; m++ {
+			out = append(out, label+strconv.Itoa(m)+"."+rest)
+		}
+	}
+	return out
+}
+
+// permute resolves the permutations of every input hostname after the input
+// has been enumerated, keeping only the candidates that answer.
+func (m *ipSubMap) permute() {
+	if m.seen == nil {
+		m.seen = make(map[string]bool)
+	}
+	// Permutations are named under the input hostname or next to it, so the
+	// wildcards of both domains apply.
+	var domains []string
+	probed := make(map[string]bool)
+	for _, host := range m.inputNames {
+		_, parent, _ := strings.Cut(host, ".")
+		for _, domain := range []string{host, parent} {
+			if !isPublicSuffix(domain) && !probed[domain] {
+				probed[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+	m.resolveCandidates(expandPermute, m.wildcards(domains), func(queue func(name string, source string)) {
+		for _, host := range m.inputNames {
+			for _, candidate := range permutations(host, m.permutationWords) {
+				queue(candidate, host)
+			}
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPermutations(t *testing.T) {
+	tt := map[string]struct {
+		host string
+		want []string
+	}{
+		"word": {
+			host: "api.example.com",
+			want: []string{
+				"dev.api.example.com", "dev-api.example.com", "api-dev.example.com", "devapi.example.com", "apidev.example.com",
+				"api1.example.com", "api2.example.com", "api3.example.com",
+			},
+		},
+		"numbered": {
+			host: "web01.example.com",
+			want: []string{
+				"dev.web01.example.com", "dev-web01.example.com", "web01-dev.example.com", "devweb01.example.com", "web01dev.example.com",
+				"web0.example.com", "web2.example.com",
+			},
+		},
+		"single label": {host: "localhost"},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := permutations(tc.host, []string{"dev"}); !slices.Equal(got, tc.want) {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadWords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	os.WriteFile(path, []byte("# words\ndev\n\nStaging\ndev\n"), 0o644)
+
+	words, err := loadWords("-permutation-words", path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"dev", "staging"}; !slices.Equal(words, want) {
+		t.Errorf("expected %q, got %q", want, words)
+	}
+
+	os.WriteFile(path, []byte("dev\n*.prod\n"), 0o644)
+	_, err = loadWords("-wordlist", path)
+	if err == nil || !strings.Contains(err.Error(), "-wordlist") {
+		t.Errorf("expected error naming -wordlist for invalid word, got %v", err)
+	}
+}
+
+func TestIPSubMapPermute(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "api.example.com":
+			return []net.IP{net.ParseIP("93.184.216.34")}, nil
+		case "api-dev.example.com":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		default:
+			return nil, fmt.Errorf("no such host")
+		}
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	report := newExpansionReport()
+	unresolved := newUnresolvedReport()
+	m := &ipSubMap{
+		ipv4:             true,
+		permutationWords: []string{"dev"},
+		seen:             make(map[string]bool),
+		public:           fragment{m: make(map[netip.Addr][]string)},
+		private:          fragment{m: make(map[netip.Addr][]string)},
+		reports: []reportOutput{
+			{name: "expanded", report: report},
+			{name: "unresolved", report: unresolved},
+		},
+	}
+	if err := m.enumerate(strings.NewReader("api.example.com\napi.example.com\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; !slices.Equal(got, []string{"api-dev.example.com"}) {
+		t.Errorf("expected the permutation to be recorded, got %q", got)
+	}
+	out := &bytes.Buffer{}
+	report.write(out)
+	if want := "api-dev.example.com permute api.example.com"; out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+	if len(unresolved.names) != 0 {
+		t.Errorf("expected unresolved permutations not to be reported, got %v", unresolved.names)
+	}
+}
+
+func TestIPSubMapPermute_wildcard(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch {
+		case host == "api.wild.example.org":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case host == "api-dev.wild.example.org":
+			return []net.IP{net.ParseIP("10.0.0.2")}, nil
+		case strings.HasSuffix(host, ".wild.example.org"):
+			return []net.IP{net.ParseIP("10.0.0.9")}, nil
+		default:
+			return nil, fmt.Errorf("no such host")
+		}
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	report := newExpansionReport()
+	m := &ipSubMap{
+		ipv4:             true,
+		permutationWords: []string{"dev", "staging"},
+		seen:             make(map[string]bool),
+		private:          fragment{m: make(map[netip.Addr][]string)},
+		reports:          []reportOutput{{name: "expanded", report: report}},
+	}
+	if err := m.enumerate(strings.NewReader("api.wild.example.org\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, ok := m.private.m[netip.MustParseAddr("10.0.0.9")]; ok {
+		t.Errorf("expected wildcard answers to be dropped, got %q", got)
+	}
+	if want := "api-dev.wild.example.org permute api.wild.example.org"; strings.Join(report.lines, "\n") != want {
+		t.Errorf("expected %q, got %q", want, report.lines)
+	}
+}
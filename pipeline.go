@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pipelineBuffer = 256
+
+// pendingLine is an input line travelling through the pipeline. Hostnames are
+// resolved by a worker before the line reaches the recording stage.
+type pendingLine struct {
+	line     string
+	resolved bool
+	ips      []net.IP
+	err      error
+	elapsed  time.Duration
+	done     chan struct{}
+
+	cnameLooked bool
+	cname       string
+	cnameErr    error
+
+	// The answers of the -vantage endpoints, when looked up by a worker.
+	vantages map[string][]string
+
+	// Candidates generated from source by kind, the -expand rule or the
+	// generator. When expansions is set, the -expand names of the line follow
+	// it, and are only recorded once expand is set by m.expand.
+	kind       string
+	source     string
+	parent     *pendingLine
+	expansions bool
+	expand     bool
+}
+
+// prefetchable reports whether the lookup of line can run ahead of the
+// recording stage. Filtered hostnames are never resolved.
+func (m *ipSubMap) prefetchable(line string) bool {
+	if m.mode == modeReverse || m.mode == modeClassify || (m.input != "" && m.input != inputText) {
+		return false
+	}
+	ok, _ := m.filterHost(line)
+	return ok
+}
+
+// pipeline reads in on its own goroutine and resolves hostnames, along with
+// their -expand names, on the prefetch workers. The returned function reports
+// read errors once the channel is closed.
+func (m *ipSubMap) pipeline(in io.Reader, stop <-chan struct{}) (<-chan *pendingLine, func() error) {
+	var readErr error
+	dedupe := m.seen != nil
+	lines := m.prefetch(stop, func(queue func(p *pendingLine, lookup bool)) {
+		queued := make(map[string]bool)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			line := cleanLine(scanner.Text())
+			if line == "" {
+				continue
+			}
+
+			p := &pendingLine{line: line}
+			lookup := !stopped(stop) && m.prefetchable(line) && !queued[line]
+			if lookup {
+				queued[line] = dedupe
+				p.expansions = len(m.expansions) > 0
+			}
+			queue(p, lookup)
+			if !p.expansions {
+				continue
+			}
+			for _, e := range expansions(line, m.expansions) {
+				ok, _ := m.filterHost(e.name)
+				queue(&pendingLine{line: e.name, kind: e.rule, source: line, parent: p}, ok && !queued[e.name])
+				queued[e.name] = true
+			}
+		}
+		readErr = scanner.Err()
+	})
+	return lines, func() error { return readErr }
+}
+
+// resolveCandidates resolves the hostnames queued by generate on the prefetch
+// workers, and records the ones that answer as generated by kind. Answers
+// made up only of the wildcard addresses of the parent domain of a name are
+// dropped.
+func (m *ipSubMap) resolveCandidates(kind string, wildcards map[string]map[string]bool, generate func(queue func(name string, source string))) {
+	stop := make(chan struct{})
+	candidates := m.prefetch(stop, func(queue func(p *pendingLine, lookup bool)) {
+		queued := make(map[string]bool)
+		generate(func(name string, source string) {
+			ok, _ := m.filterHost(name)
+			queue(&pendingLine{line: name, kind: kind, source: source}, ok && !stopped(stop) && !queued[name])
+			queued[name] = true
+		})
+	})
+	for p := range candidates {
+		<-p.done
+		m.pending = p
+		_, parent, _ := strings.Cut(p.line, ".")
+		m.resolveCandidate(p.line, p.kind, p.source, wildcards[parent])
+		m.pending = nil
+		if m.expired && !stopped(stop) {
+			close(stop)
+		}
+	}
+}
+
+// prefetch resolves the lines queued by produce on m.workers goroutines, at
+// most m.perZone at once per zone, while produce runs on its own goroutine. The
+// lines come out in the order they were queued once their lookup finished.
+// Once stop is closed, the remaining lines come out without being resolved.
+func (m *ipSubMap) prefetch(stop <-chan struct{}, produce func(queue func(p *pendingLine, lookup bool))) <-chan *pendingLine {
+	lines := make(chan *pendingLine, pipelineBuffer)
+	jobs := newJobQueue(m.perZone)
+
+	parent := m.span
+	var wg sync.WaitGroup
+	for range max(m.workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				p, ok := jobs.next()
+				if !ok {
+					return
+				}
+				if !stopped(stop) {
+					p.ips, p.elapsed, p.err = m.timedLookup(parent, p.line)
+					if p.err == nil && m.followCNAMEs && p.kind == "" {
+						p.cname, p.cnameErr = lookupCNAME(p.line)
+						p.cnameLooked = true
+					}
+					if len(m.vantages) > 0 && p.kind == "" {
+						p.vantages = m.vantageAnswers(p.line)
+					}
+				}
+				jobs.done(p)
+				close(p.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer func() {
+			jobs.close()
+			wg.Wait()
+			close(lines)
+		}()
+
+		produce(func(p *pendingLine, lookup bool) {
+			p.done = make(chan struct{})
+			if lookup && !stopped(stop) {
+				p.resolved = true
+				jobs.push(p)
+			} else {
+				close(p.done)
+			}
+			lines <- p
+		})
+	}()
+
+	return lines
+}
+
+// timedLookup resolves subdomain in a lookup span under parent.
+func (m *ipSubMap) timedLookup(parent *span, subdomain string) ([]net.IP, time.Duration, error) {
+	span := m.tracer.start("lookup", parent, spanKindClient, stringAttr("dns.name", subdomain))
+	started := time.Now()
+	ips, err := sampleIPs(subdomain, m.samples)
+	elapsed := time.Since(started)
+	span.set(intAttr("dns.addresses", len(ips)))
+	span.end(err)
+	return ips, elapsed, err
+}
+
+func stopped(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIPSubMapPipeline(t *testing.T) {
+	var mu sync.Mutex
+	var looked []string
+	var running, peak atomic.Int32
+	lookupIP = func(host string) ([]net.IP, error) {
+		if n := running.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		defer running.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		looked = append(looked, host)
+		mu.Unlock()
+		if strings.HasPrefix(host, "missing") {
+			return nil, fmt.Errorf("no such host")
+		}
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	tt := map[string]struct {
+		workers int
+		peak    int32
+	}{
+		"sequential": {workers: 1, peak: 1},
+		"concurrent": {workers: 4, peak: 2},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			looked, peak = nil, atomic.Int32{}
+			m := &ipSubMap{
+				ipv4:    true,
+				workers: tc.workers,
+				seen:    make(map[string]bool),
+				hosts:   &hostFilter{exclude: regexp.MustCompile(`^skip`)},
+				public:  fragment{m: make(map[netip.Addr][]string)},
+				private: fragment{m: make(map[netip.Addr][]string)},
+			}
+			in := "a.example.com\nmissing1.example.com\nskip.example.com\nb.example.com\nmissing2.example.com\na.example.com\nc.example.com\n"
+			err := m.enumerate(strings.NewReader(in))
+			if err == nil {
+				t.Fatalf("expected lookup errors, got %v", err)
+			}
+			if i, j := strings.Index(err.Error(), "missing1"), strings.Index(err.Error(), "missing2"); i > j {
+				t.Errorf("expected errors in input order, got %v", err)
+			}
+
+			if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; len(got) != 3 {
+				t.Errorf("expected 3 subdomains, got %q", got)
+			}
+			if len(looked) != 5 {
+				t.Errorf("expected each allowed hostname to be resolved once, got %q", looked)
+			}
+			if peak.Load() < tc.peak || (tc.workers == 1 && peak.Load() != 1) {
+				t.Errorf("expected at least %d concurrent lookups, got %d", tc.peak, peak.Load())
+			}
+		})
+	}
+}
+
+func TestIPSubMapEnumerate_deadline(t *testing.T) {
+	lookupIP = func(host string) ([]net.IP, error) {
+		time.Sleep(10 * time.Millisecond)
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	var lines []string
+	for i := range 20 {
+		lines = append(lines, fmt.Sprintf("host%d.example.com", i))
+	}
+
+	tt := map[string]struct {
+		deadline time.Duration
+		expired  bool
+	}{
+		"expired":     {deadline: 35 * time.Millisecond, expired: true},
+		"already":     {deadline: -time.Second, expired: true},
+		"not reached": {deadline: time.Minute},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			remaining := &strings.Builder{}
+			m := &ipSubMap{
+				ipv4:      true,
+				deadline:  time.Now().Add(tc.deadline),
+				remaining: remaining,
+				private:   fragment{m: make(map[netip.Addr][]string)},
+			}
+			if err := m.enumerate(strings.NewReader(strings.Join(lines, "\n"))); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if m.expired != tc.expired {
+				t.Fatalf("expected expired %v, got %v", tc.expired, m.expired)
+			}
+			resolved := len(m.private.m[netip.MustParseAddr("10.0.0.1")])
+			if resolved+m.unprocessed != len(lines) {
+				t.Errorf("expected %d lines, got %d resolved and %d unprocessed", len(lines), resolved, m.unprocessed)
+			}
+			if tc.expired && m.unprocessed == 0 {
+				t.Error("expected unprocessed lines")
+			}
+
+			want := ""
+			if m.unprocessed > 0 {
+				want = strings.Join(lines[resolved:], "\n") + "\n"
+			}
+			if remaining.String() != want {
+				t.Errorf("expected remaining %q, got %q", want, remaining.String())
+			}
+		})
+	}
+}
+
+func TestIPSubMapEnumerate_generated(t *testing.T) {
+	var running, peak atomic.Int32
+	lookupIP = func(host string) ([]net.IP, error) {
+		if n := running.Add(1); n > peak.Load() {
+			peak.Store(n)
+		}
+		defer running.Add(-1)
+		time.Sleep(10 * time.Millisecond)
+		if strings.HasPrefix(host, "w") || strings.HasPrefix(host, "api.") {
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		}
+		return nil, fmt.Errorf("no such host")
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	tr, err := newTracer("http://127.0.0.1:1", "ipsubmap")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := newExpansionReport()
+	m := &ipSubMap{
+		ipv4:       true,
+		workers:    4,
+		tracer:     tr,
+		expansions: []string{"api"},
+		brute:      &bruteForce{words: []string{"w1", "w2", "w3", "w4", "www"}, domains: []string{"example.com"}},
+		seen:       make(map[string]bool),
+		private:    fragment{m: make(map[netip.Addr][]string)},
+		reports:    []reportOutput{{name: "expanded", report: report}},
+	}
+	if err := m.enumerate(strings.NewReader("www.example.com\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "api.example.com api www.example.com\nw1.example.com brute example.com\nw2.example.com brute example.com\n" +
+		"w3.example.com brute example.com\nw4.example.com brute example.com"
+	if got := strings.Join(report.lines, "\n"); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if peak.Load() < 2 {
+		t.Errorf("expected concurrent candidate lookups, got %d", peak.Load())
+	}
+
+	lookups := 0
+	for _, s := range tr.spans {
+		if s.Name != "lookup" {
+			continue
+		}
+		lookups++
+		start, _ := strconv.ParseInt(s.StartTimeUnixNano, 10, 64)
+		end, _ := strconv.ParseInt(s.EndTimeUnixNano, 10, 64)
+		if time.Duration(end-start) < 10*time.Millisecond {
+			t.Errorf("expected the %s span to cover the lookup, got %v", *s.Attributes[0].Value.StringValue, time.Duration(end-start))
+		}
+	}
+	if lookups < 7 {
+		t.Errorf("expected a span per lookup, got %d", lookups)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+type progress struct {
+	entries atomic.Int64
+	classes map[string]*atomic.Int64
+}
+
+func newProgress() *progress {
+	p := &progress{classes: make(map[string]*atomic.Int64)}
+	for _, class := range classes {
+		p.classes[class] = &atomic.Int64{}
+	}
+	return p
+}
+
+func (p *progress) entry() {
+	if p != nil {
+		p.entries.Add(1)
+	}
+}
+
+func (p *progress) add(class string) {
+	if p != nil {
+		p.classes[class].Add(1)
+	}
+}
+
+func (p *progress) log(logger *slog.Logger) {
+	args := []any{"entries", p.entries.Load()}
+	for _, class := range classes {
+		args = append(args, class, p.classes[class].Load())
+	}
+	logger.Info("Progress", args...)
+}
+
+// report logs the counters every interval until stop is called.
+func (p *progress) report(logger *slog.Logger, interval time.Duration) (stop func()) {
+	done, exited := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(exited)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.log(logger)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-exited
+	}
+}
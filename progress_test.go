@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	p := newProgress()
+	m := &ipSubMap{
+		ipv4:     true,
+		mode:     modeClassify,
+		progress: p,
+		private:  fragment{m: make(map[netip.Addr][]string)},
+		public:   fragment{m: make(map[netip.Addr][]string)},
+	}
+	if err := m.enumerate(strings.NewReader("a.example.com 10.0.0.1\nb.example.com 10.0.0.2 1.1.1.1\n")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+		if a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}}))
+	stop := p.report(logger, 10*time.Millisecond)
+	time.Sleep(25 * time.Millisecond)
+	stop()
+
+	want := "level=INFO msg=Progress entries=2 private=2 public=1 loopback=0"
+	if line, _, _ := strings.Cut(out.String(), "\n"); line != want {
+		t.Errorf("expected %q, got %q", want, line)
+	}
+}
@@ -0,0 +1,27 @@
+package main
+
+import "encoding/binary"
+
+// protoEncoder writes length-delimited ipsubmap.v1.Mapping messages, see
+// ipsubmap.proto.
+type protoEncoder struct {
+	class string
+}
+
+func (e protoEncoder) encode(b []byte, r record) ([]byte, error) {
+	msg := appendProtoString(nil, 1, r.key)
+	msg = appendProtoString(msg, 2, e.class)
+	for _, v := range r.values {
+		msg = appendProtoString(msg, 3, v)
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(msg)))
+	return append(b, msg...), nil
+}
+
+// appendProtoString appends a length-delimited field (wire type 2).
+func appendProtoString(b []byte, field int, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(field)<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
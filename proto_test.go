@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+)
+
+// readMappings decodes the length-delimited Mapping messages in b.
+func readMappings(t *testing.T, b []byte) [][]string {
+	t.Helper()
+	r := bufio.NewReader(bytes.NewReader(b))
+	var mappings [][]string
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return mappings
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		msg := make([]byte, n)
+		if _, err := io.ReadFull(r, msg); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var fields []string
+		for len(msg) > 0 {
+			tag, i := binary.Uvarint(msg)
+			if tag&7 != 2 {
+				t.Fatalf("unexpected wire type %d", tag&7)
+			}
+			size, j := binary.Uvarint(msg[i:])
+			value := string(msg[i+j : i+j+int(size)])
+			msg = msg[i+j+int(size):]
+			fields = append(fields, fmt.Sprintf("%d=%s", tag>>3, value))
+		}
+		mappings = append(mappings, fields)
+	}
+}
+
+func TestFragmentWrite_proto(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{
+		class:  classPublic,
+		format: formatProto,
+		m: addrMap(map[string][]string{
+			"2.2.2.2": {"b.example.com"},
+			"1.1.1.1": {"a.example.com", "b.example.com"},
+		}),
+	}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := [][]string{
+		{"1=1.1.1.1", "2=public", "3=a.example.com", "3=b.example.com"},
+		{"1=2.2.2.2", "2=public", "3=b.example.com"},
+	}
+	got := readMappings(t, out.Bytes())
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type ptrZoneReport struct {
+	names map[netip.Addr][]string
+}
+
+func newPTRZoneReport() *ptrZoneReport {
+	return &ptrZoneReport{names: make(map[netip.Addr][]string)}
+}
+
+func (r *ptrZoneReport) add(_ string, ip string, subdomain string) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return
+	}
+	addr = addr.Unmap()
+	if !slices.Contains(r.names[addr], subdomain) {
+		r.names[addr] = append(r.names[addr], subdomain)
+	}
+}
+
+// reverseName returns the in-addr.arpa or ip6.arpa name of addr.
+func reverseName(addr netip.Addr) string {
+	var labels []string
+	if addr.Is4() {
+		for _, b := range addr.As4() {
+			labels = append(labels, strconv.Itoa(int(b)))
+		}
+		slices.Reverse(labels)
+		return strings.Join(labels, ".") + ".in-addr.arpa."
+	}
+
+	for _, b := range addr.As16() {
+		labels = append(labels, strconv.FormatUint(uint64(b>>4), 16), strconv.FormatUint(uint64(b&0xf), 16))
+	}
+	slices.Reverse(labels)
+	return strings.Join(labels, ".") + ".ip6.arpa."
+}
+
+func (r *ptrZoneReport) write(out io.Writer) error {
+	addrs := make([]netip.Addr, 0, len(r.names))
+	for addr := range r.names {
+		addrs = append(addrs, addr)
+	}
+	slices.SortFunc(addrs, netip.Addr.Compare)
+
+	w := bufio.NewWriter(out)
+	for _, addr := range addrs {
+		names := slices.Clone(r.names[addr])
+		slices.Sort(names)
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\t%d\tIN\tPTR\t%s.\n", reverseName(addr), zoneTTL, name)
+		}
+	}
+	return w.Flush()
+}
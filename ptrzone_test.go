@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+)
+
+func TestReverseName(t *testing.T) {
+	tt := map[string]string{
+		"93.184.216.34": "34.216.184.93.in-addr.arpa.",
+		"2001:db8::1":   "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa.",
+	}
+
+	for ip, want := range tt {
+		if got := reverseName(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("%s: expected %q, got %q", ip, want, got)
+		}
+	}
+}
+
+func TestPTRZoneReport(t *testing.T) {
+	r := newPTRZoneReport()
+	r.add(classPublic, "93.184.216.34", "www.example.com")
+	r.add(classPrivate, "10.0.0.1", "b.example.com")
+	r.add(classPrivate, "10.0.0.1", "a.example.com")
+	r.add(classPrivate, "::ffff:10.0.0.1", "a.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "1.0.0.10.in-addr.arpa.\t3600\tIN\tPTR\ta.example.com.\n" +
+		"1.0.0.10.in-addr.arpa.\t3600\tIN\tPTR\tb.example.com.\n" +
+		"34.216.184.93.in-addr.arpa.\t3600\tIN\tPTR\twww.example.com.\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+const maxReverseAddresses = 1 << 16
+
+var (
+	lookupAddr = net.LookupAddr
+
+	errNoNames = errors.New("no PTR records")
+)
+
+func reverseTargets(line string) ([]netip.Addr, error) {
+	if !strings.Contains(line, "/") {
+		addr, err := netip.ParseAddr(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip address %q", line)
+		}
+		return []netip.Addr{addr.Unmap()}, nil
+	}
+
+	prefix, err := netip.ParsePrefix(line)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q", line)
+	}
+	prefix = prefix.Masked()
+	if hostBits := prefix.Addr().BitLen() - prefix.Bits(); hostBits > 16 {
+		return nil, fmt.Errorf("CIDR %q has more than %d addresses", line, maxReverseAddresses)
+	}
+
+	var addrs []netip.Addr
+	for addr := prefix.Addr(); addr.IsValid() && prefix.Contains(addr); addr = addr.Next() {
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+func (m *ipSubMap) reverse(line string) error {
+	addrs, err := reverseTargets(line)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, addr := range addrs {
+		if addr.Is4() && !m.ipv4 || addr.Is6() && !m.ipv6 {
+			continue
+		}
+		ip := addr.String()
+
+		span := m.tracer.start("lookup", m.span, spanKindClient, stringAttr("dns.address", ip))
+		started := time.Now()
+		names, err := lookupAddr(ip)
+		m.latency.observe(reverseZoneOf(addr), time.Since(started), err)
+		span.set(intAttr("dns.names", len(names)))
+		span.end(err)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				m.fail(ip, err)
+				continue
+			}
+			m.fail(ip, err)
+			errs = append(errs, fmt.Errorf("failed to look up ip address %q: %v", ip, err))
+			continue
+		}
+		if len(names) == 0 {
+			m.fail(ip, errNoNames)
+			continue
+		}
+
+		for _, name := range names {
+			name = strings.TrimSuffix(name, ".")
+			if !m.allowsHost(name, ip) {
+				continue
+			}
+			m.record(name, addr)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestReverseTargets(t *testing.T) {
+	tt := map[string]struct {
+		line  string
+		count int
+		first string
+		last  string
+		err   bool
+	}{
+		"ipv4":        {line: "192.0.2.1", count: 1, first: "192.0.2.1", last: "192.0.2.1"},
+		"mapped ipv4": {line: "::ffff:192.0.2.1", count: 1, first: "192.0.2.1", last: "192.0.2.1"},
+		"cidr":        {line: "192.0.2.9/30", count: 4, first: "192.0.2.8", last: "192.0.2.11"},
+		"ipv6 cidr":   {line: "2001:db8::/126", count: 4, first: "2001:db8::", last: "2001:db8::3"},
+		"too large":   {line: "10.0.0.0/8", err: true},
+		"invalid":     {line: "example.com", err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			addrs, err := reverseTargets(tc.line)
+			if tc.err {
+				if err == nil {
+					t.Errorf("expected error, got %v", addrs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(addrs) != tc.count {
+				t.Fatalf("expected %d addresses, got %d", tc.count, len(addrs))
+			}
+			if first, last := addrs[0].String(), addrs[len(addrs)-1].String(); first != tc.first || last != tc.last {
+				t.Errorf("expected %s-%s, got %s-%s", tc.first, tc.last, first, last)
+			}
+		})
+	}
+}
+
+func TestIPSubMapReverse(t *testing.T) {
+	lookupAddr = func(ip string) ([]string, error) {
+		switch ip {
+		case "10.0.0.1":
+			return []string{"db.example.com.", "db.internal."}, nil
+		case "10.0.0.2":
+			return nil, &net.DNSError{Err: "no such host", Name: ip, IsNotFound: true}
+		default:
+			return nil, fmt.Errorf("timeout")
+		}
+	}
+	defer func() { lookupAddr = net.LookupAddr }()
+
+	unresolved := newUnresolvedReport()
+	errs := newErrorReport()
+	m := &ipSubMap{
+		ipv4:    true,
+		mode:    modeReverse,
+		private: fragment{m: make(map[netip.Addr][]string)},
+		hosts:   &hostFilter{exclude: regexp.MustCompile(`\.internal$`)},
+		reports: []reportOutput{{name: "unresolved", report: unresolved}, {name: "errors", report: errs}},
+	}
+
+	if err := m.lookup("10.0.0.0/30"); err == nil {
+		t.Error("expected error for the failed lookups")
+	}
+
+	if got := m.private.m[netip.MustParseAddr("10.0.0.1")]; !slices.Equal(got, []string{"db.example.com"}) {
+		t.Errorf("expected db.example.com, got %q", got)
+	}
+	if m.droppedHosts != 1 {
+		t.Errorf("expected 1 dropped hostname, got %d", m.droppedHosts)
+	}
+	if got := unresolved.names; len(got) != 3 {
+		t.Errorf("expected 3 unresolved addresses, got %q", got)
+	}
+	if got := len(errs.entries[categoryNotFound]); got != 1 {
+		t.Errorf("expected 1 not_found error, got %d", got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GB", 1 << 30},
+	{"MB", 1 << 20},
+	{"KB", 1 << 10},
+	{"G", 1 << 30},
+	{"M", 1 << 20},
+	{"K", 1 << 10},
+	{"B", 1},
+}
+
+func parseSize(s string) (int64, error) {
+	v := strings.ToUpper(strings.TrimSpace(s))
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(v, u.suffix) {
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			unit = u.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
+
+func rotatedPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+type segment struct {
+	n    int
+	path string
+}
+
+// existingSegments returns the numbered segments of path (public.1.txt, ...)
+// that exist on disk, in order.
+func existingSegments(path string) ([]segment, error) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	prefix := strings.TrimSuffix(name, ext) + "."
+
+	entries, err := os.ReadDir(cmp.Or(dir, "."))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for _, e := range entries {
+		rest, ok := strings.CutPrefix(e.Name(), prefix)
+		if !ok {
+			continue
+		}
+		rest, ok = strings.CutSuffix(rest, ext)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.Atoi(rest); err == nil && n > 0 && rest[0] != '+' {
+			segments = append(segments, segment{n: n, path: filepath.Join(dir, e.Name())})
+		}
+	}
+	slices.SortFunc(segments, func(a, b segment) int { return cmp.Compare(a.n, b.n) })
+	return segments, nil
+}
+
+func removeStaleSegments(path string, from int) error {
+	segments, err := existingSegments(path)
+	if err != nil {
+		return err
+	}
+	for _, s := range segments {
+		if s.n < from {
+			continue
+		}
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *atomicFile) current() *atomicFile {
+	if len(a.segments) == 0 {
+		return a
+	}
+	return a.segments[len(a.segments)-1]
+}
+
+func (a *atomicFile) Write(p []byte) (int, error) {
+	cur := a.current()
+	n, err := cur.File.Write(p)
+	a.size += int64(n)
+	return n, err
+}
+
+func (a *atomicFile) WriteString(s string) (int, error) {
+	return a.Write([]byte(s))
+}
+
+// full reports whether writing n more bytes, after the buffered bytes not yet
+// written, would exceed maxSize.
+func (a *atomicFile) full(buffered int, n int) bool {
+	size := a.size + int64(buffered)
+	return a.maxSize > 0 && size > 0 && size+int64(n) > a.maxSize
+}
+
+// rotate starts a new segment when writing n more bytes would exceed maxSize.
+// It reports whether a new segment was started.
+func (a *atomicFile) rotate(n int) (bool, error) {
+	if a.maxSize <= 0 || a.size == 0 || a.size+int64(n) <= a.maxSize {
+		return false, nil
+	}
+
+	next, err := createAtomic(rotatedPath(a.path, len(a.segments)+1))
+	if err != nil {
+		return false, err
+	}
+	next.contentType = a.contentType
+	a.segments = append(a.segments, next)
+	a.size = 0
+	return true, nil
+}
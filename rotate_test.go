@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tt := map[string]struct {
+		in      string
+		want    int64
+		wantErr bool
+	}{
+		"bytes":     {in: "512", want: 512},
+		"kilobytes": {in: "4KB", want: 4 << 10},
+		"megabytes": {in: "100MB", want: 100 << 20},
+		"short":     {in: "1g", want: 1 << 30},
+		"invalid":   {in: "lots", wantErr: true},
+		"negative":  {in: "-1MB", wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseSize(tc.in)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected %d, got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFragmentWrite_rotate(t *testing.T) {
+	tt := map[string]struct {
+		replace bool
+		files   int
+	}{
+		"replace": {replace: true, files: 2},
+		"keep":    {files: 4},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "public.txt")
+			stale := filepath.Join(dir, "public.2.txt")
+			if err := os.WriteFile(stale, []byte("1.1.1.1 old.example.com"), 0o644); err != nil {
+				t.Fatalf("failed to write %q: %v", stale, err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "public.4.txt"), nil, 0o644); err != nil {
+				t.Fatalf("failed to write segment: %v", err)
+			}
+
+			out, err := createAtomic(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			out.maxSize = 44
+			out.replace = tc.replace
+
+			frag := fragment{
+				m: addrMap(map[string][]string{
+					"1.1.1.1": {"a.example.com"},
+					"2.2.2.2": {"b.example.com"},
+					"3.3.3.3": {"c.example.com"},
+				}),
+			}
+			frag.out = frag.writerSink(out)
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := out.Commit(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := map[string]string{
+				"public.txt":   "1.1.1.1 a.example.com\n2.2.2.2 b.example.com",
+				"public.1.txt": "3.3.3.3 c.example.com",
+			}
+			for name, content := range want {
+				got, err := os.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(got) != content {
+					t.Errorf("expected %s to be %q, got %q", name, content, got)
+				}
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != tc.files {
+				t.Errorf("expected %d files, got %d", tc.files, len(entries))
+			}
+		})
+	}
+}
+
+func TestExistingSegments(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"public.txt", "public.10.txt", "public.2.txt", "public.old.txt", "public.0.txt", "public.+3.txt", "public.2.csv", "private.1.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("failed to write %q: %v", name, err)
+		}
+	}
+
+	got, err := existingSegments(filepath.Join(dir, "public.txt"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []segment{
+		{n: 2, path: filepath.Join(dir, "public.2.txt")},
+		{n: 10, path: filepath.Join(dir, "public.10.txt")},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestAtomicFileFull(t *testing.T) {
+	tt := map[string]struct {
+		maxSize  int64
+		size     int64
+		buffered int
+		n        int
+		want     bool
+	}{
+		"no limit":       {size: 100, n: 100},
+		"empty segment":  {maxSize: 10, n: 100},
+		"fits":           {maxSize: 10, size: 4, n: 6},
+		"written":        {maxSize: 10, size: 4, n: 7, want: true},
+		"buffered":       {maxSize: 10, buffered: 4, n: 7, want: true},
+		"buffered fits":  {maxSize: 10, size: 2, buffered: 2, n: 6},
+		"written+buffer": {maxSize: 10, size: 2, buffered: 2, n: 7, want: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			a := &atomicFile{maxSize: tc.maxSize, size: tc.size}
+			if got := a.full(tc.buffered, tc.n); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
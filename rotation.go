@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strings"
+)
+
+// sampleIPs repeats the lookup of subdomain samples times and returns the
+// union of the answers, so that every address of a round-robin pool shows
+// up. It fails only when the first lookup fails.
+func sampleIPs(subdomain string, samples int) ([]net.IP, error) {
+	ips, err := lookupIP(subdomain)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 1; i < samples; i++ {
+		more, err := lookupIP(subdomain)
+		if err != nil {
+			break
+		}
+		for _, ip := range more {
+			if !slices.ContainsFunc(ips, ip.Equal) {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips, nil
+}
+
+type rotationReport struct {
+	hosts *hostReport
+}
+
+func newRotationReport() *rotationReport {
+	return &rotationReport{hosts: newHostReport()}
+}
+
+func (r *rotationReport) add(class string, ip string, subdomain string) {
+	r.hosts.add(class, ip, subdomain)
+}
+
+func (r *rotationReport) write(out io.Writer) error {
+	hosts := make([]string, 0, len(r.hosts.hosts))
+	for host := range r.hosts.hosts {
+		hosts = append(hosts, host)
+	}
+	slices.Sort(hosts)
+
+	w := bufio.NewWriter(out)
+	for _, host := range hosts {
+		ips := r.hosts.hosts[host].ips
+		sortIPs(ips, sortNumeric)
+		fmt.Fprintf(w, "%s %d %s\n", host, len(ips), strings.Join(ips, ","))
+	}
+	return w.Flush()
+}
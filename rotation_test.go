@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestSampleIPs(t *testing.T) {
+	pool := []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}
+	tt := map[string]struct {
+		samples int
+		failAt  int
+		want    int
+		err     bool
+	}{
+		"single":       {samples: 1, want: 1},
+		"rotation":     {samples: 4, want: 3},
+		"later error":  {samples: 3, failAt: 2, want: 1},
+		"first error":  {samples: 3, failAt: 1, err: true},
+		"zero samples": {samples: 0, want: 1},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			lookupIP = func(host string) ([]net.IP, error) {
+				calls++
+				if calls == tc.failAt {
+					return nil, fmt.Errorf("timeout")
+				}
+				return []net.IP{net.ParseIP(pool[(calls-1)%len(pool)])}, nil
+			}
+			defer func() { lookupIP = net.LookupIP }()
+
+			ips, err := sampleIPs("www.example.com", tc.samples)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error %v, got %v", tc.err, err)
+			}
+			if len(ips) != tc.want {
+				t.Errorf("expected %d addresses, got %v", tc.want, ips)
+			}
+		})
+	}
+}
+
+func TestRotationReport(t *testing.T) {
+	r := newRotationReport()
+	r.add(classPublic, "10.0.0.2", "www.example.com")
+	r.add(classPublic, "10.0.0.10", "www.example.com")
+	r.add(classPublic, "10.0.0.2", "www.example.com")
+	r.add(classPublic, "10.0.0.3", "api.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "api.example.com 1 10.0.0.3\nwww.example.com 2 10.0.0.2,10.0.0.10\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
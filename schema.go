@@ -0,0 +1,101 @@
+package main
+
+import "time"
+
+// schemaVersion is written as schema_version in every JSON document. It is
+// only incremented when a field is renamed, removed or changes type; new
+// optional fields keep the version.
+const schemaVersion = 1
+
+// resultRecord is a record document as written by -format json, -out-kafka
+// and -out-elastic.
+type resultRecord struct {
+	SchemaVersion int       `json:"schema_version"`
+	Subdomain     string    `json:"subdomain"`
+	IP            string    `json:"ip"`
+	Class         string    `json:"class"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// outputSchema is the JSON Schema of the documents, printed by -print-schema.
+const outputSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "https://github.com/bountyhub-org/ipsubmap/schema/v1.json",
+  "title": "ipsubmap output",
+  "description": "JSON documents written by ipsubmap, schema_version 1",
+  "$defs": {
+    "schema_version": {
+      "const": 1
+    },
+    "class": {
+      "enum": ["private", "public", "loopback"]
+    },
+    "record": {
+      "description": "One resolved subdomain and ip address: -out-kafka messages, -out-elastic documents, -out-splunk events, -format json lines and -format msgpack maps",
+      "type": "object",
+      "required": ["schema_version", "subdomain", "ip", "class"],
+      "properties": {
+        "schema_version": {"$ref": "#/$defs/schema_version"},
+        "subdomain": {"type": "string"},
+        "ip": {"type": "string"},
+        "class": {"$ref": "#/$defs/class"},
+        "timestamp": {"type": "string", "format": "date-time", "description": "-out-kafka, -out-elastic, -format json and -format msgpack only"}
+      }
+    },
+    "error": {
+      "description": "One failed lookup: -errors-out lines",
+      "type": "object",
+      "required": ["schema_version", "hostname", "category", "error", "timestamp"],
+      "properties": {
+        "schema_version": {"$ref": "#/$defs/schema_version"},
+        "hostname": {"type": "string"},
+        "category": {"enum": ["not_found", "servfail", "server_error", "timeout", "temporary", "no_addresses", "other"]},
+        "resolver": {"type": "string"},
+        "error": {"type": "string"},
+        "timestamp": {"type": "string", "format": "date-time"}
+      }
+    },
+    "finding": {
+      "type": "object",
+      "required": ["ip", "subdomains"],
+      "properties": {
+        "ip": {"type": "string"},
+        "class": {"$ref": "#/$defs/class"},
+        "subdomains": {"type": "array", "items": {"type": "string"}}
+      }
+    },
+    "webhook": {
+      "description": "The -webhook run summary",
+      "type": "object",
+      "required": ["schema_version", "event", "version", "classes", "errors", "dropped"],
+      "properties": {
+        "schema_version": {"$ref": "#/$defs/schema_version"},
+        "event": {"const": "run_completed"},
+        "version": {"type": "string"},
+        "input": {"type": "string"},
+        "started_at": {"type": "string", "format": "date-time"},
+        "finished_at": {"type": "string", "format": "date-time"},
+        "classes": {
+          "type": "object",
+          "additionalProperties": {
+            "type": "object",
+            "properties": {
+              "ips": {"type": "integer"},
+              "subdomains": {"type": "integer"}
+            }
+          }
+        },
+        "errors": {"type": "object", "additionalProperties": {"type": "integer"}},
+        "dropped": {"type": "object", "additionalProperties": {"type": "integer"}},
+        "new": {"type": "array", "items": {"$ref": "#/$defs/finding"}},
+        "gone": {"type": "array", "items": {"$ref": "#/$defs/finding"}}
+      }
+    }
+  },
+  "oneOf": [
+    {"$ref": "#/$defs/record"},
+    {"$ref": "#/$defs/error"},
+    {"$ref": "#/$defs/webhook"}
+  ]
+}
+`
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestOutputSchema(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Const      any                        `json:"const"`
+			Required   []string                   `json:"required"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal([]byte(outputSchema), &schema); err != nil {
+		t.Fatalf("invalid schema: %v", err)
+	}
+	if got := schema.Defs["schema_version"].Const; got != float64(schemaVersion) {
+		t.Fatalf("expected schema_version %d, got %v", schemaVersion, got)
+	}
+
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tt := map[string]struct {
+		def string
+		doc any
+	}{
+		"record":  {def: "record", doc: resultRecord{SchemaVersion: schemaVersion, Timestamp: now}},
+		"splunk":  {def: "record", doc: splunkFinding{SchemaVersion: schemaVersion}},
+		"error":   {def: "error", doc: errorEntry{SchemaVersion: schemaVersion, Timestamp: now}},
+		"webhook": {def: "webhook", doc: newWebhookPayload(summaryView{}, false)},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			def := schema.Defs[tc.def]
+			b, err := json.Marshal(tc.doc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var doc map[string]any
+			if err := json.Unmarshal(b, &doc); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			for _, field := range def.Required {
+				if _, ok := doc[field]; !ok {
+					t.Errorf("required field %q missing from %s", field, b)
+				}
+			}
+			for field := range doc {
+				if _, ok := def.Properties[field]; !ok {
+					t.Errorf("field %q not in the %s schema", field, tc.def)
+				}
+			}
+		})
+	}
+}
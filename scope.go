@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+type scope struct {
+	includeHosts []string
+	excludeHosts []string
+	includeNets  []netip.Prefix
+	excludeNets  []netip.Prefix
+}
+
+const (
+	scopeFormatText     = "text"
+	scopeFormatH1       = "hackerone"
+	scopeFormatBugcrowd = "bugcrowd"
+)
+
+func (s *scope) loadFile(path string, format string) (skipped []string, err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	switch format {
+	case scopeFormatH1:
+		skipped, err = s.loadHackerOne(in)
+	case scopeFormatBugcrowd:
+		skipped, err = s.loadBugcrowd(in)
+	default:
+		err = s.load(in)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scope %q: %v", path, err)
+	}
+	return skipped, nil
+}
+
+func (s *scope) load(in io.Reader) error {
+	return scanLines(in, func(n int, line string) error {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			return nil
+		}
+
+		exclude := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+		if err := s.addEntry(line, exclude); err != nil {
+			return fmt.Errorf("line %d: %v", n, err)
+		}
+		return nil
+	})
+}
+
+func (s *scope) addEntry(entry string, exclude bool) error {
+	if prefix, ok := parsePrefix(entry); ok {
+		if exclude {
+			s.excludeNets = append(s.excludeNets, prefix)
+		} else {
+			s.includeNets = append(s.includeNets, prefix)
+		}
+		return nil
+	}
+
+	host := normalizeHost(entry)
+	if host == "" || host == "*." || strings.Contains(strings.TrimPrefix(host, "*."), "*") {
+		return fmt.Errorf("invalid scope entry %q", entry)
+	}
+	if exclude {
+		s.excludeHosts = append(s.excludeHosts, host)
+	} else {
+		s.includeHosts = append(s.includeHosts, host)
+	}
+	return nil
+}
+
+func parsePrefix(s string) (netip.Prefix, bool) {
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), true
+	}
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), true
+	}
+	return netip.Prefix{}, false
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
+
+func (s *scope) hostInScope(host string) (bool, string) {
+	host = normalizeHost(host)
+	for _, pattern := range s.excludeHosts {
+		if matchHost(pattern, host) {
+			return false, fmt.Sprintf("host excluded by %q", pattern)
+		}
+	}
+
+	if len(s.includeHosts) == 0 {
+		return true, ""
+	}
+	for _, pattern := range s.includeHosts {
+		if matchHost(pattern, host) {
+			return true, ""
+		}
+	}
+	return false, "host not in scope"
+}
+
+func (s *scope) ipInScope(addr netip.Addr) (bool, string) {
+	addr = addr.Unmap()
+
+	for _, prefix := range s.excludeNets {
+		if prefix.Contains(addr) {
+			return false, fmt.Sprintf("address excluded by %s", prefix)
+		}
+	}
+
+	if len(s.includeNets) == 0 {
+		return true, ""
+	}
+	for _, prefix := range s.includeNets {
+		if prefix.Contains(addr) {
+			return true, ""
+		}
+	}
+	return false, "address not in scope"
+}
+
+func matchHost(pattern string, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+type h1Asset struct {
+	AssetIdentifier       string `json:"asset_identifier"`
+	AssetType             string `json:"asset_type"`
+	EligibleForSubmission *bool  `json:"eligible_for_submission"`
+}
+
+// loadHackerOne accepts both the structured scopes API response and the
+// {"targets": {"in_scope": [...], "out_of_scope": [...]}} program export.
+func (s *scope) loadHackerOne(in io.Reader) ([]string, error) {
+	var doc struct {
+		Data []struct {
+			Attributes h1Asset `json:"attributes"`
+		} `json:"data"`
+		Targets struct {
+			InScope    []h1Asset `json:"in_scope"`
+			OutOfScope []h1Asset `json:"out_of_scope"`
+		} `json:"targets"`
+	}
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var skipped []string
+	add := func(asset h1Asset, exclude bool) {
+		if !slices.Contains([]string{"URL", "WILDCARD", "DOMAIN", "CIDR", "IP_ADDRESS"}, asset.AssetType) {
+			skipped = append(skipped, asset.AssetIdentifier)
+			return
+		}
+		skipped = append(skipped, s.addAsset(asset.AssetIdentifier, exclude)...)
+	}
+
+	for _, d := range doc.Data {
+		exclude := d.Attributes.EligibleForSubmission != nil && !*d.Attributes.EligibleForSubmission
+		add(d.Attributes, exclude)
+	}
+	for _, asset := range doc.Targets.InScope {
+		add(asset, false)
+	}
+	for _, asset := range doc.Targets.OutOfScope {
+		add(asset, true)
+	}
+
+	return skipped, nil
+}
+
+type bugcrowdTarget struct {
+	Type   string `json:"type"`
+	Target string `json:"target"`
+	URI    string `json:"uri"`
+}
+
+func (s *scope) loadBugcrowd(in io.Reader) ([]string, error) {
+	var doc struct {
+		Targets struct {
+			InScope    []bugcrowdTarget `json:"in_scope"`
+			OutOfScope []bugcrowdTarget `json:"out_of_scope"`
+		} `json:"targets"`
+	}
+	if err := json.NewDecoder(in).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	var skipped []string
+	add := func(target bugcrowdTarget, exclude bool) {
+		if !slices.Contains([]string{"website", "api", "ip_address", "network"}, target.Type) {
+			skipped = append(skipped, target.Target)
+			return
+		}
+		asset := target.Target
+		if target.URI != "" {
+			asset = target.URI
+		}
+		skipped = append(skipped, s.addAsset(asset, exclude)...)
+	}
+
+	for _, target := range doc.Targets.InScope {
+		add(target, false)
+	}
+	for _, target := range doc.Targets.OutOfScope {
+		add(target, true)
+	}
+
+	return skipped, nil
+}
+
+func (s *scope) addAsset(asset string, exclude bool) (skipped []string) {
+	for _, entry := range strings.Split(asset, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, rest, ok := strings.Cut(entry, "://"); ok {
+			if u, err := url.Parse(entry); err == nil && u.Hostname() != "" {
+				entry = u.Hostname()
+			} else {
+				entry, _, _ = strings.Cut(rest, "/")
+			}
+		}
+		if host, _, err := net.SplitHostPort(entry); err == nil {
+			entry = host
+		}
+		if strings.HasPrefix(entry, "*") && !strings.HasPrefix(entry, "*.") {
+			entry = "*." + strings.TrimPrefix(entry, "*")
+		}
+		if err := s.addEntry(entry, exclude); err != nil {
+			skipped = append(skipped, entry)
+		}
+	}
+	return skipped
+}
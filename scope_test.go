@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestScope(t *testing.T) {
+	s := &scope{}
+	in := strings.Join([]string{
+		"# program scope",
+		"*.example.com",
+		"example.org",
+		"!admin.example.com",
+		"10.0.0.0/8",
+		"!10.1.0.0/16",
+		"192.0.2.1",
+	}, "\n")
+	if err := s.load(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hosts := map[string]bool{
+		"www.example.com":   true,
+		"WWW.Example.com.":  true,
+		"example.com":       false,
+		"example.org":       true,
+		"www.example.org":   false,
+		"admin.example.com": false,
+		"example.net":       false,
+	}
+	for host, want := range hosts {
+		if got, _ := s.hostInScope(host); got != want {
+			t.Errorf("hostInScope(%q): expected %v, got %v", host, want, got)
+		}
+	}
+
+	ips := map[string]bool{
+		"10.0.0.1":          true,
+		"10.1.2.3":          false,
+		"192.0.2.1":         true,
+		"192.0.2.2":         false,
+		"::ffff:10.0.0.1":   true,
+		"2001:db8::1":       false,
+		"1.1.1.1":           false,
+		"::ffff:192.0.2.1":  true,
+		"::ffff:192.0.2.10": false,
+	}
+	for ip, want := range ips {
+		if got, _ := s.ipInScope(netip.MustParseAddr(ip)); got != want {
+			t.Errorf("ipInScope(%q): expected %v, got %v", ip, want, got)
+		}
+	}
+}
+
+func TestScope_invalid(t *testing.T) {
+	for _, entry := range []string{"*.", "foo.*.example.com", "!"} {
+		s := &scope{}
+		if err := s.load(strings.NewReader(entry)); err == nil {
+			t.Errorf("expected error for %q", entry)
+		}
+	}
+}
+
+func TestScope_loadHackerOne(t *testing.T) {
+	in := `{
+		"data": [
+			{"type": "structured-scope", "attributes": {"asset_identifier": "*.example.com", "asset_type": "WILDCARD", "eligible_for_submission": true}},
+			{"type": "structured-scope", "attributes": {"asset_identifier": "https://app.example.org/login", "asset_type": "URL", "eligible_for_submission": true}},
+			{"type": "structured-scope", "attributes": {"asset_identifier": "blog.example.com", "asset_type": "URL", "eligible_for_submission": false}},
+			{"type": "structured-scope", "attributes": {"asset_identifier": "com.example.app", "asset_type": "GOOGLE_PLAY_APP_ID", "eligible_for_submission": true}}
+		]
+	}`
+
+	s := &scope{}
+	skipped, err := s.loadHackerOne(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(skipped) != 1 || skipped[0] != "com.example.app" {
+		t.Errorf("expected app id to be skipped, got %v", skipped)
+	}
+
+	hosts := map[string]bool{
+		"www.example.com":  true,
+		"blog.example.com": false,
+		"app.example.org":  true,
+		"com.example.app":  false,
+	}
+	for host, want := range hosts {
+		if got, _ := s.hostInScope(host); got != want {
+			t.Errorf("hostInScope(%q): expected %v, got %v", host, want, got)
+		}
+	}
+}
+
+func TestScope_loadBugcrowd(t *testing.T) {
+	in := `{
+		"name": "Example",
+		"targets": {
+			"in_scope": [
+				{"type": "website", "target": "*.example.com"},
+				{"type": "api", "target": "api", "uri": "https://api.example.net:8443/v1"},
+				{"type": "network", "target": "203.0.113.0/24"}
+			],
+			"out_of_scope": [
+				{"type": "website", "target": "https://status.example.com"}
+			]
+		}
+	}`
+
+	s := &scope{}
+	if _, err := s.loadBugcrowd(strings.NewReader(in)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	hosts := map[string]bool{
+		"www.example.com":    true,
+		"status.example.com": false,
+		"api.example.net":    true,
+	}
+	for host, want := range hosts {
+		if got, _ := s.hostInScope(host); got != want {
+			t.Errorf("hostInScope(%q): expected %v, got %v", host, want, got)
+		}
+	}
+
+	if ok, _ := s.ipInScope(netip.MustParseAddr("203.0.113.7")); !ok {
+		t.Error("expected network target to be in scope")
+	}
+}
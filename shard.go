@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+)
+
+func shardPaths(path string, shards int) []string {
+	if path == "" || shards <= 1 {
+		return []string{path}
+	}
+
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	paths := make([]string, shards)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("%s.shard%d%s", base, i, ext)
+	}
+	return paths
+}
+
+func shardOf(key string, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// shardSink splits records over sinks by shardOf their key.
+type shardSink []sink
+
+func (s shardSink) write(r record) error {
+	return s[shardOf(r.key, len(s))].write(r)
+}
+
+func (s shardSink) close() error {
+	for _, w := range s {
+		if err := w.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestShardPaths(t *testing.T) {
+	tt := map[string]struct {
+		path   string
+		shards int
+		want   []string
+	}{
+		"unsharded": {
+			path:   "public.txt",
+			shards: 1,
+			want:   []string{"public.txt"},
+		},
+		"sharded": {
+			path:   "out/public.txt",
+			shards: 3,
+			want:   []string{"out/public.shard0.txt", "out/public.shard1.txt", "out/public.shard2.txt"},
+		},
+		"empty": {
+			path:   "",
+			shards: 3,
+			want:   []string{""},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got := shardPaths(tc.path, tc.shards)
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFragmentWrite_shards(t *testing.T) {
+	m := map[string][]string{
+		"1.1.1.1": {"a.example.com"},
+		"2.2.2.2": {"b.example.com"},
+		"3.3.3.3": {"c.example.com"},
+		"4.4.4.4": {"d.example.com"},
+		"5.5.5.5": {"e.example.com"},
+	}
+
+	bufs := []*bytes.Buffer{{}, {}}
+	frag := fragment{m: addrMap(m)}
+	frag.out = frag.writerSink(bufs[0], bufs[1])
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	loaded := fragment{m: make(map[netip.Addr][]string)}
+	for i, buf := range bufs {
+		shard := fragment{m: make(map[netip.Addr][]string)}
+		if err := shard.load(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("unexpected load error: %v", err)
+		}
+		for ip := range shard.m {
+			if got := shardOf(formatIP(ip), len(bufs)); got != i {
+				t.Errorf("expected %s in shard %d, found in shard %d", ip, got, i)
+			}
+		}
+		if err := loaded.load(bytes.NewReader(buf.Bytes())); err != nil {
+			t.Fatalf("unexpected load error: %v", err)
+		}
+	}
+
+	if len(loaded.m) != len(m) {
+		t.Errorf("expected %d entries across shards, got %d", len(m), len(loaded.m))
+	}
+}
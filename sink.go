@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// stdoutPath selects standard output in place of a per-class output file.
+const stdoutPath = "-"
+
+// record is one output entry: an ip address and its subdomains, or a
+// subdomain and its ip addresses with -sort subdomain.
+type record struct {
+	key    string
+	values []string
+}
+
+// sink receives the records of a fragment in output order. close is called
+// once after the last record and must flush anything still buffered.
+type sink interface {
+	write(r record) error
+	close() error
+}
+
+// bufferSink keeps records in memory.
+type bufferSink struct {
+	records []record
+	closed  bool
+}
+
+func (b *bufferSink) write(r record) error {
+	b.records = append(b.records, record{key: r.key, values: append([]string(nil), r.values...)})
+	return nil
+}
+
+func (b *bufferSink) close() error {
+	b.closed = true
+	return nil
+}
+
+// stdoutSink writes a per-class output to standard output. Text output gets
+// a final newline so the shell prompt does not follow the last line.
+type stdoutSink struct {
+	sink
+	out     io.Writer
+	newline bool
+	written bool
+}
+
+func (f *fragment) stdoutSink(out io.Writer) sink {
+	return &stdoutSink{
+		sink:    f.writerSink(out),
+		out:     out,
+		newline: !f.format.binary() && !f.format.inventory(),
+	}
+}
+
+func (s *stdoutSink) write(r record) error {
+	s.written = true
+	return s.sink.write(r)
+}
+
+func (s *stdoutSink) close() error {
+	if err := s.sink.close(); err != nil {
+		return err
+	}
+	if !s.newline || !s.written {
+		return nil
+	}
+	_, err := io.WriteString(s.out, "\n")
+	return err
+}
+
+// inventorySink collects every record before writing them as one inventory
+// document on close.
+type inventorySink struct {
+	out    io.Writer
+	format outputFormat
+	class  string
+	seen   map[string]time.Time
+	m      map[string][]string
+}
+
+func (s *inventorySink) write(r record) error {
+	s.m[r.key] = r.values
+	return nil
+}
+
+func (s *inventorySink) close() error {
+	if len(s.m) == 0 {
+		return nil
+	}
+	return writeInventory(s.out, s.format, s.class, s.m, s.seen)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+func TestFragmentWriteTo_buffer(t *testing.T) {
+	tt := map[string]struct {
+		sortMode sortMode
+		want     []record
+	}{
+		"numeric": {
+			sortMode: sortNumeric,
+			want: []record{
+				{key: "2.2.2.2", values: []string{"a.example.com", "b.example.com"}},
+				{key: "10.0.0.1", values: []string{"b.example.com"}},
+			},
+		},
+		"subdomain": {
+			sortMode: sortSubdomain,
+			want: []record{
+				{key: "a.example.com", values: []string{"2.2.2.2"}},
+				{key: "b.example.com", values: []string{"2.2.2.2", "10.0.0.1"}},
+			},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			frag := fragment{
+				m: addrMap(map[string][]string{
+					"10.0.0.1": {"b.example.com"},
+					"2.2.2.2":  {"a.example.com", "b.example.com"},
+				}),
+				sortMode: tc.sortMode,
+			}
+			buf := &bufferSink{}
+			if err := frag.writeTo(buf); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !buf.closed {
+				t.Errorf("expected sink to be closed")
+			}
+			if !reflect.DeepEqual(buf.records, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, buf.records)
+			}
+		})
+	}
+}
+
+func TestStdoutSink(t *testing.T) {
+	tt := map[string]struct {
+		format outputFormat
+		m      map[string][]string
+		want   string
+	}{
+		"list": {
+			format: formatList,
+			m:      map[string][]string{"1.1.1.1": {"a.example.com"}, "2.2.2.2": {"b.example.com"}},
+			want:   "1.1.1.1 a.example.com\n2.2.2.2 b.example.com\n",
+		},
+		"empty": {
+			format: formatList,
+			m:      map[string][]string{},
+			want:   "",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			frag := fragment{m: addrMap(tc.m), format: tc.format, ipSep: " "}
+			frag.out = frag.stdoutSink(out)
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInventorySink(t *testing.T) {
+	out := &bytes.Buffer{}
+	frag := fragment{m: map[netip.Addr][]string{}, class: classPrivate, format: formatAnsible}
+	frag.out = frag.writerSink(out)
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("expected no inventory for an empty fragment, got %q", out.String())
+	}
+
+	frag.append(netip.MustParseAddr("10.0.0.1"), "a.example.com")
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := &bytes.Buffer{}
+	if err := writeInventory(want, formatAnsible, classPrivate, map[string][]string{"10.0.0.1": {"a.example.com"}}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != want.String() {
+		t.Errorf("expected %q, got %q", want.String(), out.String())
+	}
+}
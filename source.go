@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// interfaceAddr returns the first address of the named interface, preferring
+// ipv4 unless only ipv6 lookups are made.
+func interfaceAddr(name string, ipv6 bool) (net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, err
+	}
+
+	var fallback net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if (ipnet.IP.To4() == nil) == ipv6 {
+			return ipnet.IP, nil
+		}
+		if fallback == nil {
+			fallback = ipnet.IP
+		}
+	}
+	if fallback == nil {
+		return nil, fmt.Errorf("interface %s has no usable address", name)
+	}
+	return fallback, nil
+}
+
+// sourceDialer returns a dial function connecting from ip, or from any
+// address when ip is nil.
+func sourceDialer(ip net.IP) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		if ip != nil {
+			switch network {
+			case "udp", "udp4", "udp6":
+				d.LocalAddr = &net.UDPAddr{IP: ip}
+			default:
+				d.LocalAddr = &net.TCPAddr{IP: ip}
+			}
+		}
+		return d.DialContext(ctx, network, address)
+	}
+}
+
+// sourceResolver returns a resolver sending its queries from ip.
+func sourceResolver(ip net.IP) *net.Resolver {
+	return &net.Resolver{PreferGo: true, Dial: sourceDialer(ip)}
+}
+
+// bindSource makes all lookups egress from ip.
+func bindSource(ip net.IP) {
+	r := sourceResolver(ip)
+	lookupIP = func(host string) ([]net.IP, error) {
+		addrs, err := r.LookupIPAddr(context.Background(), host)
+		if err != nil {
+			return nil, err
+		}
+		ips := make([]net.IP, len(addrs))
+		for i, addr := range addrs {
+			ips[i] = addr.IP
+		}
+		return ips, nil
+	}
+	lookupAddr = func(addr string) ([]string, error) {
+		return r.LookupAddr(context.Background(), addr)
+	}
+}
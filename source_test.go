@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestInterfaceAddr(t *testing.T) {
+	ip, err := interfaceAddr("lo", false)
+	if err != nil {
+		t.Skipf("no loopback interface: %v", err)
+	}
+	if !ip.IsLoopback() {
+		t.Errorf("expected a loopback address, got %s", ip)
+	}
+
+	if _, err := interfaceAddr("ipsubmap-missing0", false); err == nil {
+		t.Error("expected error for missing interface")
+	}
+}
+
+func TestSourceResolver(t *testing.T) {
+	tt := map[string]func() (net.Addr, func(), error){
+		"udp": func() (net.Addr, func(), error) {
+			c, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				return nil, nil, err
+			}
+			return c.LocalAddr(), func() { c.Close() }, nil
+		},
+		"tcp": func() (net.Addr, func(), error) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				return nil, nil, err
+			}
+			return l.Addr(), func() { l.Close() }, nil
+		},
+	}
+
+	for network, listen := range tt {
+		t.Run(network, func(t *testing.T) {
+			addr, stop, err := listen()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer stop()
+
+			r := sourceResolver(net.ParseIP("127.0.0.1"))
+			conn, err := r.Dial(context.Background(), network, addr.String())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer conn.Close()
+
+			host, _, _ := net.SplitHostPort(conn.LocalAddr().String())
+			if host != "127.0.0.1" {
+				t.Errorf("expected queries from 127.0.0.1, got %s", host)
+			}
+		})
+	}
+}
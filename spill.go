@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+const defaultSpillChunkSize = 1 << 20
+
+type spill struct {
+	dir       string
+	f         *os.File
+	w         *bufio.Writer
+	err       error
+	chunkSize int
+	sortMode  sortMode
+}
+
+func newSpill(dir string, mode sortMode) (*spill, error) {
+	f, err := os.CreateTemp(dir, "ipsubmap-spill-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %v", err)
+	}
+
+	return &spill{
+		dir:       dir,
+		f:         f,
+		w:         bufio.NewWriter(f),
+		chunkSize: defaultSpillChunkSize,
+		sortMode:  mode,
+	}, nil
+}
+
+func (s *spill) add(ip string, subdomain string) {
+	if s.err != nil {
+		return
+	}
+
+	key, value := ip, subdomain
+	keyMode, valueMode := s.sortMode, sortLexical
+	if s.sortMode == sortSubdomain {
+		key, value = subdomain, ip
+		keyMode, valueMode = sortLexical, sortNumeric
+	}
+
+	record := strings.Join([]string{sortKey(key, keyMode), key, sortKey(value, valueMode), value}, "\t")
+	if _, err := s.w.WriteString(record + "\n"); err != nil {
+		s.err = fmt.Errorf("failed to write to spill file: %v", err)
+	}
+}
+
+func (s *spill) each(fn func(key string, values []string) error) error {
+	if s.err != nil {
+		return s.err
+	}
+	if err := s.w.Flush(); err != nil {
+		return fmt.Errorf("failed to flush spill file: %v", err)
+	}
+
+	in, err := os.Open(s.f.Name())
+	if err != nil {
+		return fmt.Errorf("failed to open spill file: %v", err)
+	}
+	defer in.Close()
+
+	records, cleanup, err := sortRecords(in, s.dir, s.chunkSize)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var (
+		current string
+		values  []string
+	)
+	for {
+		record, err := records()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		fields := strings.SplitN(record, "\t", 4)
+		if len(fields) != 4 {
+			return fmt.Errorf("malformed spill record %q", record)
+		}
+		key, value := fields[1], fields[3]
+		if key != current && values != nil {
+			if err := fn(current, values); err != nil {
+				return err
+			}
+			values = nil
+		}
+		current = key
+		if len(values) > 0 && values[len(values)-1] == value {
+			continue
+		}
+		values = append(values, value)
+	}
+
+	if values != nil {
+		return fn(current, values)
+	}
+
+	return nil
+}
+
+func (s *spill) Close() error {
+	s.f.Close()
+	return os.Remove(s.f.Name())
+}
+
+// sortRecords sorts newline separated records from in, spilling sorted chunks
+// of at most chunkSize records to dir and merging them back together.
+func sortRecords(in io.Reader, dir string, chunkSize int) (func() (string, error), func(), error) {
+	scanner := bufio.NewScanner(in)
+	var (
+		chunk  []string
+		chunks []string
+	)
+	cleanup := func() {
+		for _, name := range chunks {
+			os.Remove(name)
+		}
+	}
+
+	for scanner.Scan() {
+		chunk = append(chunk, scanner.Text())
+		if len(chunk) < chunkSize {
+			continue
+		}
+		name, err := writeChunk(dir, chunk)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		chunks = append(chunks, name)
+		chunk = chunk[:0]
+	}
+	if err := scanner.Err(); err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to read records: %v", err)
+	}
+
+	if len(chunks) == 0 {
+		sort.Strings(chunk)
+		next := func() (string, error) {
+			if len(chunk) == 0 {
+				return "", io.EOF
+			}
+			record := chunk[0]
+			chunk = chunk[1:]
+			return record, nil
+		}
+		return next, cleanup, nil
+	}
+
+	if len(chunk) > 0 {
+		name, err := writeChunk(dir, chunk)
+		if err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		chunks = append(chunks, name)
+	}
+
+	h := &mergeHeap{}
+	var files []*os.File
+	closeAll := func() {
+		for _, f := range files {
+			f.Close()
+		}
+		cleanup()
+	}
+	for _, name := range chunks {
+		f, err := os.Open(name)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to open chunk file: %v", err)
+		}
+		files = append(files, f)
+
+		sc := bufio.NewScanner(f)
+		if sc.Scan() {
+			h.items = append(h.items, mergeItem{record: sc.Text(), scanner: sc})
+		} else if err := sc.Err(); err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("failed to read chunk file: %v", err)
+		}
+	}
+	heap.Init(h)
+
+	next := func() (string, error) {
+		if h.Len() == 0 {
+			return "", io.EOF
+		}
+		top := &h.items[0]
+		record := top.record
+		if top.scanner.Scan() {
+			top.record = top.scanner.Text()
+			heap.Fix(h, 0)
+		} else {
+			if err := top.scanner.Err(); err != nil {
+				return "", fmt.Errorf("failed to read chunk file: %v", err)
+			}
+			heap.Pop(h)
+		}
+		return record, nil
+	}
+
+	return next, closeAll, nil
+}
+
+func writeChunk(dir string, chunk []string) (string, error) {
+	sort.Strings(chunk)
+
+	f, err := os.CreateTemp(dir, "ipsubmap-chunk-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create chunk file: %v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	for _, record := range chunk {
+		if _, err := w.WriteString(record + "\n"); err != nil {
+			os.Remove(f.Name())
+			return "", fmt.Errorf("failed to write chunk file: %v", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write chunk file: %v", err)
+	}
+
+	return f.Name(), nil
+}
+
+type mergeItem struct {
+	record  string
+	scanner *bufio.Scanner
+}
+
+type mergeHeap struct {
+	items []mergeItem
+}
+
+func (h *mergeHeap) Len() int           { return len(h.items) }
+func (h *mergeHeap) Less(i, j int) bool { return h.items[i].record < h.items[j].record }
+func (h *mergeHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *mergeHeap) Push(x any)         { h.items = append(h.items, x.(mergeItem)) }
+func (h *mergeHeap) Pop() any {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
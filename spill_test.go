@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"testing"
+)
+
+func TestSpillFragmentWrite(t *testing.T) {
+	for name, chunkSize := range map[string]int{
+		"in memory": defaultSpillChunkSize,
+		"chunked":   2,
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			sp, err := newSpill(dir, sortLexical)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer sp.Close()
+			sp.chunkSize = chunkSize
+
+			out := &bytes.Buffer{}
+			frag := fragment{spill: sp}
+			frag.out = frag.writerSink(out)
+			frag.append(netip.MustParseAddr("2.2.2.2"), "example.com")
+			frag.append(netip.MustParseAddr("1.1.1.10"), "example.net")
+			frag.append(netip.MustParseAddr("1.1.1.1"), "example.org")
+			frag.append(netip.MustParseAddr("1.1.1.1"), "example.com")
+			frag.append(netip.MustParseAddr("1.1.1.1"), "example.org")
+
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := "1.1.1.1 example.com,example.org\n1.1.1.10 example.net\n2.2.2.2 example.com"
+			if got := out.String(); got != want {
+				t.Errorf("expected %q, got %q", want, got)
+			}
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("expected only the spill file to remain, got %d entries", len(entries))
+			}
+		})
+	}
+}
+
+func TestFragmentWrite_externalSort(t *testing.T) {
+	dir := t.TempDir()
+	out := &bytes.Buffer{}
+	frag := fragment{
+		m: addrMap(map[string][]string{
+			"5.5.5.5": {"e.example.com"},
+			"3.3.3.3": {"c.example.com"},
+			"1.1.1.1": {"a.example.com"},
+			"4.4.4.4": {"d.example.com"},
+			"2.2.2.2": {"b.example.com"},
+		}),
+		sortBudget: 2,
+		sortDir:    dir,
+	}
+	frag.out = frag.writerSink(out)
+
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "1.1.1.1 a.example.com\n2.2.2.2 b.example.com\n3.3.3.3 c.example.com\n4.4.4.4 d.example.com\n5.5.5.5 e.example.com"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected chunk files to be removed, got %d entries", len(entries))
+	}
+}
+
+func TestSpillFragmentWrite_sortSubdomain(t *testing.T) {
+	sp, err := newSpill(t.TempDir(), sortSubdomain)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer sp.Close()
+
+	out := &bytes.Buffer{}
+	frag := fragment{spill: sp}
+	frag.out = frag.writerSink(out)
+	frag.append(netip.MustParseAddr("10.0.0.1"), "b.example.com")
+	frag.append(netip.MustParseAddr("2.2.2.2"), "b.example.com")
+	frag.append(netip.MustParseAddr("2.2.2.2"), "a.example.com")
+
+	if err := frag.write(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "a.example.com 2.2.2.2\nb.example.com 2.2.2.2,10.0.0.1"
+	if got := out.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
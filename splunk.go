@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const splunkBatchSize = 1000
+
+type splunkReport struct {
+	endpoint   string
+	token      string
+	index      string
+	sourcetype string
+	buf        bytes.Buffer
+	pending    int
+	err        error
+	now        func() time.Time
+}
+
+type splunkEvent struct {
+	Time       float64       `json:"time"`
+	Source     string        `json:"source"`
+	Sourcetype string        `json:"sourcetype,omitempty"`
+	Index      string        `json:"index,omitempty"`
+	Event      splunkFinding `json:"event"`
+}
+
+type splunkFinding struct {
+	SchemaVersion int    `json:"schema_version"`
+	Subdomain     string `json:"subdomain"`
+	IP            string `json:"ip"`
+	Class         string `json:"class"`
+}
+
+func newSplunkReport(rawURL, token, index, sourcetype string) (*splunkReport, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid splunk url %q", rawURL)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/services/collector/event"
+	}
+	if token == "" {
+		return nil, fmt.Errorf("splunk HEC token is required")
+	}
+
+	return &splunkReport{
+		endpoint:   u.String(),
+		token:      token,
+		index:      index,
+		sourcetype: sourcetype,
+		now:        time.Now,
+	}, nil
+}
+
+func (r *splunkReport) add(class string, ip string, subdomain string) {
+	if r.err != nil {
+		return
+	}
+
+	event, _ := json.Marshal(splunkEvent{
+		Time:       float64(r.now().UnixMilli()) / 1000,
+		Source:     "ipsubmap",
+		Sourcetype: r.sourcetype,
+		Index:      r.index,
+		Event:      splunkFinding{SchemaVersion: schemaVersion, Subdomain: subdomain, IP: ip, Class: class},
+	})
+	r.buf.Write(event)
+	r.buf.WriteByte('\n')
+	r.pending++
+
+	if r.pending >= splunkBatchSize {
+		r.err = r.flush()
+	}
+}
+
+func (r *splunkReport) flush() error {
+	if r.pending == 0 {
+		return nil
+	}
+	defer func() {
+		r.buf.Reset()
+		r.pending = 0
+	}()
+
+	req, err := http.NewRequest(http.MethodPost, r.endpoint, bytes.NewReader(r.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Splunk "+r.token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("HEC request failed: %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+func (r *splunkReport) write(_ io.Writer) error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.flush()
+}
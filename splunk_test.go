@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewSplunkReport(t *testing.T) {
+	tt := map[string]struct {
+		url          string
+		token        string
+		wantEndpoint string
+		wantErr      bool
+	}{
+		"default path": {url: "https://splunk:8088", token: "t", wantEndpoint: "https://splunk:8088/services/collector/event"},
+		"custom path":  {url: "https://splunk:8088/services/collector", token: "t", wantEndpoint: "https://splunk:8088/services/collector"},
+		"no token":     {url: "https://splunk:8088", wantErr: true},
+		"invalid url":  {url: "splunk:8088", token: "t", wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			r, err := newSplunkReport(tc.url, tc.token, "", "")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err == nil && r.endpoint != tc.wantEndpoint {
+				t.Errorf("expected %s, got %s", tc.wantEndpoint, r.endpoint)
+			}
+		})
+	}
+}
+
+func TestSplunkReport(t *testing.T) {
+	var gotAuth, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotAuth, gotBody = r.Header.Get("Authorization"), string(body)
+		io.WriteString(w, `{"text":"Success","code":0}`)
+	}))
+	defer srv.Close()
+
+	r, err := newSplunkReport(srv.URL, "secret", "recon", "ipsubmap")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.now = func() time.Time { return time.Date(2024, 1, 2, 3, 4, 5, 500e6, time.UTC) }
+
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	if err := r.write(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotAuth != "Splunk secret" {
+		t.Errorf("unexpected authorization %q", gotAuth)
+	}
+	want := `{"time":1704164645.5,"source":"ipsubmap","sourcetype":"ipsubmap","index":"recon","event":{"schema_version":1,"subdomain":"a.example.com","ip":"1.1.1.1","class":"public"}}` + "\n"
+	if gotBody != want {
+		t.Errorf("expected %q, got %q", want, gotBody)
+	}
+}
+
+func TestIPSubMapWrite_splunkFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"text":"Invalid token","code":4}`, http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	r, err := newSplunkReport(srv.URL, "secret", "", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ips := newIPReport(sortNumeric)
+	out := &strings.Builder{}
+	m := &ipSubMap{
+		reports: []reportOutput{
+			{name: "unique ip addresses", out: out, report: ips},
+			{name: "splunk", out: io.Discard, sink: true, report: r},
+		},
+	}
+	for _, report := range m.reports {
+		report.add(classPublic, "1.1.1.1", "a.example.com")
+	}
+
+	failed := m.writeSinks()
+	if err := failed["splunk"]; err == nil || !strings.Contains(err.Error(), "Invalid token") || len(failed) != 1 {
+		t.Errorf("expected the splunk sink to fail, got %v", failed)
+	}
+	if err := m.write(); err != nil {
+		t.Fatalf("expected local outputs to be written, got %v", err)
+	}
+	if out.String() != "1.1.1.1" {
+		t.Errorf("unexpected local output %q", out.String())
+	}
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"cmp"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"slices"
+)
+
+const sqlitePageSize = 4096
+
+// sqliteMaxIndexPayload is the largest index entry stored without overflow
+// pages, which sqliteDB does not write.
+const sqliteMaxIndexPayload = (sqlitePageSize-12)*64/255 - 23
+
+// B-tree page types.
+const (
+	sqliteIndexInterior = 0x02
+	sqliteTableInterior = 0x05
+	sqliteIndexLeaf     = 0x0a
+	sqliteTableLeaf     = 0x0d
+)
+
+var sqliteSchema = []struct {
+	typ, name, table, sql string
+}{
+	{"table", "ips", "ips", "CREATE TABLE ips (id INTEGER PRIMARY KEY, ip TEXT NOT NULL, class TEXT NOT NULL)"},
+	{"index", "ips_ip", "ips", "CREATE UNIQUE INDEX ips_ip ON ips (ip)"},
+	{"table", "subdomains", "subdomains", "CREATE TABLE subdomains (id INTEGER PRIMARY KEY, name TEXT NOT NULL)"},
+	{"index", "subdomains_name", "subdomains", "CREATE UNIQUE INDEX subdomains_name ON subdomains (name)"},
+	{"table", "mappings", "mappings", "CREATE TABLE mappings (subdomain_id INTEGER NOT NULL REFERENCES subdomains (id), ip_id INTEGER NOT NULL REFERENCES ips (id))"},
+	{"index", "mappings_subdomain", "mappings", "CREATE UNIQUE INDEX mappings_subdomain ON mappings (subdomain_id, ip_id)"},
+	{"index", "mappings_ip", "mappings", "CREATE INDEX mappings_ip ON mappings (ip_id)"},
+}
+
+// sqliteReport writes the results as a SQLite database with the tables and
+// indexes in sqliteSchema.
+type sqliteReport struct {
+	classes  map[string]string
+	mappings map[[2]string]struct{}
+}
+
+func newSQLiteReport() *sqliteReport {
+	return &sqliteReport{
+		classes:  make(map[string]string),
+		mappings: make(map[[2]string]struct{}),
+	}
+}
+
+func (r *sqliteReport) add(class string, ip string, subdomain string) {
+	r.classes[ip] = class
+	r.mappings[[2]string{subdomain, ip}] = struct{}{}
+}
+
+func (r *sqliteReport) contentType() string {
+	return formatSQLite.contentType()
+}
+
+func (r *sqliteReport) write(out io.Writer) error {
+	ips := make([]string, 0, len(r.classes))
+	for ip := range r.classes {
+		ips = append(ips, ip)
+	}
+	sortIPs(ips, sortNumeric)
+	ipIDs := make(map[string]int64, len(ips))
+	var ipRows [][]byte
+	for i, ip := range ips {
+		ipIDs[ip] = int64(i + 1)
+		ipRows = append(ipRows, sqliteRecord(nil, ip, r.classes[ip]))
+	}
+
+	subIDs := make(map[string]int64)
+	var subdomains []string
+	for m := range r.mappings {
+		if _, ok := subIDs[m[0]]; !ok {
+			subIDs[m[0]] = 0
+			subdomains = append(subdomains, m[0])
+		}
+	}
+	slices.Sort(subdomains)
+	var subRows [][]byte
+	for i, sub := range subdomains {
+		subIDs[sub] = int64(i + 1)
+		subRows = append(subRows, sqliteRecord(nil, sub))
+	}
+
+	mappings := make([][2]int64, 0, len(r.mappings))
+	for m := range r.mappings {
+		mappings = append(mappings, [2]int64{subIDs[m[0]], ipIDs[m[1]]})
+	}
+	slices.SortFunc(mappings, func(a, b [2]int64) int {
+		return cmp.Or(cmp.Compare(a[0], b[0]), cmp.Compare(a[1], b[1]))
+	})
+	var mappingRows [][]byte
+	for _, m := range mappings {
+		mappingRows = append(mappingRows, sqliteRecord(m[0], m[1]))
+	}
+
+	// Index entries are the indexed columns followed by the rowid, in
+	// BINARY collation order.
+	var ipIndex, subIndex, bySubdomain, byIP [][]byte
+	byName := slices.Clone(ips)
+	slices.Sort(byName)
+	for _, ip := range byName {
+		ipIndex = append(ipIndex, sqliteRecord(ip, ipIDs[ip]))
+	}
+	for i, sub := range subdomains {
+		subIndex = append(subIndex, sqliteRecord(sub, int64(i+1)))
+	}
+	for i, m := range mappings {
+		bySubdomain = append(bySubdomain, sqliteRecord(m[0], m[1], int64(i+1)))
+	}
+	order := make([]int, len(mappings))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(mappings[a][1], mappings[b][1])
+	})
+	for _, i := range order {
+		byIP = append(byIP, sqliteRecord(mappings[i][1], int64(i+1)))
+	}
+
+	db := &sqliteDB{pages: [][]byte{nil}}
+	var roots []int64
+	for _, tree := range []struct {
+		rows  [][]byte
+		index bool
+	}{
+		{ipRows, false}, {ipIndex, true},
+		{subRows, false}, {subIndex, true},
+		{mappingRows, false}, {bySubdomain, true}, {byIP, true},
+	} {
+		if !tree.index {
+			roots = append(roots, int64(db.table(tree.rows)))
+			continue
+		}
+		root, err := db.index(tree.rows)
+		if err != nil {
+			return err
+		}
+		roots = append(roots, int64(root))
+	}
+
+	var schema [][]byte
+	for i, s := range sqliteSchema {
+		schema = append(schema, sqliteTableCell(int64(i+1), sqliteRecord(s.typ, s.name, s.table, roots[i], s.sql)))
+	}
+	db.pages[0] = sqlitePage(100, sqliteTableLeaf, schema, 0)
+	copy(db.pages[0], sqliteHeader(len(db.pages)))
+
+	for _, p := range db.pages {
+		if _, err := out.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sqliteHeader(pages int) []byte {
+	h := make([]byte, 100)
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], sqlitePageSize)
+	h[18], h[19] = 1, 1
+	h[21], h[22], h[23] = 64, 32, 32
+	binary.BigEndian.PutUint32(h[24:], 1)
+	binary.BigEndian.PutUint32(h[28:], uint32(pages))
+	binary.BigEndian.PutUint32(h[40:], 1)
+	binary.BigEndian.PutUint32(h[44:], 4)
+	binary.BigEndian.PutUint32(h[56:], 1)
+	binary.BigEndian.PutUint32(h[92:], 1)
+	binary.BigEndian.PutUint32(h[96:], 3045000)
+	return h
+}
+
+// sqliteDB builds the b-trees of a database file bottom up, one page at a
+// time. Page 1, the schema table, is left for the caller.
+type sqliteDB struct {
+	pages [][]byte
+}
+
+func (db *sqliteDB) add(typ byte, cells [][]byte, right uint32) uint32 {
+	db.pages = append(db.pages, sqlitePage(0, typ, cells, right))
+	return uint32(len(db.pages))
+}
+
+// table writes a table b-tree with rows as records with rowids 1..n and
+// returns its root page.
+func (db *sqliteDB) table(rows [][]byte) uint32 {
+	type child struct {
+		page   uint32
+		maxKey int64
+	}
+
+	var level []child
+	var cells [][]byte
+	for i, row := range rows {
+		cell := sqliteTableCell(int64(i+1), row)
+		if !sqliteFits(sqliteTableLeaf, cells, cell) {
+			level = append(level, child{db.add(sqliteTableLeaf, cells, 0), int64(i)})
+			cells = nil
+		}
+		cells = append(cells, cell)
+	}
+	level = append(level, child{db.add(sqliteTableLeaf, cells, 0), int64(len(rows))})
+
+	for len(level) > 1 {
+		var next []child
+		var cells [][]byte
+		var keys []int64
+		pending := level[0]
+		for _, c := range level[1:] {
+			cell := binary.BigEndian.AppendUint32(nil, pending.page)
+			cell = appendSQLiteVarint(cell, uint64(pending.maxKey))
+			if !sqliteFits(sqliteTableInterior, cells, cell) {
+				// The last cell becomes the right child, so no page is
+				// left without cells.
+				last := len(cells) - 1
+				right := binary.BigEndian.Uint32(cells[last])
+				next = append(next, child{db.add(sqliteTableInterior, cells[:last], right), keys[last]})
+				cells, keys = nil, nil
+			}
+			cells = append(cells, cell)
+			keys = append(keys, pending.maxKey)
+			pending = c
+		}
+		next = append(next, child{db.add(sqliteTableInterior, cells, pending.page), pending.maxKey})
+		level = next
+	}
+	return level[0].page
+}
+
+// index writes an index b-tree with the sorted entries and returns its root
+// page. Unlike table b-trees, every entry is stored once: the entries
+// separating two pages move up into their parent.
+func (db *sqliteDB) index(entries [][]byte) (uint32, error) {
+	for _, e := range entries {
+		if len(e) > sqliteMaxIndexPayload {
+			return 0, fmt.Errorf("index entry of %d bytes exceeds the sqlite output limit of %d bytes", len(e), sqliteMaxIndexPayload)
+		}
+	}
+
+	var children []uint32
+	var seps [][]byte
+	var cells [][]byte
+	for i, e := range entries {
+		cell := appendSQLiteVarint(nil, uint64(len(e)))
+		cell = append(cell, e...)
+		if !sqliteFits(sqliteIndexLeaf, cells, cell) {
+			if i < len(entries)-1 {
+				children = append(children, db.add(sqliteIndexLeaf, cells, 0))
+				seps = append(seps, e)
+				cells = nil
+				continue
+			}
+			last := len(cells) - 1
+			children = append(children, db.add(sqliteIndexLeaf, cells[:last], 0))
+			seps = append(seps, entries[i-1])
+			cells = nil
+		}
+		cells = append(cells, cell)
+	}
+	children = append(children, db.add(sqliteIndexLeaf, cells, 0))
+
+	for len(children) > 1 {
+		var nextChildren []uint32
+		var nextSeps [][]byte
+		var cells [][]byte
+		pending := children[0]
+		for i, sep := range seps {
+			cell := binary.BigEndian.AppendUint32(nil, pending)
+			cell = appendSQLiteVarint(cell, uint64(len(sep)))
+			cell = append(cell, sep...)
+			if !sqliteFits(sqliteIndexInterior, cells, cell) {
+				if i < len(seps)-1 {
+					nextChildren = append(nextChildren, db.add(sqliteIndexInterior, cells, pending))
+					nextSeps = append(nextSeps, sep)
+					cells = nil
+					pending = children[i+1]
+					continue
+				}
+				last := len(cells) - 1
+				right := binary.BigEndian.Uint32(cells[last])
+				nextChildren = append(nextChildren, db.add(sqliteIndexInterior, cells[:last], right))
+				nextSeps = append(nextSeps, seps[i-1])
+				cells = nil
+			}
+			cells = append(cells, cell)
+			pending = children[i+1]
+		}
+		nextChildren = append(nextChildren, db.add(sqliteIndexInterior, cells, pending))
+		children, seps = nextChildren, nextSeps
+	}
+	return children[0], nil
+}
+
+func sqliteHeaderSize(typ byte) int {
+	if typ == sqliteTableInterior || typ == sqliteIndexInterior {
+		return 12
+	}
+	return 8
+}
+
+func sqliteFits(typ byte, cells [][]byte, cell []byte) bool {
+	used := sqliteHeaderSize(typ) + 2*(len(cells)+1) + len(cell)
+	for _, c := range cells {
+		used += len(c)
+	}
+	return used <= sqlitePageSize
+}
+
+// sqlitePage lays out a b-tree page, with the page header at offset.
+func sqlitePage(offset int, typ byte, cells [][]byte, right uint32) []byte {
+	p := make([]byte, sqlitePageSize)
+	p[offset] = typ
+	binary.BigEndian.PutUint16(p[offset+3:], uint16(len(cells)))
+	end := sqlitePageSize
+	pointers := offset + sqliteHeaderSize(typ)
+	for i, c := range cells {
+		end -= len(c)
+		copy(p[end:], c)
+		binary.BigEndian.PutUint16(p[pointers+2*i:], uint16(end))
+	}
+	binary.BigEndian.PutUint16(p[offset+5:], uint16(end))
+	if sqliteHeaderSize(typ) == 12 {
+		binary.BigEndian.PutUint32(p[offset+8:], right)
+	}
+	return p
+}
+
+func sqliteTableCell(rowid int64, record []byte) []byte {
+	cell := appendSQLiteVarint(nil, uint64(len(record)))
+	cell = appendSQLiteVarint(cell, uint64(rowid))
+	return append(cell, record...)
+}
+
+// sqliteRecord encodes values (nil, int64 or string) in the record format.
+func sqliteRecord(values ...any) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = appendSQLiteVarint(types, 0)
+		case string:
+			types = appendSQLiteVarint(types, uint64(2*len(v)+13))
+			body = append(body, v...)
+		case int64:
+			serial, size := sqliteIntSerial(v)
+			types = appendSQLiteVarint(types, serial)
+			for i := size - 1; i >= 0; i-- {
+				body = append(body, byte(v>>(8*i)))
+			}
+		}
+	}
+
+	size := len(types) + 1
+	if size > 127 {
+		size++
+	}
+	record := appendSQLiteVarint(nil, uint64(size))
+	record = append(record, types...)
+	return append(record, body...)
+}
+
+func sqliteIntSerial(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= -1<<7 && v < 1<<7:
+		return 1, 1
+	case v >= -1<<15 && v < 1<<15:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= -1<<31 && v < 1<<31:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	default:
+		return 6, 8
+	}
+}
+
+// appendSQLiteVarint appends v as a big-endian varint. Values used here stay
+// below 2^56, so the 9 byte form is not needed.
+func appendSQLiteVarint(b []byte, v uint64) []byte {
+	var tmp [9]byte
+	n := len(tmp)
+	for {
+		n--
+		tmp[n] = byte(v & 0x7f)
+		if n < len(tmp)-1 {
+			tmp[n] |= 0x80
+		}
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	return append(b, tmp[n:]...)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func readSQLiteVarint(b []byte) (uint64, int) {
+	var v uint64
+	for i := range 9 {
+		if i == 8 {
+			return v<<8 | uint64(b[i]), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return v, i + 1
+		}
+	}
+	return v, 9
+}
+
+// sqliteRows walks the table b-tree at page and decodes the text and integer
+// columns of its rows.
+func sqliteRows(t *testing.T, db []byte, page uint32) [][]any {
+	t.Helper()
+	p := db[int(page-1)*sqlitePageSize : int(page)*sqlitePageSize]
+	header := 0
+	if page == 1 {
+		header = 100
+	}
+
+	var rows [][]any
+	n := int(binary.BigEndian.Uint16(p[header+3:]))
+	for i := range n {
+		cell := p[binary.BigEndian.Uint16(p[header+sqliteHeaderSize(p[header])+2*i:]):]
+		switch p[header] {
+		case sqliteTableInterior:
+			rows = append(rows, sqliteRows(t, db, binary.BigEndian.Uint32(cell))...)
+		case sqliteTableLeaf:
+			size, m := readSQLiteVarint(cell)
+			_, k := readSQLiteVarint(cell[m:])
+			record := cell[m+k : m+k+int(size)]
+
+			headerSize, m := readSQLiteVarint(record)
+			body := record[headerSize:]
+			var row []any
+			for h := record[m:headerSize]; len(h) > 0; {
+				serial, m := readSQLiteVarint(h)
+				h = h[m:]
+				switch {
+				case serial == 0:
+					row = append(row, nil)
+				case serial == 8 || serial == 9:
+					row = append(row, int64(serial-8))
+				case serial >= 13:
+					size := int(serial-13) / 2
+					row = append(row, string(body[:size]))
+					body = body[size:]
+				default:
+					size := []int{0, 1, 2, 3, 4, 6, 8}[serial]
+					var v int64
+					for _, c := range body[:size] {
+						v = v<<8 | int64(c)
+					}
+					row = append(row, v)
+					body = body[size:]
+				}
+			}
+			rows = append(rows, row)
+		default:
+			t.Fatalf("unexpected page type %#x on page %d", p[header], page)
+		}
+	}
+	if p[header] == sqliteTableInterior {
+		rows = append(rows, sqliteRows(t, db, binary.BigEndian.Uint32(p[header+8:]))...)
+	}
+	return rows
+}
+
+func TestSQLiteReport(t *testing.T) {
+	r := newSQLiteReport()
+	for i := range 500 {
+		r.add(classPrivate, fmt.Sprintf("10.0.%d.%d", i/256, i%256), fmt.Sprintf("host%03d.example.com", i))
+	}
+	r.add(classPublic, "1.1.1.1", "host000.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	db := out.Bytes()
+	if !bytes.HasPrefix(db, []byte("SQLite format 3\x00")) {
+		t.Fatalf("missing header")
+	}
+	if pages := int(binary.BigEndian.Uint32(db[28:])); pages*sqlitePageSize != len(db) {
+		t.Fatalf("expected %d pages, got %d bytes", pages, len(db))
+	}
+
+	roots := make(map[string]uint32)
+	for _, row := range sqliteRows(t, db, 1) {
+		roots[row[1].(string)] = uint32(row[3].(int64))
+	}
+	if len(roots) != len(sqliteSchema) {
+		t.Fatalf("expected %d schema entries, got %v", len(sqliteSchema), roots)
+	}
+
+	ips := sqliteRows(t, db, roots["ips"])
+	if len(ips) != 501 {
+		t.Fatalf("expected 501 ips, got %d", len(ips))
+	}
+	if want := []any{nil, "1.1.1.1", classPublic}; !reflect.DeepEqual(ips[0], want) {
+		t.Errorf("expected first ip %v, got %v", want, ips[0])
+	}
+
+	mappings := sqliteRows(t, db, roots["mappings"])
+	if len(mappings) != 501 {
+		t.Fatalf("expected 501 mappings, got %d", len(mappings))
+	}
+	// host000.example.com is subdomain 1, and maps to 1.1.1.1 and 10.0.0.0.
+	if want := [][]any{{int64(1), int64(1)}, {int64(1), int64(2)}}; !reflect.DeepEqual(mappings[:2], want) {
+		t.Errorf("expected mappings %v, got %v", want, mappings[:2])
+	}
+}
+
+func TestSQLiteIndexTooLong(t *testing.T) {
+	db := &sqliteDB{pages: [][]byte{nil}}
+	if _, err := db.index([][]byte{make([]byte, sqliteMaxIndexPayload+1)}); err == nil {
+		t.Fatal("expected error")
+	}
+}
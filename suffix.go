@@ -0,0 +1,48 @@
+package main
+
+import "strings"
+
+// secondLevelSuffixes lists the common public suffixes of two labels, under
+// which names are registered one level deeper than under a top-level domain.
+// It is a small subset of the Public Suffix List, covering the country code
+// registries seen most in bug bounty scopes.
+var secondLevelSuffixes = parseSuffixes(
+	"uk:ac,co,gov,ltd,me,net,nhs,org,plc,police,sch",
+	"au:asn,com,edu,gov,id,net,org",
+	"nz:ac,co,geek,gen,govt,kiwi,maori,net,org,school",
+	"jp:ac,ad,co,ed,go,gr,lg,ne,or",
+	"kr:ac,co,go,hs,mil,ne,or,pe,re",
+	"br:art,blog,com,eco,edu,gov,ind,inf,net,org",
+	"za:ac,co,gov,net,org,web",
+	"in:ac,co,edu,firm,gen,gov,ind,net,org,res",
+	"cn:ac,com,edu,gov,net,org",
+	"hk:com,edu,gov,idv,net,org",
+	"tw:com,edu,gov,idv,net,org",
+	"sg:com,edu,gov,net,org,per",
+	"my:com,edu,gov,net,org",
+	"mx:com,edu,gob,net,org",
+	"ar:com,edu,gob,net,org",
+	"tr:biz,com,edu,gen,gov,info,net,org,web",
+	"il:ac,co,gov,muni,net,org",
+	"id:ac,biz,co,go,my,net,or,sch,web",
+	"th:ac,co,go,in,net,or",
+	"ua:com,edu,gov,in,net,org",
+)
+
+func parseSuffixes(registries ...string) map[string]bool {
+	suffixes := make(map[string]bool)
+	for _, registry := range registries {
+		tld, labels, _ := strings.Cut(registry, ":")
+		for _, label := range strings.Split(labels, ",") {
+			suffixes[label+"."+tld] = true
+		}
+	}
+	return suffixes
+}
+
+// isPublicSuffix reports whether name is a top-level domain or one of the
+// known second level public suffixes, where nobody registers hostnames.
+func isPublicSuffix(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	return !strings.Contains(name, ".") || secondLevelSuffixes[name]
+}
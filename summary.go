@@ -0,0 +1,370 @@
+package main
+
+import (
+	"cmp"
+	htmltemplate "html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+const topIPsLimit = 10
+
+type summaryReport struct {
+	html     bool
+	classes  map[string]map[string][]string
+	errors   map[string]int
+	sinks    map[string]string
+	baseline map[[2]string]struct{}
+	previous map[string]int
+	sortMode sortMode
+}
+
+func newSummaryReport(path string, mode sortMode) *summaryReport {
+	ext := strings.ToLower(filepath.Ext(path))
+	return &summaryReport{
+		html:     ext == ".html" || ext == ".htm",
+		classes:  make(map[string]map[string][]string),
+		errors:   make(map[string]int),
+		sinks:    make(map[string]string),
+		sortMode: ipSortMode(mode),
+	}
+}
+
+func (r *summaryReport) loadBaseline(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	previous := newCombinedReport(r.sortMode)
+	if err := previous.load(in); err != nil {
+		return err
+	}
+
+	r.baseline = make(map[[2]string]struct{})
+	r.previous = make(map[string]int)
+	for ip, entry := range previous.ips {
+		r.previous[entry.class]++
+		for _, subdomain := range entry.subdomains {
+			r.baseline[[2]string{ip, subdomain}] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (r *summaryReport) add(class string, ip string, subdomain string) {
+	if r.classes[class] == nil {
+		r.classes[class] = make(map[string][]string)
+	}
+	if !slices.Contains(r.classes[class][ip], subdomain) {
+		r.classes[class][ip] = append(r.classes[class][ip], subdomain)
+	}
+}
+
+func (r *summaryReport) fail(_ string, err error) {
+	r.errors[errorCategory(err)]++
+}
+
+func (r *summaryReport) sinkFailed(name string, err error) {
+	r.sinks[name] = err.Error()
+}
+
+type summaryRow struct {
+	IP         string
+	Class      string
+	Subdomains []string
+	New        bool
+}
+
+type summaryClass struct {
+	Name        string
+	IPs         int
+	PreviousIPs int
+	Subdomains  int
+	Rows        []summaryRow
+}
+
+type summaryCount struct {
+	Name  string
+	Count int
+}
+
+type summaryFailure struct {
+	Name  string
+	Error string
+}
+
+type summaryView struct {
+	Version     string
+	Classes     []summaryClass
+	TopIPs      []summaryRow
+	HasBaseline bool
+	New         []summaryRow
+	Gone        []summaryRow
+	Errors      []summaryCount
+	ErrorsTotal int
+	FailedSinks []summaryFailure
+}
+
+func (r *summaryReport) view() summaryView {
+	v := summaryView{Version: readBuildDetails().String()}
+	current := make(map[[2]string]struct{})
+	var all []summaryRow
+
+	for _, class := range classes {
+		ips := make([]string, 0, len(r.classes[class]))
+		for ip := range r.classes[class] {
+			ips = append(ips, ip)
+		}
+		sortIPs(ips, r.sortMode)
+
+		sc := summaryClass{Name: class, IPs: len(ips), PreviousIPs: r.previous[class]}
+		subdomains := make(map[string]struct{})
+		for _, ip := range ips {
+			row := summaryRow{IP: ip, Class: class}
+			var added []string
+			for _, subdomain := range r.classes[class][ip] {
+				subdomains[subdomain] = struct{}{}
+				current[[2]string{ip, subdomain}] = struct{}{}
+				if _, ok := r.baseline[[2]string{ip, subdomain}]; r.baseline != nil && !ok {
+					row.New = true
+					added = append(added, subdomain)
+				}
+			}
+			row.Subdomains = slices.Clone(r.classes[class][ip])
+			slices.Sort(row.Subdomains)
+			sc.Rows = append(sc.Rows, row)
+			all = append(all, row)
+			if len(added) > 0 {
+				slices.Sort(added)
+				v.New = append(v.New, summaryRow{IP: ip, Class: class, Subdomains: added})
+			}
+		}
+		sc.Subdomains = len(subdomains)
+		v.Classes = append(v.Classes, sc)
+	}
+
+	slices.SortStableFunc(all, func(a, b summaryRow) int {
+		return cmp.Compare(len(b.Subdomains), len(a.Subdomains))
+	})
+	v.TopIPs = all[:min(len(all), topIPsLimit)]
+
+	if r.baseline != nil {
+		v.HasBaseline = true
+		gone := make(map[string][]string)
+		for pair := range r.baseline {
+			if _, ok := current[pair]; !ok {
+				gone[pair[0]] = append(gone[pair[0]], pair[1])
+			}
+		}
+		ips := make([]string, 0, len(gone))
+		for ip := range gone {
+			ips = append(ips, ip)
+		}
+		sortIPs(ips, r.sortMode)
+		for _, ip := range ips {
+			slices.Sort(gone[ip])
+			v.Gone = append(v.Gone, summaryRow{IP: ip, Subdomains: gone[ip]})
+		}
+	}
+
+	for category, n := range r.errors {
+		v.Errors = append(v.Errors, summaryCount{Name: category, Count: n})
+		v.ErrorsTotal += n
+	}
+	slices.SortFunc(v.Errors, func(a, b summaryCount) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Name, b.Name))
+	})
+
+	for name, err := range r.sinks {
+		v.FailedSinks = append(v.FailedSinks, summaryFailure{Name: name, Error: err})
+	}
+	slices.SortFunc(v.FailedSinks, func(a, b summaryFailure) int { return strings.Compare(a.Name, b.Name) })
+
+	return v
+}
+
+func (r *summaryReport) write(out io.Writer) error {
+	if r.html {
+		return summaryHTML.Execute(out, r.view())
+	}
+	return summaryMarkdown.Execute(out, r.view())
+}
+
+var summaryFuncs = map[string]any{
+	"join": strings.Join,
+}
+
+var summaryMarkdown = template.Must(template.New("markdown").Funcs(summaryFuncs).Parse(`# ipsubmap report
+
+Generated by {{.Version}}.
+
+## Summary
+
+| Class | IP addresses | Subdomains |
+| --- | --- | --- |
+{{- range .Classes}}
+| {{.Name}} | {{.IPs}} | {{.Subdomains}} |
+{{- end}}
+
+## Top IP addresses by subdomain count
+
+| IP address | Class | Subdomains |
+| --- | --- | --- |
+{{- range .TopIPs}}
+| {{.IP}} | {{.Class}} | {{len .Subdomains}} |
+{{- end}}
+{{- if .HasBaseline}}
+
+## Changes since the previous run
+
+### New
+
+| IP address | Class | Subdomains |
+| --- | --- | --- |
+{{- range .New}}
+| {{.IP}} | {{.Class}} | {{join .Subdomains ", "}} |
+{{- end}}
+
+### Gone
+
+| IP address | Subdomains |
+| --- | --- |
+{{- range .Gone}}
+| {{.IP}} | {{join .Subdomains ", "}} |
+{{- end}}
+{{- end}}
+
+## Errors
+
+{{if .Errors -}}
+| Category | Count |
+| --- | --- |
+{{- range .Errors}}
+| {{.Name}} | {{.Count}} |
+{{- end}}
+{{- else -}}
+No errors.
+{{- end}}
+{{- if .FailedSinks}}
+
+## Failed sinks
+
+Results were not delivered to these sinks:
+
+| Sink | Error |
+| --- | --- |
+{{- range .FailedSinks}}
+| {{.Name}} | {{.Error}} |
+{{- end}}
+{{- end}}
+{{range .Classes}}
+## {{.Name}}
+
+{{if .Rows -}}
+| IP address | Subdomains |{{if $.HasBaseline}} New |{{end}}
+| --- | --- |{{if $.HasBaseline}} --- |{{end}}
+{{- range .Rows}}
+| {{.IP}} | {{join .Subdomains ", "}} |{{if $.HasBaseline}} {{if .New}}yes{{end}} |{{end}}
+{{- end}}
+{{- else -}}
+No results.
+{{- end}}
+{{end -}}
+`))
+
+var summaryHTML = htmltemplate.Must(htmltemplate.New("html").Funcs(summaryFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ipsubmap report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f0f0f0; }
+tr.new td { background: #eaffea; }
+</style>
+</head>
+<body>
+<h1>ipsubmap report</h1>
+<p>Generated by {{.Version}}.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Class</th><th>IP addresses</th><th>Subdomains</th></tr>
+{{- range .Classes}}
+<tr><td>{{.Name}}</td><td>{{.IPs}}</td><td>{{.Subdomains}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Top IP addresses by subdomain count</h2>
+<table>
+<tr><th>IP address</th><th>Class</th><th>Subdomains</th></tr>
+{{- range .TopIPs}}
+<tr><td>{{.IP}}</td><td>{{.Class}}</td><td>{{len .Subdomains}}</td></tr>
+{{- end}}
+</table>
+{{- if .HasBaseline}}
+
+<h2>Changes since the previous run</h2>
+<h3>New</h3>
+<table>
+<tr><th>IP address</th><th>Class</th><th>Subdomains</th></tr>
+{{- range .New}}
+<tr><td>{{.IP}}</td><td>{{.Class}}</td><td>{{join .Subdomains ", "}}</td></tr>
+{{- end}}
+</table>
+<h3>Gone</h3>
+<table>
+<tr><th>IP address</th><th>Subdomains</th></tr>
+{{- range .Gone}}
+<tr><td>{{.IP}}</td><td>{{join .Subdomains ", "}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+
+<h2>Errors</h2>
+{{- if .Errors}}
+<table>
+<tr><th>Category</th><th>Count</th></tr>
+{{- range .Errors}}
+<tr><td>{{.Name}}</td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No errors.</p>
+{{- end}}
+{{- if .FailedSinks}}
+
+<h2>Failed sinks</h2>
+<p>Results were not delivered to these sinks:</p>
+<table>
+<tr><th>Sink</th><th>Error</th></tr>
+{{- range .FailedSinks}}
+<tr><td>{{.Name}}</td><td>{{.Error}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- range .Classes}}
+
+<h2>{{.Name}}</h2>
+{{- if .Rows}}
+<table>
+<tr><th>IP address</th><th>Subdomains</th></tr>
+{{- range .Rows}}
+<tr{{if .New}} class="new"{{end}}><td>{{.IP}}</td><td>{{join .Subdomains ", "}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No results.</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
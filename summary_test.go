@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSummaryReport_markdown(t *testing.T) {
+	baseline := filepath.Join(t.TempDir(), "previous.txt")
+	if err := os.WriteFile(baseline, []byte("1.1.1.1 public a.example.com\n3.3.3.3 public old.example.com"), 0o644); err != nil {
+		t.Fatalf("failed to write %q: %v", baseline, err)
+	}
+
+	r := newSummaryReport("report.md", sortNumeric)
+	if err := r.loadBaseline(baseline); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	r.add(classPublic, "1.1.1.1", "b.example.com")
+	r.add(classPrivate, "10.0.0.1", "c.example.com")
+	r.fail("d.example.com", &net.DNSError{Err: "no such host", IsNotFound: true})
+	r.sinkFailed("elasticsearch", errors.New("connection refused"))
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := out.String()
+	for _, want := range []string{
+		"| elasticsearch | connection refused |",
+		"| public | 1 | 2 |",
+		"| 1.1.1.1 | public | 2 |",
+		"| 1.1.1.1 | public | b.example.com |",
+		"| 3.3.3.3 | old.example.com |",
+		"| not_found | 1 |",
+		"| 10.0.0.1 | c.example.com | yes |",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected report to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestSummaryReport_html(t *testing.T) {
+	r := newSummaryReport("report.html", sortNumeric)
+	r.add(classPublic, "1.1.1.1", "<script>.example.com")
+
+	out := &bytes.Buffer{}
+	if err := r.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := out.String()
+	if !strings.Contains(got, "&lt;script&gt;.example.com") {
+		t.Errorf("expected subdomains to be escaped, got:\n%s", got)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+)
+
+type syslogWriter interface {
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+	Close() error
+}
+
+func parseSyslogTarget(target string) (network string, addr string, err error) {
+	if target == "local" {
+		return "", "", nil
+	}
+
+	network, addr, ok := strings.Cut(target, "://")
+	if !ok || (network != "udp" && network != "tcp") {
+		return "", "", fmt.Errorf("invalid syslog target %q: expected local, udp://host:port or tcp://host:port", target)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "514")
+	}
+	return network, addr, nil
+}
+
+type syslogHandler struct {
+	w   syslogWriter
+	h   slog.Handler
+	buf *bytes.Buffer
+	mu  *sync.Mutex
+}
+
+func newSyslogHandler(w syslogWriter, level slog.Leveler) *syslogHandler {
+	buf := &bytes.Buffer{}
+	return &syslogHandler{
+		w: w,
+		h: slog.NewTextHandler(buf, &slog.HandlerOptions{
+			Level: level,
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if len(groups) == 0 && a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}),
+		buf: buf,
+		mu:  &sync.Mutex{},
+	}
+}
+
+func (h *syslogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.h.Enabled(ctx, level)
+}
+
+func (h *syslogHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.h.Handle(ctx, r); err != nil {
+		return err
+	}
+	msg := strings.TrimSuffix(h.buf.String(), "\n")
+
+	switch {
+	case r.Level >= slog.LevelError:
+		return h.w.Err(msg)
+	case r.Level >= slog.LevelWarn:
+		return h.w.Warning(msg)
+	case r.Level >= slog.LevelInfo:
+		return h.w.Info(msg)
+	default:
+		return h.w.Debug(msg)
+	}
+}
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{w: h.w, h: h.h.WithAttrs(attrs), buf: h.buf, mu: h.mu}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{w: h.w, h: h.h.WithGroup(name), buf: h.buf, mu: h.mu}
+}
+
+type multiHandler []slog.Handler
+
+func (m multiHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range m {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m multiHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, h := range m {
+		if h.Enabled(ctx, r.Level) {
+			errs = append(errs, h.Handle(ctx, r.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m multiHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (m multiHandler) WithGroup(name string) slog.Handler {
+	handlers := make(multiHandler, len(m))
+	for i, h := range m {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
+
+type syslogReport struct {
+	w   syslogWriter
+	err error
+}
+
+func (r *syslogReport) add(class string, ip string, subdomain string) {
+	if r.err != nil {
+		return
+	}
+	r.err = r.w.Info(fmt.Sprintf("finding subdomain=%s ip=%s class=%s", subdomain, ip, class))
+}
+
+func (r *syslogReport) write(_ io.Writer) error {
+	return r.err
+}
@@ -0,0 +1,12 @@
+//go:build windows || plan9
+
+package main
+
+import "errors"
+
+func dialSyslog(target string) (syslogWriter, error) {
+	if _, _, err := parseSyslogTarget(target); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("syslog is not supported on this platform")
+}
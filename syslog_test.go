@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type fakeSyslog struct {
+	messages []string
+	err      error
+}
+
+func (f *fakeSyslog) log(severity, m string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.messages = append(f.messages, severity+" "+m)
+	return nil
+}
+
+func (f *fakeSyslog) Err(m string) error     { return f.log("err", m) }
+func (f *fakeSyslog) Warning(m string) error { return f.log("warning", m) }
+func (f *fakeSyslog) Info(m string) error    { return f.log("info", m) }
+func (f *fakeSyslog) Debug(m string) error   { return f.log("debug", m) }
+func (f *fakeSyslog) Close() error           { return nil }
+
+func TestParseSyslogTarget(t *testing.T) {
+	tt := map[string]struct {
+		target      string
+		wantNetwork string
+		wantAddr    string
+		wantErr     bool
+	}{
+		"local":        {target: "local"},
+		"udp":          {target: "udp://logs:1514", wantNetwork: "udp", wantAddr: "logs:1514"},
+		"default port": {target: "tcp://logs", wantNetwork: "tcp", wantAddr: "logs:514"},
+		"invalid":      {target: "logs:514", wantErr: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			network, addr, err := parseSyslogTarget(tc.target)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if network != tc.wantNetwork || addr != tc.wantAddr {
+				t.Errorf("expected %s %s, got %s %s", tc.wantNetwork, tc.wantAddr, network, addr)
+			}
+		})
+	}
+}
+
+func TestSyslogHandler(t *testing.T) {
+	w := &fakeSyslog{}
+	logger := slog.New(newSyslogHandler(w, slog.LevelInfo)).With(slog.String("app", "ipsubmap"))
+
+	logger.Debug("hidden")
+	logger.Info("Writing output files")
+	logger.Warn("failed to send webhook", "error", "timeout")
+	logger.Error("failed to open input file")
+
+	want := []string{
+		`info level=INFO msg="Writing output files" app=ipsubmap`,
+		`warning level=WARN msg="failed to send webhook" app=ipsubmap error=timeout`,
+		`err level=ERROR msg="failed to open input file" app=ipsubmap`,
+	}
+	if strings.Join(w.messages, "\n") != strings.Join(want, "\n") {
+		t.Errorf("expected %q, got %q", want, w.messages)
+	}
+}
+
+func TestSyslogReport(t *testing.T) {
+	w := &fakeSyslog{}
+	r := &syslogReport{w: w}
+	r.add(classPrivate, "10.0.0.1", "dev.example.com")
+	if err := r.write(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := "info finding subdomain=dev.example.com ip=10.0.0.1 class=private"
+	if len(w.messages) != 1 || w.messages[0] != want {
+		t.Errorf("expected %q, got %q", want, w.messages)
+	}
+}
+
+func TestIPSubMapWrite_syslogFailure(t *testing.T) {
+	ips := newIPReport(sortNumeric)
+	out := &strings.Builder{}
+	m := &ipSubMap{
+		reports: []reportOutput{
+			{name: "unique ip addresses", out: out, report: ips},
+			{name: "syslog findings", out: io.Discard, sink: true, report: &syslogReport{w: &fakeSyslog{err: errors.New("connection refused")}}},
+		},
+	}
+	for _, report := range m.reports {
+		report.add(classPublic, "1.1.1.1", "a.example.com")
+	}
+
+	failed := m.writeSinks()
+	if failed["syslog findings"] == nil || len(failed) != 1 {
+		t.Errorf("expected the syslog sink to fail, got %v", failed)
+	}
+	if err := m.write(); err != nil {
+		t.Fatalf("expected local outputs to be written, got %v", err)
+	}
+	if out.String() != "1.1.1.1" {
+		t.Errorf("unexpected local output %q", out.String())
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !windows && !plan9
+
+package main
+
+import "log/syslog"
+
+func dialSyslog(target string) (syslogWriter, error) {
+	network, addr, err := parseSyslogTarget(target)
+	if err != nil {
+		return nil, err
+	}
+	return syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, "ipsubmap")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+type templateData struct {
+	IP         string
+	Class      string
+	Subdomains []string
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+func parseLineTemplate(s string) (*template.Template, error) {
+	s = unescape(s)
+	if strings.Contains(s, "\n") {
+		return nil, fmt.Errorf("template must produce a single line")
+	}
+	return template.New("line").Option("missingkey=error").Funcs(templateFuncs).Parse(s)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFragmentWrite_template(t *testing.T) {
+	tt := map[string]struct {
+		template string
+		want     string
+	}{
+		"tab separated": {
+			template: `{{.IP}}\t{{.Class}}\t{{join .Subdomains " "}}`,
+			want:     "10.0.0.1\tprivate\ta.example.com b.example.com\n10.0.0.2\tprivate\tc.example.com",
+		},
+		"one subdomain per line": {
+			template: `{{range $i, $s := .Subdomains}}{{if $i}};{{end}}{{$s}}={{$.IP}}{{end}}`,
+			want:     "a.example.com=10.0.0.1;b.example.com=10.0.0.1\nc.example.com=10.0.0.2",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			tmpl, err := parseLineTemplate(tc.template)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			out := &bytes.Buffer{}
+			frag := fragment{
+				m: addrMap(map[string][]string{
+					"10.0.0.1": {"a.example.com", "b.example.com"},
+					"10.0.0.2": {"c.example.com"},
+				}),
+				sortMode: sortNumeric,
+				class:    classPrivate,
+				template: tmpl,
+			}
+			frag.out = frag.writerSink(out)
+			if err := frag.write(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseLineTemplate_invalid(t *testing.T) {
+	for _, s := range []string{"{{.IP", "{{.IP}}\n{{.Class}}", "{{upper .IP}}"} {
+		if _, err := parseLineTemplate(s); err == nil {
+			t.Errorf("expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import "sync"
+
+// jobQueue hands the lookups queued by the pipeline to its workers in order.
+// With a limit, at most limit lookups per zone are in flight: the jobs of a
+// saturated zone wait in a queue of their own, while the workers go on with
+// the jobs of other zones.
+type jobQueue struct {
+	limit int
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	ready    []*pendingLine
+	waiting  map[string][]*pendingLine
+	held     int
+	inFlight map[string]int
+	closed   bool
+}
+
+func newJobQueue(limit int) *jobQueue {
+	q := &jobQueue{limit: limit, waiting: make(map[string][]*pendingLine), inFlight: make(map[string]int)}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push queues p, holding it back while its zone is saturated.
+func (q *jobQueue) push(p *pendingLine) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.limit > 0 {
+		zone := zoneOf(p.line)
+		if q.inFlight[zone] >= q.limit {
+			q.waiting[zone] = append(q.waiting[zone], p)
+			q.held++
+			return
+		}
+		q.inFlight[zone]++
+	}
+	q.ready = append(q.ready, p)
+	q.cond.Signal()
+}
+
+// next returns the next job that may start, or false once the queue is closed
+// and empty.
+func (q *jobQueue) next() (*pendingLine, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.ready) == 0 && !(q.closed && q.held == 0) {
+		q.cond.Wait()
+	}
+	if len(q.ready) == 0 {
+		return nil, false
+	}
+	p := q.ready[0]
+	q.ready[0] = nil
+	q.ready = q.ready[1:]
+	return p, true
+}
+
+// done ends the lookup of p, letting the next held back job of its zone start.
+func (q *jobQueue) done(p *pendingLine) {
+	if q.limit <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	zone := zoneOf(p.line)
+	if waiting := q.waiting[zone]; len(waiting) > 0 {
+		q.ready = append(q.ready, waiting[0])
+		q.held--
+		if len(waiting) == 1 {
+			delete(q.waiting, zone)
+		} else {
+			q.waiting[zone] = waiting[1:]
+		}
+		// Once the last held back job is ready, idle workers of a closed
+		// queue may return.
+		q.cond.Broadcast()
+		return
+	}
+	if q.inFlight[zone]--; q.inFlight[zone] == 0 {
+		delete(q.inFlight, zone)
+	}
+}
+
+// close lets the workers return once the queued jobs have been taken.
+func (q *jobQueue) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.cond.Broadcast()
+}
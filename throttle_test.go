@@ -0,0 +1,89 @@
+package main
+
+import (
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJobQueue(t *testing.T) {
+	tt := map[string]struct {
+		limit int
+		hosts []string
+		peak  map[string]int
+		first []string
+	}{
+		"one zone": {
+			limit: 2,
+			hosts: []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com", "www.a.example.com"},
+			peak:  map[string]int{"example.com": 2},
+		},
+		"saturated zone": {
+			limit: 1,
+			hosts: []string{"a.example.com", "b.example.com", "c.example.com", "a.example.org", "a.example.net"},
+			peak:  map[string]int{"example.com": 1, "example.org": 1, "example.net": 1},
+			first: []string{"a.example.com", "a.example.net", "a.example.org"},
+		},
+		"unlimited": {
+			hosts: []string{"a.example.com", "b.example.com", "c.example.com"},
+			peak:  map[string]int{"example.com": 3},
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			q := newJobQueue(tc.limit)
+			for _, host := range tc.hosts {
+				q.push(&pendingLine{line: host})
+			}
+			q.close()
+
+			var mu sync.Mutex
+			running := make(map[string]int)
+			peak := make(map[string]int)
+			var started []string
+			var wg sync.WaitGroup
+			for range 3 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						p, ok := q.next()
+						if !ok {
+							return
+						}
+						zone := zoneOf(p.line)
+						mu.Lock()
+						started = append(started, p.line)
+						running[zone]++
+						peak[zone] = max(peak[zone], running[zone])
+						mu.Unlock()
+
+						time.Sleep(20 * time.Millisecond)
+
+						mu.Lock()
+						running[zone]--
+						mu.Unlock()
+						q.done(p)
+					}
+				}()
+			}
+			wg.Wait()
+
+			for zone, n := range tc.peak {
+				if peak[zone] != n {
+					t.Errorf("expected %d lookups in flight in %s at most, got %d", n, zone, peak[zone])
+				}
+			}
+			if len(started) != len(tc.hosts) {
+				t.Errorf("expected every job to run, got %v", started)
+			}
+			first := slices.Clone(started[:len(tc.first)])
+			slices.Sort(first)
+			if !slices.Equal(first, tc.first) {
+				t.Errorf("expected the other zones to go ahead of the saturated one, got %v", started)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const traceBatchSize = 512
+
+const (
+	spanKindInternal = 1
+	spanKindClient   = 3
+
+	spanStatusError = 2
+)
+
+type tracer struct {
+	endpoint string
+	service  string
+	traceID  [16]byte
+
+	mu    sync.Mutex
+	spans []otlpSpan
+	err   error
+}
+
+type span struct {
+	t      *tracer
+	id     [8]byte
+	parent [8]byte
+	name   string
+	kind   int
+	start  time.Time
+	attrs  []otlpAttribute
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+func stringAttr(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+func intAttr(key string, value int) otlpAttribute {
+	v := strconv.Itoa(value)
+	return otlpAttribute{Key: key, Value: otlpValue{IntValue: &v}}
+}
+
+func newTracer(endpoint, service string) (*tracer, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid otel endpoint %q", endpoint)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	t := &tracer{endpoint: u.String(), service: service}
+	rand.Read(t.traceID[:])
+	return t, nil
+}
+
+func (t *tracer) start(name string, parent *span, kind int, attrs ...otlpAttribute) *span {
+	if t == nil {
+		return nil
+	}
+
+	s := &span{t: t, name: name, kind: kind, start: time.Now(), attrs: attrs}
+	rand.Read(s.id[:])
+	if parent != nil {
+		s.parent = parent.id
+	}
+	return s
+}
+
+func (s *span) set(attrs ...otlpAttribute) {
+	if s == nil {
+		return
+	}
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func (s *span) end(err error) {
+	if s == nil {
+		return
+	}
+
+	out := otlpSpan{
+		TraceID:           hex.EncodeToString(s.t.traceID[:]),
+		SpanID:            hex.EncodeToString(s.id[:]),
+		Name:              s.name,
+		Kind:              s.kind,
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes:        s.attrs,
+	}
+	if s.parent != [8]byte{} {
+		out.ParentSpanID = hex.EncodeToString(s.parent[:])
+	}
+	if err != nil {
+		out.Status = otlpStatus{Code: spanStatusError, Message: err.Error()}
+	}
+
+	t := s.t
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, out)
+	if len(t.spans) >= traceBatchSize {
+		t.export()
+	}
+}
+
+// export sends the buffered spans, keeping the first error for shutdown.
+func (t *tracer) export() {
+	if len(t.spans) == 0 {
+		return
+	}
+	spans := t.spans
+	t.spans = nil
+
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpAttribute{stringAttr("service.name", t.service)},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]string{"name": "ipsubmap"},
+				"spans": spans,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err == nil {
+		err = postOTLP(t.endpoint, body)
+	}
+	if err != nil && t.err == nil {
+		t.err = fmt.Errorf("failed to export spans: %v", err)
+	}
+}
+
+func postOTLP(endpoint string, body []byte) error {
+	resp, err := httpClient.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+func (t *tracer) shutdown() error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.export()
+	return t.err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracer(t *testing.T) {
+	var got struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpSpan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	tr, err := newTracer(srv.URL, "ipsubmap")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	root := tr.start("enumerate", nil, spanKindInternal)
+	lookup := tr.start("lookup", root, spanKindClient, stringAttr("dns.name", "a.example.com"))
+	lookup.set(intAttr("dns.addresses", 0))
+	lookup.end(errors.New("no such host"))
+	root.end(nil)
+
+	if err := tr.shutdown(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if path != "/v1/traces" {
+		t.Errorf("expected spans posted to /v1/traces, got %q", path)
+	}
+	if len(got.ResourceSpans) != 1 || len(got.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected payload %+v", got)
+	}
+	spans := got.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	l, r := spans[0], spans[1]
+	if l.Name != "lookup" || l.ParentSpanID != r.SpanID || l.TraceID != r.TraceID || r.ParentSpanID != "" {
+		t.Errorf("unexpected span relationship: %+v %+v", l, r)
+	}
+	if l.Status.Code != spanStatusError || l.Status.Message != "no such host" {
+		t.Errorf("unexpected lookup status %+v", l.Status)
+	}
+	if len(l.Attributes) != 2 || *l.Attributes[0].Value.StringValue != "a.example.com" || *l.Attributes[1].Value.IntValue != "0" {
+		t.Errorf("unexpected lookup attributes %+v", l.Attributes)
+	}
+}
+
+func TestTracer_disabled(t *testing.T) {
+	var tr *tracer
+	s := tr.start("lookup", nil, spanKindClient)
+	s.set(intAttr("dns.addresses", 1))
+	s.end(nil)
+	if err := tr.shutdown(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var uploadClient = &http.Client{Timeout: 5 * time.Minute}
+
+type remoteTarget struct {
+	scheme string
+	bucket string
+	key    string
+}
+
+func parseRemote(path string) (remoteTarget, bool) {
+	scheme, rest, ok := strings.Cut(path, "://")
+	if !ok || (scheme != "s3" && scheme != "gs") {
+		return remoteTarget{}, false
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	return remoteTarget{scheme: scheme, bucket: bucket, key: key}, true
+}
+
+func (r remoteTarget) String() string {
+	return r.scheme + "://" + r.bucket + "/" + r.key
+}
+
+func (r remoteTarget) validate() error {
+	if r.bucket == "" || r.key == "" || strings.HasSuffix(r.key, "/") {
+		return fmt.Errorf("invalid destination %q: expected %s://bucket/key", r, r.scheme)
+	}
+	return nil
+}
+
+// uploadPartSize is the part size of multipart uploads to S3, and the size
+// above which they are used. A single PUT is limited to 5 GB.
+var uploadPartSize int64 = 64 << 20
+
+const uploadMaxParts = 10000
+
+func (r remoteTarget) upload(f *os.File, contentType string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to upload %q: %v", r, err)
+	}
+
+	switch {
+	case r.scheme == "s3" && info.Size() > uploadPartSize:
+		err = r.s3Multipart(f, info.Size(), contentType)
+	case r.scheme == "s3":
+		var req *http.Request
+		req, err = r.s3Request(http.MethodPut, nil, io.NewSectionReader(f, 0, info.Size()), time.Now().UTC())
+		if err == nil {
+			req.Header.Set("Content-Type", contentType)
+			_, _, err = sendUpload(req)
+		}
+	case r.scheme == "gs":
+		var req *http.Request
+		req, err = r.gcsRequest(io.NewSectionReader(f, 0, info.Size()), info.Size())
+		if err == nil {
+			req.Header.Set("Content-Type", contentType)
+			_, _, err = sendUpload(req)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload %q: %v", r, err)
+	}
+	return nil
+}
+
+func sendUpload(req *http.Request) (http.Header, []byte, error) {
+	resp, err := uploadClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, nil, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(body[:min(len(body), 512)]))
+	}
+	return resp.Header, body, nil
+}
+
+type s3Part struct {
+	Number int    `xml:"PartNumber"`
+	ETag   string `xml:"ETag"`
+}
+
+func (r remoteTarget) s3Multipart(f *os.File, size int64, contentType string) error {
+	req, err := r.s3Request(http.MethodPost, url.Values{"uploads": {""}}, nil, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	_, body, err := sendUpload(req)
+	if err != nil {
+		return err
+	}
+	var created struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.Unmarshal(body, &created); err != nil || created.UploadID == "" {
+		return fmt.Errorf("invalid multipart upload response: %s", bytes.TrimSpace(body))
+	}
+
+	if err := r.s3Parts(f, size, created.UploadID); err != nil {
+		if req, aerr := r.s3Request(http.MethodDelete, url.Values{"uploadId": {created.UploadID}}, nil, time.Now().UTC()); aerr == nil {
+			sendUpload(req)
+		}
+		return err
+	}
+	return nil
+}
+
+func (r remoteTarget) s3Parts(f *os.File, size int64, uploadID string) error {
+	partSize := max(uploadPartSize, (size+uploadMaxParts-1)/uploadMaxParts)
+	var parts []s3Part
+	for off, n := int64(0), 1; off < size; off, n = off+partSize, n+1 {
+		query := url.Values{"partNumber": {strconv.Itoa(n)}, "uploadId": {uploadID}}
+		req, err := r.s3Request(http.MethodPut, query, io.NewSectionReader(f, off, min(partSize, size-off)), time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		header, _, err := sendUpload(req)
+		if err != nil {
+			return fmt.Errorf("part %d: %v", n, err)
+		}
+		parts = append(parts, s3Part{Number: n, ETag: header.Get("ETag")})
+	}
+
+	complete, err := xml.Marshal(struct {
+		XMLName xml.Name `xml:"CompleteMultipartUpload"`
+		Parts   []s3Part `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	req, err := r.s3Request(http.MethodPost, url.Values{"uploadId": {uploadID}}, bytes.NewReader(complete), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	_, body, err := sendUpload(req)
+	if err != nil {
+		return err
+	}
+	// Completing an upload can fail after the response status was sent.
+	var result struct {
+		XMLName xml.Name
+		Message string
+	}
+	if xml.Unmarshal(body, &result) == nil && result.XMLName.Local == "Error" {
+		return fmt.Errorf("failed to complete multipart upload: %s", result.Message)
+	}
+	return nil
+}
+
+// s3Request returns a signed request for the object. The body is read once to
+// hash the payload and rewound before it is sent.
+func (r remoteTarget) s3Request(method string, query url.Values, body io.ReadSeeker, now time.Time) (*http.Request, error) {
+	accessKey := os.Getenv("AWS_ACCESS_KEY_ID")
+	secretKey := os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	var u string
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL"); endpoint != "" {
+		u = strings.TrimSuffix(endpoint, "/") + "/" + r.bucket + "/" + escapePath(r.key)
+	} else {
+		u = fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", r.bucket, region, escapePath(r.key))
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	hash := sha256.New()
+	var size int64
+	if body != nil {
+		n, err := io.Copy(hash, body)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := body.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		size = n
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	signV4(req, hex.EncodeToString(hash.Sum(nil)), accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), region, "s3", now)
+	return req, nil
+}
+
+func signV4(req *http.Request, payloadHash string, accessKey, secretKey, sessionToken, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+	var canonicalHeaders strings.Builder
+	for _, h := range headers {
+		v := req.Header.Get(h)
+		if h == "host" {
+			v = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey(secretKey, date, region, service), stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, credentialScope, signedHeaders, signature))
+}
+
+func signingKey(secretKey, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secretKey), date)
+	k = hmacSHA256(k, region)
+	k = hmacSHA256(k, service)
+	return hmacSHA256(k, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		var b strings.Builder
+		for _, c := range []byte(s) {
+			if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0 {
+				b.WriteByte(c)
+			} else {
+				fmt.Fprintf(&b, "%%%02X", c)
+			}
+		}
+		segments[i] = b.String()
+	}
+	return strings.Join(segments, "/")
+}
+
+func (r remoteTarget) gcsRequest(body io.Reader, size int64) (*http.Request, error) {
+	token, err := gcsToken()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := "https://storage.googleapis.com"
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		endpoint = strings.TrimSuffix(host, "/")
+		if !strings.Contains(endpoint, "://") {
+			endpoint = "http://" + endpoint
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodPut, endpoint+"/"+r.bucket+"/"+escapePath(r.key), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+func gcsToken() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+	if os.Getenv("STORAGE_EMULATOR_HOST") != "" {
+		return "", nil
+	}
+
+	u := url.URL{
+		Scheme: "http",
+		Host:   "metadata.google.internal",
+		Path:   "/computeMetadata/v1/instance/service-accounts/default/token",
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("GOOGLE_OAUTH_ACCESS_TOKEN is not set and the metadata server is unavailable: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned %s", resp.Status)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("invalid metadata server token: %v", err)
+	}
+	return token.AccessToken, nil
+}
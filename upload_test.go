@@ -0,0 +1,211 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseRemote(t *testing.T) {
+	tt := map[string]struct {
+		path    string
+		want    remoteTarget
+		ok      bool
+		wantErr bool
+	}{
+		"local": {
+			path: "out/public.txt",
+		},
+		"s3": {
+			path: "s3://bucket/runs/public.txt",
+			want: remoteTarget{scheme: "s3", bucket: "bucket", key: "runs/public.txt"},
+			ok:   true,
+		},
+		"gs": {
+			path: "gs://bucket/public.txt",
+			want: remoteTarget{scheme: "gs", bucket: "bucket", key: "public.txt"},
+			ok:   true,
+		},
+		"missing key": {
+			path:    "s3://bucket",
+			want:    remoteTarget{scheme: "s3", bucket: "bucket"},
+			ok:      true,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			got, ok := parseRemote(tc.path)
+			if ok != tc.ok || got != tc.want {
+				t.Fatalf("expected %+v %v, got %+v %v", tc.want, tc.ok, got, ok)
+			}
+			if ok {
+				if err := got.validate(); (err != nil) != tc.wantErr {
+					t.Errorf("unexpected validate error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestAtomicFile_upload(t *testing.T) {
+	var gotPath, gotAuth, gotType, gotBody string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotPath, gotAuth, gotType, gotBody = r.URL.EscapedPath(), r.Header.Get("Authorization"), r.Header.Get("Content-Type"), string(body)
+	}))
+	defer srv.Close()
+
+	tt := map[string]struct {
+		path     string
+		env      map[string]string
+		wantPath string
+		wantAuth string
+	}{
+		"s3": {
+			path: "s3://bucket/runs/public ips.txt",
+			env: map[string]string{
+				"AWS_ENDPOINT_URL":      srv.URL,
+				"AWS_ACCESS_KEY_ID":     "AKIDEXAMPLE",
+				"AWS_SECRET_ACCESS_KEY": "secret",
+				"AWS_REGION":            "eu-west-1",
+			},
+			wantPath: "/bucket/runs/public%20ips.txt",
+			wantAuth: "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/",
+		},
+		"gs": {
+			path: "gs://bucket/public.txt",
+			env: map[string]string{
+				"STORAGE_EMULATOR_HOST":     srv.URL,
+				"GOOGLE_OAUTH_ACCESS_TOKEN": "token",
+			},
+			wantPath: "/bucket/public.txt",
+			wantAuth: "Bearer token",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			out, err := createAtomic(tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			out.contentType = formatCSV.contentType()
+			if _, err := out.WriteString("1.1.1.1 example.com"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := out.Commit(); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if gotPath != tc.wantPath {
+				t.Errorf("expected path %q, got %q", tc.wantPath, gotPath)
+			}
+			if !strings.HasPrefix(gotAuth, tc.wantAuth) {
+				t.Errorf("expected authorization %q, got %q", tc.wantAuth, gotAuth)
+			}
+			if gotType != "text/csv" {
+				t.Errorf("expected content type text/csv, got %q", gotType)
+			}
+			if gotBody != "1.1.1.1 example.com" {
+				t.Errorf("unexpected body %q", gotBody)
+			}
+		})
+	}
+}
+
+func TestAtomicFile_multipartUpload(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	var contentType string
+	parts := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.RawQuery)
+		if sum := sha256.Sum256(body); r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+			http.Error(w, "payload hash mismatch", http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		switch {
+		case q.Has("uploads"):
+			contentType = r.Header.Get("Content-Type")
+			fmt.Fprint(w, "<InitiateMultipartUploadResult><UploadId>upload-1</UploadId></InitiateMultipartUploadResult>")
+		case q.Has("partNumber"):
+			parts[q.Get("partNumber")] = string(body)
+			w.Header().Set("ETag", `"etag-`+q.Get("partNumber")+`"`)
+		case r.Method == http.MethodPost:
+			var complete struct {
+				Parts []s3Part `xml:"Part"`
+			}
+			xml.Unmarshal(body, &complete)
+			if len(complete.Parts) != 3 || complete.Parts[2] != (s3Part{Number: 3, ETag: `"etag-3"`}) {
+				http.Error(w, "missing part", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, "<CompleteMultipartUploadResult></CompleteMultipartUploadResult>")
+		}
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_ENDPOINT_URL", srv.URL)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer func(size int64) { uploadPartSize = size }(uploadPartSize)
+	uploadPartSize = 8
+
+	out, err := createAtomic("s3://bucket/public.parquet")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out.contentType = formatParquet.contentType()
+	if _, err := out.WriteString("0123456789abcdefXYZ"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"POST uploads=",
+		"PUT partNumber=1&uploadId=upload-1",
+		"PUT partNumber=2&uploadId=upload-1",
+		"PUT partNumber=3&uploadId=upload-1",
+		"POST uploadId=upload-1",
+	}
+	if !slices.Equal(requests, want) {
+		t.Errorf("expected requests %q, got %q", want, requests)
+	}
+	wantParts := map[string]string{"1": "01234567", "2": "89abcdef", "3": "XYZ"}
+	if !maps.Equal(parts, wantParts) {
+		t.Errorf("expected parts %q, got %q", wantParts, parts)
+	}
+	if contentType != "application/vnd.apache.parquet" {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+}
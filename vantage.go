@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const localVantage = "local"
+
+// vantage is a DNS-over-HTTPS JSON endpoint resolving from another region,
+// either through a regional endpoint or an edns_client_subnet parameter.
+type vantage struct {
+	name     string
+	endpoint string
+}
+
+type vantageList []vantage
+
+func (l *vantageList) String() string {
+	if l == nil {
+		return ""
+	}
+	s := make([]string, len(*l))
+	for i, v := range *l {
+		s[i] = v.name + "=" + v.endpoint
+	}
+	return strings.Join(s, ",")
+}
+
+func (l *vantageList) Set(value string) error {
+	name, endpoint, ok := strings.Cut(value, "=")
+	if !ok || name == "" || !isURL(endpoint) {
+		return fmt.Errorf("invalid vantage %q: expected name=https://...", value)
+	}
+	if name == localVantage || slices.ContainsFunc(*l, func(v vantage) bool { return v.name == name }) {
+		return fmt.Errorf("duplicate vantage %q", name)
+	}
+	*l = append(*l, vantage{name: name, endpoint: endpoint})
+	return nil
+}
+
+type dohAnswer struct {
+	Type int    `json:"type"`
+	Data string `json:"data"`
+}
+
+type dohResponse struct {
+	Status int         `json:"Status"`
+	Answer []dohAnswer `json:"Answer"`
+}
+
+const (
+	dohTypeA    = 1
+	dohTypeAAAA = 28
+)
+
+// lookup resolves the addresses of host of the given record type. Names that
+// do not exist resolve to no addresses.
+func (v vantage) lookup(host string, rtype int) ([]net.IP, error) {
+	u, err := url.Parse(v.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("name", host)
+	q.Set("type", fmt.Sprint(rtype))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("vantage %s: unexpected status %s", v.name, resp.Status)
+	}
+
+	var r dohResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("vantage %s: %v", v.name, err)
+	}
+	// NOERROR and NXDOMAIN are answers, anything else is a resolver failure.
+	if r.Status != 0 && r.Status != 3 {
+		return nil, fmt.Errorf("vantage %s: rcode %d", v.name, r.Status)
+	}
+
+	var ips []net.IP
+	for _, a := range r.Answer {
+		if a.Type != rtype {
+			continue
+		}
+		if ip := net.ParseIP(a.Data); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+type vantageReport interface {
+	answered(subdomain string, vantage string, ips []string)
+}
+
+// vantageAnswers resolves subdomain through every vantage. Names that do not
+// exist count as an empty answer, and vantages failing for other reasons are
+// left out.
+func (m *ipSubMap) vantageAnswers(subdomain string) map[string][]string {
+	answers := make(map[string][]string)
+	for _, v := range m.vantages {
+		var ips []net.IP
+		var err error
+		for _, rtype := range []int{dohTypeA, dohTypeAAAA} {
+			if (rtype == dohTypeA && !m.ipv4) || (rtype == dohTypeAAAA && !m.ipv6) {
+				continue
+			}
+			var found []net.IP
+			if found, err = v.lookup(subdomain, rtype); err != nil {
+				break
+			}
+			ips = append(ips, found...)
+		}
+		if err == nil {
+			answers[v.name] = m.vantageIPs(ips)
+		}
+	}
+	return answers
+}
+
+// compareVantages hands the answers of the vantages, looked up by a prefetch
+// worker or here, along with the local ones, to the reports comparing them.
+func (m *ipSubMap) compareVantages(subdomain string, local []net.IP, localErr error) {
+	var answers map[string][]string
+	if p := m.pending; p != nil && p.vantages != nil && p.line == subdomain {
+		answers = p.vantages
+	} else {
+		answers = m.vantageAnswers(subdomain)
+	}
+	var dnsErr *net.DNSError
+	if localErr == nil || (errors.As(localErr, &dnsErr) && dnsErr.IsNotFound) {
+		answers[localVantage] = m.vantageIPs(local)
+	}
+
+	for _, r := range m.reports {
+		if x, ok := r.report.(vantageReport); ok {
+			for name, ips := range answers {
+				x.answered(subdomain, name, ips)
+			}
+		}
+	}
+}
+
+func (m *ipSubMap) vantageIPs(ips []net.IP) []string {
+	var s []string
+	for _, ip := range ips {
+		if (ip.To4() == nil && !m.ipv6) || (ip.To4() != nil && !m.ipv4) {
+			continue
+		}
+		s = append(s, formatIP(addrFromIP(ip)))
+	}
+	slices.Sort(s)
+	return slices.Compact(s)
+}
+
+type geoReport struct {
+	vantages []string
+	answers  map[string]map[string][]string
+}
+
+func newGeoReport(vantages vantageList) *geoReport {
+	r := &geoReport{
+		vantages: []string{localVantage},
+		answers:  make(map[string]map[string][]string),
+	}
+	for _, v := range vantages {
+		r.vantages = append(r.vantages, v.name)
+	}
+	return r
+}
+
+func (r *geoReport) add(string, string, string) {}
+
+func (r *geoReport) answered(subdomain string, vantage string, ips []string) {
+	if r.answers[subdomain] == nil {
+		r.answers[subdomain] = make(map[string][]string)
+	}
+	r.answers[subdomain][vantage] = ips
+}
+
+// write lists the subdomains whose answers differ between vantages, with the
+// answer of each vantage.
+func (r *geoReport) write(out io.Writer) error {
+	var names []string
+	for name, answers := range r.answers {
+		var first []string
+		compared := false
+		for _, v := range r.vantages {
+			ips, ok := answers[v]
+			if !ok {
+				continue
+			}
+			if compared && !slices.Equal(first, ips) {
+				names = append(names, name)
+				break
+			}
+			first, compared = ips, true
+		}
+	}
+	slices.Sort(names)
+
+	w := bufio.NewWriter(out)
+	for _, name := range names {
+		fmt.Fprint(w, name)
+		for _, v := range r.vantages {
+			ips, ok := r.answers[name][v]
+			if !ok {
+				continue
+			}
+			value := strings.Join(ips, ",")
+			if value == "" {
+				value = "-"
+			}
+			fmt.Fprintf(w, " %s=%s", v, value)
+		}
+		fmt.Fprintln(w)
+	}
+	return w.Flush()
+}
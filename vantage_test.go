@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVantageListSet(t *testing.T) {
+	tt := map[string]struct {
+		values []string
+		err    bool
+	}{
+		"valid":     {values: []string{"eu=https://dns.example/resolve", "us=https://dns.example/resolve?edns_client_subnet=8.8.8.0/24"}},
+		"no name":   {values: []string{"=https://dns.example/resolve"}, err: true},
+		"no url":    {values: []string{"eu=dns.example"}, err: true},
+		"local":     {values: []string{"local=https://dns.example/resolve"}, err: true},
+		"duplicate": {values: []string{"eu=https://a.example/resolve", "eu=https://b.example/resolve"}, err: true},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			var l vantageList
+			var err error
+			for _, v := range tc.values {
+				if err = l.Set(v); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tc.err {
+				t.Errorf("expected error %v, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func dohServer(answers map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		region := q.Get("edns_client_subnet")
+		if q.Get("type") != "1" {
+			io.WriteString(w, `{"Status":0}`)
+			return
+		}
+		switch ip, ok := answers[region+" "+q.Get("name")]; {
+		case ip == "servfail":
+			io.WriteString(w, `{"Status":2}`)
+		case ok:
+			fmt.Fprintf(w, `{"Status":0,"Answer":[{"type":5,"data":"cdn.example.net."},{"type":1,"data":%q}]}`, ip)
+		default:
+			io.WriteString(w, `{"Status":3}`)
+		}
+	}))
+}
+
+func TestVantageLookup(t *testing.T) {
+	srv := dohServer(map[string]string{"eu www.example.com": "10.0.0.2", "eu broken.example.com": "servfail"})
+	defer srv.Close()
+
+	v := vantage{name: "eu", endpoint: srv.URL + "/resolve?edns_client_subnet=eu"}
+	ips, err := v.lookup("www.example.com", dohTypeA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ips) != 1 || !ips[0].Equal(net.ParseIP("10.0.0.2")) {
+		t.Errorf("expected 10.0.0.2, got %v", ips)
+	}
+
+	if ips, err := v.lookup("missing.example.com", dohTypeA); err != nil || len(ips) != 0 {
+		t.Errorf("expected no addresses for a missing name, got %v, %v", ips, err)
+	}
+	if _, err := v.lookup("broken.example.com", dohTypeA); err == nil {
+		t.Error("expected error for SERVFAIL")
+	}
+}
+
+func TestIPSubMapCompareVantages(t *testing.T) {
+	srv := dohServer(map[string]string{
+		"eu www.example.com": "10.0.0.2",
+		"us www.example.com": "10.0.0.1",
+		"eu api.example.com": "10.0.0.3",
+		"us api.example.com": "10.0.0.3",
+		"eu geo.example.com": "10.0.0.4",
+	})
+	defer srv.Close()
+
+	lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "www.example.com":
+			return []net.IP{net.ParseIP("10.0.0.1")}, nil
+		case "api.example.com":
+			return []net.IP{net.ParseIP("10.0.0.3")}, nil
+		default:
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	vantages := vantageList{
+		{name: "eu", endpoint: srv.URL + "/resolve?edns_client_subnet=eu"},
+		{name: "us", endpoint: srv.URL + "/resolve?edns_client_subnet=us"},
+	}
+	report := newGeoReport(vantages)
+	m := &ipSubMap{
+		ipv4:     true,
+		vantages: vantages,
+		public:   fragment{m: make(map[netip.Addr][]string)},
+		private:  fragment{m: make(map[netip.Addr][]string)},
+		reports:  []reportOutput{{name: "geo", report: report}},
+	}
+	m.enumerate(strings.NewReader("www.example.com\napi.example.com\ngeo.example.com\n"))
+
+	out := &bytes.Buffer{}
+	if err := report.write(out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "geo.example.com local=- eu=10.0.0.4 us=-\n" +
+		"www.example.com local=10.0.0.1 eu=10.0.0.2 us=10.0.0.1\n"
+	if out.String() != want {
+		t.Errorf("expected %q, got %q", want, out.String())
+	}
+}
+
+func TestIPSubMapCompareVantages_prefetch(t *testing.T) {
+	var mu sync.Mutex
+	running, peak := 0, 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		fmt.Fprint(w, `{"Status":0,"Answer":[{"type":1,"data":"10.0.0.2"}]}`)
+	}))
+	defer srv.Close()
+
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("10.0.0.1")}, nil
+	}
+	defer func() { lookupIP = net.LookupIP }()
+
+	vantages := vantageList{{name: "eu", endpoint: srv.URL + "/resolve"}}
+	report := newGeoReport(vantages)
+	m := &ipSubMap{
+		ipv4:     true,
+		workers:  4,
+		vantages: vantages,
+		private:  fragment{m: make(map[netip.Addr][]string)},
+		reports:  []reportOutput{{name: "geo", report: report}},
+	}
+	var in strings.Builder
+	for i := range 8 {
+		fmt.Fprintf(&in, "h%d.example.com\n", i)
+	}
+	if err := m.enumerate(strings.NewReader(in.String())); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.answers) != 8 {
+		t.Errorf("expected answers for 8 names, got %d", len(report.answers))
+	}
+	if peak < 2 {
+		t.Errorf("expected the vantages to be queried by the workers concurrently, got %d at most", peak)
+	}
+}
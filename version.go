@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set at build time with -ldflags "-X main.version=... -X main.commit=... -X main.date=...".
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+type buildDetails struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	Date      string `json:"date,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+func readBuildDetails() buildDetails {
+	d := buildDetails{Version: version, Commit: commit, Date: date, GoVersion: runtime.Version()}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if d.Version == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			d.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch {
+			case s.Key == "vcs.revision" && d.Commit == "":
+				d.Commit = s.Value
+			case s.Key == "vcs.time" && d.Date == "":
+				d.Date = s.Value
+			case s.Key == "vcs.modified" && s.Value == "true" && d.Commit != "" && commit == "":
+				d.Commit += "-dirty"
+			}
+		}
+	}
+
+	if d.Version == "" {
+		d.Version = "dev"
+	}
+	return d
+}
+
+func (d buildDetails) String() string {
+	s := "ipsubmap " + d.Version
+	if d.Commit != "" {
+		s += fmt.Sprintf(" (commit %s", d.Commit)
+		if d.Date != "" {
+			s += ", built " + d.Date
+		}
+		s += ")"
+	}
+	return s + " " + d.GoVersion
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestReadBuildDetails(t *testing.T) {
+	defer func(v, c, d string) { version, commit, date = v, c, d }(version, commit, date)
+	version, commit, date = "v1.2.3", "abc123", "2024-01-02T03:04:05Z"
+
+	d := readBuildDetails()
+	want := "ipsubmap v1.2.3 (commit abc123, built 2024-01-02T03:04:05Z) " + runtime.Version()
+	if got := d.String(); got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestBuildDetailsString(t *testing.T) {
+	tt := map[string]struct {
+		details buildDetails
+		want    string
+	}{
+		"version only": {
+			details: buildDetails{Version: "dev", GoVersion: "go1.22.4"},
+			want:    "ipsubmap dev go1.22.4",
+		},
+		"commit without date": {
+			details: buildDetails{Version: "v1.0.0", Commit: "abc123", GoVersion: "go1.22.4"},
+			want:    "ipsubmap v1.0.0 (commit abc123) go1.22.4",
+		},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			if got := tc.details.String(); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
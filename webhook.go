@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type webhookClass struct {
+	IPs        int `json:"ips"`
+	Subdomains int `json:"subdomains"`
+}
+
+type webhookFinding struct {
+	IP         string   `json:"ip"`
+	Class      string   `json:"class,omitempty"`
+	Subdomains []string `json:"subdomains"`
+}
+
+type webhookPayload struct {
+	SchemaVersion int                     `json:"schema_version"`
+	Event         string                  `json:"event"`
+	Version       string                  `json:"version"`
+	Input         string                  `json:"input"`
+	StartedAt     time.Time               `json:"started_at"`
+	FinishedAt    time.Time               `json:"finished_at"`
+	Classes       map[string]webhookClass `json:"classes"`
+	Errors        map[string]int          `json:"errors"`
+	Dropped       map[string]int          `json:"dropped"`
+	New           []webhookFinding        `json:"new,omitempty"`
+	Gone          []webhookFinding        `json:"gone,omitempty"`
+}
+
+func newWebhookPayload(v summaryView, findings bool) webhookPayload {
+	p := webhookPayload{
+		SchemaVersion: schemaVersion,
+		Event:         "run_completed",
+		Version:       v.Version,
+		Classes:       make(map[string]webhookClass),
+		Errors:        make(map[string]int),
+		Dropped:       make(map[string]int),
+	}
+	for _, c := range v.Classes {
+		p.Classes[c.Name] = webhookClass{IPs: c.IPs, Subdomains: c.Subdomains}
+	}
+	for _, e := range v.Errors {
+		p.Errors[e.Name] = e.Count
+	}
+
+	if findings {
+		for _, row := range v.New {
+			p.New = append(p.New, webhookFinding{IP: row.IP, Class: row.Class, Subdomains: row.Subdomains})
+		}
+		for _, row := range v.Gone {
+			p.Gone = append(p.Gone, webhookFinding{IP: row.IP, Subdomains: row.Subdomains})
+		}
+	}
+	return p
+}
+
+func postJSON(url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookPayload(t *testing.T) {
+	r := newSummaryReport("", sortLexical)
+	r.baseline = map[[2]string]struct{}{
+		{"1.1.1.1", "a.example.com"}: {},
+		{"3.3.3.3", "c.example.com"}: {},
+	}
+	r.add(classPublic, "1.1.1.1", "a.example.com")
+	r.add(classPrivate, "10.0.0.1", "b.example.com")
+	r.fail("d.example.com", errNoAddresses)
+
+	tt := map[string]struct {
+		findings bool
+		wantNew  int
+		wantGone int
+	}{
+		"summary only":  {findings: false},
+		"with findings": {findings: true, wantNew: 1, wantGone: 1},
+	}
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			p := newWebhookPayload(r.view(), tc.findings)
+			if p.Classes[classPublic].IPs != 1 || p.Classes[classPrivate].Subdomains != 1 {
+				t.Errorf("unexpected classes %+v", p.Classes)
+			}
+			if p.Errors["no_addresses"] != 1 {
+				t.Errorf("unexpected errors %+v", p.Errors)
+			}
+			if len(p.New) != tc.wantNew || len(p.Gone) != tc.wantGone {
+				t.Errorf("expected %d new and %d gone, got %+v and %+v", tc.wantNew, tc.wantGone, p.New, p.Gone)
+			}
+		})
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	var got webhookPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	if err := postJSON(srv.URL, webhookPayload{Event: "run_completed", Input: "subdomains.txt"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Event != "run_completed" || got.Input != "subdomains.txt" {
+		t.Errorf("unexpected payload %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	if err := postJSON(failing.URL, webhookPayload{}); err == nil {
+		t.Error("expected error for failing webhook")
+	}
+}